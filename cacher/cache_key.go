@@ -21,8 +21,10 @@ func GetCustomerCacheKeyByID(id uuid.UUID) string {
 	return createCacheKey(fmt.Sprintf("%s:%s:id:%s", cachePrefix, customerPrefix, id.String()))
 }
 
-func GetCustomerCacheKeyByNIK(nik string) string {
-	return createCacheKey(fmt.Sprintf("%s:%s:nik:%s", cachePrefix, customerPrefix, nik))
+// GetCustomerIDCacheKeyByNIK indexes a NIK to its customer ID only; the
+// customer itself is cached under GetCustomerCacheKeyByID.
+func GetCustomerIDCacheKeyByNIK(nik string) string {
+	return createCacheKey(fmt.Sprintf("%s:%s:nik:%s:id", cachePrefix, customerPrefix, nik))
 }
 
 func GetCustomerDocumentsCacheKey(customerID uuid.UUID) string {
@@ -49,6 +51,24 @@ func GetTransactionCacheKey(transactionID uuid.UUID) string {
 	return createCacheKey(fmt.Sprintf("%s:%s:id:%s", cachePrefix, transactionPrefix, transactionID.String()))
 }
 
+func GetTransactionCacheKeyByContractNumber(contractNumber string) string {
+	return createCacheKey(fmt.Sprintf("%s:%s:contract:%s", cachePrefix, transactionPrefix, contractNumber))
+}
+
+func GetTransactionIdempotencyCacheKey(customerID uuid.UUID, idempotencyKey string) string {
+	return createCacheKey(fmt.Sprintf("%s:%s:idempotency:%s:%s", cachePrefix, transactionPrefix, customerID.String(), idempotencyKey))
+}
+
+func GetCreditLimitLockKey(customerID uuid.UUID, tenorMonth int) string {
+	return createCacheKey(fmt.Sprintf("lock:%s:customer:%s:tenor:%d", limitPrefix, customerID.String(), tenorMonth))
+}
+
+// GetInstallmentReminderMarkerKey marks that a due reminder for the installment
+// went out on day (YYYY-MM-DD).
+func GetInstallmentReminderMarkerKey(installmentID uuid.UUID, day string) string {
+	return createCacheKey(fmt.Sprintf("reminder:installment:%s:%s", installmentID.String(), day))
+}
+
 func GetMultipleCustomerCacheKeys(ids []uuid.UUID) []string {
 	keys := make([]string, len(ids))
 	for i, id := range ids {
@@ -68,3 +88,13 @@ func GetMultipleCreditLimitCacheKeys(ids []uuid.UUID) []string {
 func GetAssetCacheKey(id uuid.UUID) string {
 	return fmt.Sprintf("asset:%s", id.String())
 }
+
+func GetAssetListVersionCacheKey() string {
+	return "assets:version"
+}
+
+// GetAssetListCacheKey scopes a list page to the current assets version, so
+// bumping the version orphans every cached page at once.
+func GetAssetListCacheKey(version string, filterHash string) string {
+	return fmt.Sprintf("assets:list:v%s:%s", version, filterHash)
+}
@@ -0,0 +1,31 @@
+package cacher
+
+import (
+	"context"
+	"encoding/json"
+	"kredit-plus/infra/redis"
+	"time"
+)
+
+// GetOrLoad returns the value cached under key, or calls loader and caches its
+// result for ttl. A nil value from loader is returned as is and never cached.
+// Cache failures never fail the call; the redis client logs them.
+func GetOrLoad[T any](ctx context.Context, redisClient *redis.Client, key string, ttl time.Duration, loader func() (*T, error)) (*T, error) {
+	if cachedData, err := redisClient.Get(ctx, key); err == nil {
+		var value T
+		if err := json.Unmarshal([]byte(cachedData), &value); err == nil {
+			return &value, nil
+		}
+	}
+
+	value, err := loader()
+	if err != nil || value == nil {
+		return value, err
+	}
+
+	if data, err := json.Marshal(value); err == nil {
+		_ = redisClient.SetWithJitter(ctx, key, string(data), ttl)
+	}
+
+	return value, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -10,10 +11,22 @@ import (
 	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/infra/mysql"
 	"kredit-plus/infra/redis"
+	"kredit-plus/infra/telemetry"
+	"kredit-plus/infra/webhook"
+	"kredit-plus/internal/entity"
+	"kredit-plus/internal/middleware"
 	"kredit-plus/wire"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+)
+
+const (
+	defaultOverdueSweepInterval = time.Hour
+	defaultReminderInterval     = time.Hour
+	defaultReminderDaysAhead    = 3
+	defaultShutdownTimeout      = 30 * time.Second
 )
 
 func main() {
@@ -21,21 +34,24 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("failed to load config: %v", err))
 	}
+	entity.SetAllowedTenors(cfg.CreditPolicy.AllowedTenors)
 	ctx := context.Background()
 
 	//Init Logger
-	logger, err := loggerPkg.NewLogger(loggerPkg.Config(cfg.Logger))
+	logger, logLevel, err := loggerPkg.NewLogger(loggerPkg.Config(cfg.Logger))
 	if err != nil {
 		panic(fmt.Sprintf("failed to create logger: %v", err))
 	}
 	defer logger.Sync()
+	//Fallback for loggerPkg.FromContext outside a request
+	zap.ReplaceGlobals(logger)
 
 	//Init Otel
-	//shutdown, err := telemetry.InitTracer(ctx, telemetry.Config(cfg.Telemetry), logger)
-	//if err != nil {
-	//	logger.Fatal("failed to initialize telemetry", zap.Error(err))
-	//}
-	//defer shutdown()
+	shutdownTracer, err := telemetry.InitTracer(ctx, telemetry.Config(cfg.Telemetry), logger)
+	if err != nil {
+		logger.Fatal("failed to initialize telemetry", zap.Error(err))
+	}
+	defer shutdownTracer()
 
 	//Init GORM (MySQL)
 	db, err := mysql.NewClient(ctx, mysql.Config(cfg.MySQL), logger)
@@ -43,6 +59,11 @@ func main() {
 		logger.Fatal("failed to connect to database", zap.Error(err))
 	}
 	defer db.Close()
+	if cfg.MySQL.AutoMigrate {
+		if err := db.Migrate(ctx); err != nil {
+			logger.Fatal("failed to migrate database", zap.Error(err))
+		}
+	}
 
 	//Init Redis
 	redisClient, err := redis.NewClient(redis.Config(cfg.Redis), logger)
@@ -51,39 +72,53 @@ func main() {
 	}
 	defer redisClient.Close()
 
+	//Init Webhook
+	webhookClient := webhook.NewClient(webhook.Config(cfg.App.Webhook), logger)
+
 	//Server (Fiber)
 	app := fiber.New(fiber.Config{
 		ErrorHandler: customErrorHandler,
 	})
+	app.Use(middleware.RequestID(logger))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
 	}))
 
-	//Asset
-	assetHandler, err := wire.InitializeAssetHandler(db, redisClient, logger)
-	if err != nil {
-		logger.Fatal("failed to initialize asset handler", zap.Error(err))
-	}
-	assetHandler.RegisterRoutes(app)
-	//Customer
-	customerHandler, err := wire.InitializeCustomerHandler(db, redisClient, logger)
-	if err != nil {
-		logger.Fatal("failed to initialize customer handler", zap.Error(err))
-	}
-	customerHandler.RegisterRoutes(app)
-	//Credit Limit
-	creditLimitHandler, err := wire.InitializeCreditLimitHandler(db, redisClient, logger)
-	if err != nil {
-		logger.Fatal("failed to initialize credit limit handler", zap.Error(err))
-	}
-	creditLimitHandler.RegisterRoutes(app)
-	//Transaction
-	transactionHandler, err := wire.InitializeTransactionProviderHandler(db, redisClient, logger)
+	//Auth
+	authMiddleware := middleware.JWTAuth(middleware.AuthConfig(cfg.Auth))
+	app.Use("/api/v1", authMiddleware)
+	//Admin routes are refused outright while auth is disabled
+	app.Use("/admin", authMiddleware, middleware.RequireActor())
+
+	//Handlers
+	application, err := wire.InitializeApp(db, redisClient, webhookClient, entity.CreditPolicy(cfg.CreditPolicy), logLevel, logger)
 	if err != nil {
-		logger.Fatal("failed to initialize transaction handler", zap.Error(err))
+		logger.Fatal("failed to initialize application", zap.Error(err))
 	}
-	transactionHandler.RegisterRoutes(app)
+	application.RegisterRoutes(app)
+
+	//Background Jobs
+	jobCtx, cancelJobs := context.WithCancel(ctx)
+	defer cancelJobs()
+
+	sweepDone := make(chan struct{})
+	go func() {
+		defer close(sweepDone)
+		runOverdueSweep(jobCtx, application.TransactionService, cfg.App.OverdueSweepInterval, cfg.App.LateFeeRate, logger)
+	}()
+
+	poolStatsDone := make(chan struct{})
+	go func() {
+		defer close(poolStatsDone)
+		runPoolStatsLogger(jobCtx, db, cfg.MySQL.StatsInterval, logger)
+	}()
+
+	remindersDone := make(chan struct{})
+	go func() {
+		defer close(remindersDone)
+		runDueReminders(jobCtx, application.TransactionService, cfg.App.ReminderInterval, cfg.App.ReminderDaysAhead, logger)
+	}()
 
 	//Start Server
 	go func() {
@@ -95,9 +130,118 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	logger.Info("shutting down server...")
-	if err := app.Shutdown(); err != nil {
-		logger.Fatal("server forced to shutdown", zap.Error(err))
+	shutdownTimeout := cfg.App.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	logger.Info("shutting down server...",
+		zap.Int32("open_connections", app.Server().GetOpenConnectionsCount()),
+		zap.Duration("timeout", shutdownTimeout),
+	)
+
+	//Drain in-flight requests first, then stop jobs; DB and Redis close in the deferred calls after both
+	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		logger.Error("server forced to shutdown",
+			zap.Error(err),
+			zap.Int32("open_connections", app.Server().GetOpenConnectionsCount()),
+		)
+	}
+	cancelJobs()
+	<-sweepDone
+	<-remindersDone
+	<-poolStatsDone
+
+	//Give queued webhooks the same budget to go out before exiting
+	webhookCtx, cancelWebhook := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancelWebhook()
+	if err := webhookClient.Close(webhookCtx); err != nil {
+		logger.Error("failed to deliver pending webhooks", zap.Error(err))
+	}
+
+	logger.Info("server stopped")
+}
+
+func runOverdueSweep(ctx context.Context, transactionService entity.TransactionService, interval time.Duration, lateFeeRate float64, logger *zap.Logger) {
+	if interval <= 0 {
+		interval = defaultOverdueSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("overdue sweep stopped")
+			return
+		case <-ticker.C:
+			if _, err := transactionService.RunOverdueSweep(ctx, lateFeeRate); err != nil {
+				logger.Error("overdue sweep failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func runDueReminders(ctx context.Context, transactionService entity.TransactionService, interval time.Duration, daysAhead int, logger *zap.Logger) {
+	if interval <= 0 {
+		interval = defaultReminderInterval
+	}
+	if daysAhead <= 0 {
+		daysAhead = defaultReminderDaysAhead
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("due reminders stopped")
+			return
+		case <-ticker.C:
+			if _, err := transactionService.SendDueReminders(ctx, daysAhead); err != nil {
+				logger.Error("due reminders failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// runPoolStatsLogger logs a snapshot of the MySQL connection pool every
+// interval. Waits since the previous snapshot show how often requests queued
+// for a connection, e.g. behind FOR UPDATE locks during transaction creation.
+func runPoolStatsLogger(ctx context.Context, db *mysql.Client, interval time.Duration, logger *zap.Logger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous sql.DBStats
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("pool stats logger stopped")
+			return
+		case <-ticker.C:
+			stats, err := db.Stats()
+			if err != nil {
+				logger.Error("failed to read connection pool stats", zap.Error(err))
+				continue
+			}
+
+			logger.Info("mysql connection pool stats",
+				zap.Int("max_open_connections", stats.MaxOpenConnections),
+				zap.Int("open_connections", stats.OpenConnections),
+				zap.Int("in_use", stats.InUse),
+				zap.Int("idle", stats.Idle),
+				zap.Int64("wait_count", stats.WaitCount),
+				zap.Duration("wait_duration", stats.WaitDuration),
+				zap.Int64("wait_count_delta", stats.WaitCount-previous.WaitCount),
+				zap.Duration("wait_duration_delta", stats.WaitDuration-previous.WaitDuration),
+			)
+			previous = stats
+		}
 	}
 }
 
@@ -3,53 +3,127 @@ package config
 import (
 	"fmt"
 	"github.com/spf13/viper"
+	"go.uber.org/zap/zapcore"
+	"sort"
+	"strings"
 	"time"
 )
 
+const (
+	defaultMySQLPort         = 3306
+	defaultMySQLMaxOpenConns = 100
+	defaultMySQLMaxIdleConns = 10
+	defaultRedisPort         = 6379
+	defaultRedisPoolSize     = 20
+	defaultLogLevel          = "info"
+
+	EnvPrefix = "KP"
+)
+
+// Config is loaded from config.yaml. Any key present in the file can be
+// overridden by an environment variable named after its path, upper-cased,
+// with dots replaced by underscores and prefixed with EnvPrefix, so
+// mysql.password is KP_MYSQL_PASSWORD and app.webhook.secret is
+// KP_APP_WEBHOOK_SECRET.
 type Config struct {
-	App       AppConfig       `mapstructure:"app"`
-	MySQL     MySQLConfig     `mapstructure:"mysql"`
-	Redis     RedisConfig     `mapstructure:"redis"`
-	Logger    LoggerConfig    `mapstructure:"logger"`
-	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+	App          AppConfig          `mapstructure:"app"`
+	MySQL        MySQLConfig        `mapstructure:"mysql"`
+	Redis        RedisConfig        `mapstructure:"redis"`
+	Logger       LoggerConfig       `mapstructure:"logger"`
+	Telemetry    TelemetryConfig    `mapstructure:"telemetry"`
+	CreditPolicy CreditPolicyConfig `mapstructure:"credit_policy"`
+	Auth         AuthConfig         `mapstructure:"auth"`
 }
 
 type AppConfig struct {
-	Name        string `mapstructure:"name"`
-	Version     string `mapstructure:"version"`
-	Environment string `mapstructure:"environment"`
-	Port        int    `mapstructure:"port"`
+	Name                 string        `mapstructure:"name"`
+	Version              string        `mapstructure:"version"`
+	Environment          string        `mapstructure:"environment"`
+	Port                 int           `mapstructure:"port"`
+	OverdueSweepInterval time.Duration `mapstructure:"overdue_sweep_interval"`
+	LateFeeRate          float64       `mapstructure:"late_fee_rate"` //Percent of the installment amount per overdue month
+	ReminderInterval     time.Duration `mapstructure:"reminder_interval"`
+	ReminderDaysAhead    int           `mapstructure:"reminder_days_ahead"` //Remind customers this many days before an installment is due
+	ShutdownTimeout      time.Duration `mapstructure:"shutdown_timeout"`
+	Webhook              WebhookConfig `mapstructure:"webhook"`
+}
+
+type WebhookConfig struct {
+	URL        string        `mapstructure:"url"` //Empty disables transaction status webhooks
+	Secret     string        `mapstructure:"secret"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	MaxRetries int           `mapstructure:"max_retries"`
+	QueueSize  int           `mapstructure:"queue_size"`
 }
 
 type MySQLConfig struct {
-	Host         string        `mapstructure:"host"`
-	Port         int           `mapstructure:"port"`
-	User         string        `mapstructure:"user"`
-	Password     string        `mapstructure:"password"`
-	Database     string        `mapstructure:"database"`
-	MaxOpenConns int           `mapstructure:"max_open_conns"`
-	MaxIdleConns int           `mapstructure:"max_idle_conns"`
-	MaxLifetime  time.Duration `mapstructure:"max_lifetime"`
-	Debug        bool          `mapstructure:"debug"`
+	Host          string        `mapstructure:"host"`
+	Port          int           `mapstructure:"port"`
+	User          string        `mapstructure:"user"`
+	Password      string        `mapstructure:"password"`
+	Database      string        `mapstructure:"database"`
+	MaxOpenConns  int           `mapstructure:"max_open_conns"`
+	MaxIdleConns  int           `mapstructure:"max_idle_conns"`
+	MaxLifetime   time.Duration `mapstructure:"max_lifetime"`
+	Debug         bool          `mapstructure:"debug"`
+	AutoMigrate   bool          `mapstructure:"auto_migrate"`
+	QueryTimeout  time.Duration `mapstructure:"query_timeout"`
+	Replicas      []string      `mapstructure:"replicas"`       //host:port of read replicas, same credentials as primary
+	Tracing       bool          `mapstructure:"tracing"`        //Emit a span per SQL statement
+	StatsInterval time.Duration `mapstructure:"stats_interval"` //How often to log connection pool stats, 0 disables
 }
 
 type RedisConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Password string `mapstructure:"password"`
-	DB       int    `mapstructure:"db"`
+	Host             string        `mapstructure:"host"`
+	Port             int           `mapstructure:"port"`
+	Password         string        `mapstructure:"password"`
+	DB               int           `mapstructure:"db"`
+	PoolSize         int           `mapstructure:"pool_size"`
+	MinIdleConns     int           `mapstructure:"min_idle_conns"`
+	DialTimeout      time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout      time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout     time.Duration `mapstructure:"write_timeout"`
+	BreakerThreshold int           `mapstructure:"breaker_threshold"`
+	BreakerCooldown  time.Duration `mapstructure:"breaker_cooldown"`
 }
 
 type LoggerConfig struct {
-	Level       string `mapstructure:"level"`
-	Environment string `mapstructure:"environment"`
+	Level              string   `mapstructure:"level"`
+	Environment        string   `mapstructure:"environment"`
+	Encoding           string   `mapstructure:"encoding"`            //json or console, defaults to json
+	OutputPaths        []string `mapstructure:"output_paths"`        //stdout, stderr or file paths, defaults to stdout
+	SamplingInitial    int      `mapstructure:"sampling_initial"`    //Identical entries logged per second before sampling, 0 disables sampling
+	SamplingThereafter int      `mapstructure:"sampling_thereafter"` //After that, log every Nth identical entry
 }
 
 type TelemetryConfig struct {
-	ServiceName    string `mapstructure:"service_name"`
-	ServiceVersion string `mapstructure:"service_version"`
-	Environment    string `mapstructure:"environment"`
-	OTLPEndpoint   string `mapstructure:"otlp_endpoint"`
+	Enabled        bool    `mapstructure:"enabled"`
+	ServiceName    string  `mapstructure:"service_name"`
+	ServiceVersion string  `mapstructure:"service_version"`
+	Environment    string  `mapstructure:"environment"`
+	OTLPEndpoint   string  `mapstructure:"otlp_endpoint"`
+	SampleRatio    float64 `mapstructure:"sample_ratio"`
+}
+
+type AuthConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	Secret      string   `mapstructure:"secret"`
+	Issuer      string   `mapstructure:"issuer"`
+	PublicPaths []string `mapstructure:"public_paths"`
+}
+
+type CreditPolicyConfig struct {
+	AutoProvisionLimits  bool               `mapstructure:"auto_provision_limits"`
+	TenorMultipliers     map[int]float64    `mapstructure:"tenor_multipliers"` //Limit amount as a multiple of the monthly salary, keyed by tenor month
+	MinimumAge           int                `mapstructure:"minimum_age"`
+	MaxInterestRates     map[string]float64 `mapstructure:"max_interest_rates"`     //Interest rate cap in percent, keyed by asset category
+	MaxDebtToIncome      float64            `mapstructure:"max_debt_to_income"`     //Cap on total monthly installments as a fraction of salary, 0 disables the check
+	RequiredDocuments    []string           `mapstructure:"required_documents"`     //Document types a customer must have uploaded before financing
+	DefaultInterestRates map[int]float64    `mapstructure:"default_interest_rates"` //Interest rate in percent applied when a transaction omits one, keyed by tenor month
+	DefaultAdminFees     map[string]float64 `mapstructure:"default_admin_fees"`     //Admin fee applied when a transaction omits one, keyed by asset category
+	MaxAdminFee          float64            `mapstructure:"max_admin_fee"`          //Ceiling on the admin fee of a transaction, 0 disables it
+	AllowedTenors        []int              `mapstructure:"allowed_tenors"`         //Tenor months offered, empty keeps the default 1, 2, 3 and 6
+	RecalculateOnSalary  bool               `mapstructure:"recalculate_on_salary"`  //Recompute credit limits from tenor_multipliers when a customer's salary changes
 }
 
 func Load() (*Config, error) {
@@ -57,6 +131,8 @@ func Load() (*Config, error) {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("./config")
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
@@ -68,5 +144,120 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// Validate fills in safe defaults for optional settings and checks the
+// required ones, reporting every problem found in a single error.
+func (c *Config) Validate() error {
+	if c.MySQL.Port == 0 {
+		c.MySQL.Port = defaultMySQLPort
+	}
+	if c.MySQL.MaxOpenConns <= 0 {
+		c.MySQL.MaxOpenConns = defaultMySQLMaxOpenConns
+	}
+	if c.MySQL.MaxIdleConns <= 0 {
+		c.MySQL.MaxIdleConns = defaultMySQLMaxIdleConns
+	}
+	if c.Redis.Port == 0 {
+		c.Redis.Port = defaultRedisPort
+	}
+	if c.Redis.PoolSize <= 0 {
+		c.Redis.PoolSize = defaultRedisPoolSize
+	}
+	if c.Logger.Level == "" {
+		c.Logger.Level = defaultLogLevel
+	}
+
+	var problems []string
+	if c.App.Port <= 0 || c.App.Port > 65535 {
+		problems = append(problems, "app.port must be between 1 and 65535")
+	}
+	if c.App.LateFeeRate < 0 {
+		problems = append(problems, "app.late_fee_rate must not be negative")
+	}
+	if c.MySQL.Host == "" {
+		problems = append(problems, "mysql.host is required")
+	}
+	if c.MySQL.User == "" {
+		problems = append(problems, "mysql.user is required")
+	}
+	if c.MySQL.Database == "" {
+		problems = append(problems, "mysql.database is required")
+	}
+	if c.MySQL.MaxIdleConns > c.MySQL.MaxOpenConns {
+		problems = append(problems, "mysql.max_idle_conns must not exceed mysql.max_open_conns")
+	}
+	if c.Redis.Host == "" {
+		problems = append(problems, "redis.host is required")
+	}
+	if c.Redis.BreakerThreshold < 0 || c.Redis.BreakerCooldown < 0 {
+		problems = append(problems, "redis breaker values must not be negative")
+	}
+	if _, err := zapcore.ParseLevel(c.Logger.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("logger.level %q is not a valid log level", c.Logger.Level))
+	}
+	if c.Logger.SamplingInitial < 0 || c.Logger.SamplingThereafter < 0 {
+		problems = append(problems, "logger sampling values must not be negative")
+	}
+	if c.Logger.Encoding != "" && c.Logger.Encoding != "json" && c.Logger.Encoding != "console" {
+		problems = append(problems, fmt.Sprintf("logger.encoding %q must be json or console", c.Logger.Encoding))
+	}
+	if c.Telemetry.Enabled && c.Telemetry.OTLPEndpoint == "" {
+		problems = append(problems, "telemetry.otlp_endpoint is required when telemetry is enabled")
+	}
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		problems = append(problems, "telemetry.sample_ratio must be between 0 and 1")
+	}
+	if c.Auth.Enabled && c.Auth.Secret == "" {
+		problems = append(problems, "auth.secret is required when auth is enabled")
+	}
+	if c.CreditPolicy.MaxDebtToIncome < 0 || c.CreditPolicy.MaxDebtToIncome > 1 {
+		problems = append(problems, "credit_policy.max_debt_to_income must be between 0 and 1")
+	}
+	tenors := make([]int, 0, len(c.CreditPolicy.DefaultInterestRates))
+	for tenor := range c.CreditPolicy.DefaultInterestRates {
+		tenors = append(tenors, tenor)
+	}
+	sort.Ints(tenors)
+	for _, tenor := range tenors {
+		if rate := c.CreditPolicy.DefaultInterestRates[tenor]; rate < 0 || rate > 100 {
+			problems = append(problems, fmt.Sprintf("credit_policy.default_interest_rates[%d] must be between 0 and 100", tenor))
+		}
+	}
+	seenTenors := make(map[int]bool, len(c.CreditPolicy.AllowedTenors))
+	for _, tenor := range c.CreditPolicy.AllowedTenors {
+		if tenor <= 0 {
+			problems = append(problems, fmt.Sprintf("credit_policy.allowed_tenors contains invalid tenor %d", tenor))
+		} else if seenTenors[tenor] {
+			problems = append(problems, fmt.Sprintf("credit_policy.allowed_tenors lists tenor %d more than once", tenor))
+		}
+		seenTenors[tenor] = true
+	}
+	if c.CreditPolicy.MaxAdminFee < 0 {
+		problems = append(problems, "credit_policy.max_admin_fee must not be negative")
+	}
+	categories := make([]string, 0, len(c.CreditPolicy.DefaultAdminFees))
+	for category := range c.CreditPolicy.DefaultAdminFees {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		fee := c.CreditPolicy.DefaultAdminFees[category]
+		if fee < 0 {
+			problems = append(problems, fmt.Sprintf("credit_policy.default_admin_fees.%s must not be negative", category))
+		} else if c.CreditPolicy.MaxAdminFee > 0 && fee > c.CreditPolicy.MaxAdminFee {
+			problems = append(problems, fmt.Sprintf("credit_policy.default_admin_fees.%s must not exceed credit_policy.max_admin_fee", category))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
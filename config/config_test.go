@@ -0,0 +1,144 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+	"testing"
+)
+
+// loadFresh runs Load against config.yaml in this directory, resetting the
+// global viper state so one test's settings do not leak into the next.
+func loadFresh(t *testing.T) *Config {
+	t.Helper()
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	return config
+}
+
+func TestLoadReadsFileValues(t *testing.T) {
+	config := loadFresh(t)
+
+	if config.MySQL.Password != "pass2login" {
+		t.Errorf("mysql.password = %q, want the file value", config.MySQL.Password)
+	}
+	if config.Redis.Port != 6379 {
+		t.Errorf("redis.port = %d, want the file value", config.Redis.Port)
+	}
+}
+
+func TestLoadEnvOverridesFileValue(t *testing.T) {
+	t.Setenv("KP_MYSQL_PASSWORD", "from-env")
+	t.Setenv("KP_REDIS_PORT", "6380")
+	t.Setenv("KP_APP_WEBHOOK_SECRET", "webhook-secret")
+
+	config := loadFresh(t)
+
+	if config.MySQL.Password != "from-env" {
+		t.Errorf("mysql.password = %q, want KP_MYSQL_PASSWORD to win", config.MySQL.Password)
+	}
+	if config.Redis.Port != 6380 {
+		t.Errorf("redis.port = %d, want KP_REDIS_PORT to win", config.Redis.Port)
+	}
+	if config.App.Webhook.Secret != "webhook-secret" {
+		t.Errorf("app.webhook.secret = %q, want KP_APP_WEBHOOK_SECRET to win", config.App.Webhook.Secret)
+	}
+}
+
+func TestLoadIgnoresUnprefixedEnv(t *testing.T) {
+	t.Setenv("MYSQL_PASSWORD", "unprefixed")
+
+	config := loadFresh(t)
+
+	if config.MySQL.Password != "pass2login" {
+		t.Errorf("mysql.password = %q, want the file value", config.MySQL.Password)
+	}
+}
+
+// minimalConfig sets only the required settings so Validate has to fill in
+// every optional one.
+func minimalConfig() *Config {
+	return &Config{
+		App:   AppConfig{Port: 8080},
+		MySQL: MySQLConfig{Host: "localhost", User: "root", Database: "kredit_plus"},
+		Redis: RedisConfig{Host: "localhost"},
+	}
+}
+
+func TestValidateFillsDefaults(t *testing.T) {
+	config := minimalConfig()
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	if config.MySQL.Port != defaultMySQLPort {
+		t.Errorf("mysql.port = %d, want %d", config.MySQL.Port, defaultMySQLPort)
+	}
+	if config.MySQL.MaxOpenConns != defaultMySQLMaxOpenConns {
+		t.Errorf("mysql.max_open_conns = %d, want %d", config.MySQL.MaxOpenConns, defaultMySQLMaxOpenConns)
+	}
+	if config.MySQL.MaxIdleConns != defaultMySQLMaxIdleConns {
+		t.Errorf("mysql.max_idle_conns = %d, want %d", config.MySQL.MaxIdleConns, defaultMySQLMaxIdleConns)
+	}
+	if config.Redis.Port != defaultRedisPort {
+		t.Errorf("redis.port = %d, want %d", config.Redis.Port, defaultRedisPort)
+	}
+	if config.Redis.PoolSize != defaultRedisPoolSize {
+		t.Errorf("redis.pool_size = %d, want %d", config.Redis.PoolSize, defaultRedisPoolSize)
+	}
+	if config.Logger.Level != defaultLogLevel {
+		t.Errorf("logger.level = %q, want %q", config.Logger.Level, defaultLogLevel)
+	}
+}
+
+func TestValidateKeepsExplicitSettings(t *testing.T) {
+	config := minimalConfig()
+	config.MySQL.Port = 3307
+	config.Redis.PoolSize = 5
+	config.Logger.Level = "debug"
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	if config.MySQL.Port != 3307 {
+		t.Errorf("mysql.port = %d, want the explicit 3307", config.MySQL.Port)
+	}
+	if config.Redis.PoolSize != 5 {
+		t.Errorf("redis.pool_size = %d, want the explicit 5", config.Redis.PoolSize)
+	}
+	if config.Logger.Level != "debug" {
+		t.Errorf("logger.level = %q, want the explicit debug", config.Logger.Level)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := minimalConfig()
+	config.App.Port = 0
+	config.MySQL.Host = ""
+	config.Auth = AuthConfig{Enabled: true}
+	config.Telemetry.SampleRatio = 2
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate returned nil, want an error")
+	}
+
+	//All of them in one error, not just the first
+	for _, want := range []string{
+		"app.port must be between 1 and 65535",
+		"mysql.host is required",
+		"auth.secret is required when auth is enabled",
+		"telemetry.sample_ratio must be between 0 and 1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
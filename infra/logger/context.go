@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// ContextKey is the key the request-scoped logger is stored under. Fiber
+// handlers pass c.Context() down, which resolves values set with c.Locals, so
+// middleware can store the logger with c.Locals(ContextKey, logger).
+var ContextKey = contextKey{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, ContextKey, logger)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, falling back to
+// the global logger outside a request.
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(ContextKey).(*zap.Logger); ok {
+			return logger
+		}
+	}
+	return zap.L()
+}
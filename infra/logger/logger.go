@@ -6,22 +6,59 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// Config selects the log level and output. An empty Encoding means JSON and
+// empty OutputPaths means stdout; paths other than stdout/stderr are files.
+// Sampling is enabled when SamplingInitial is positive: per second, the first
+// SamplingInitial entries with the same level and message are logged and then
+// only every SamplingThereafter-th one.
 type Config struct {
-	Level       string
-	Environment string
+	Level              string
+	Environment        string
+	Encoding           string
+	OutputPaths        []string
+	SamplingInitial    int
+	SamplingThereafter int
 }
 
-func NewLogger(cfg Config) (*zap.Logger, error) {
+// NewLogger builds the logger together with its level, which can be changed
+// at runtime through the returned zap.AtomicLevel.
+func NewLogger(cfg Config) (*zap.Logger, zap.AtomicLevel, error) {
+	atomicLevel := zap.NewAtomicLevel()
 	level, err := zapcore.ParseLevel(cfg.Level)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse log level: %w", err)
+		return nil, atomicLevel, fmt.Errorf("failed to parse log level: %w", err)
+	}
+	atomicLevel.SetLevel(level)
+
+	encoding := cfg.Encoding
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+	if !IsValidEncoding(encoding) {
+		return nil, atomicLevel, fmt.Errorf("unsupported log encoding %q", encoding)
+	}
+
+	outputPaths := cfg.OutputPaths
+	if len(outputPaths) == 0 {
+		outputPaths = []string{"stdout"}
+	}
+
+	//Colored levels keep console output readable, JSON stays plain for log shippers
+	encodeLevel := zapcore.LowercaseLevelEncoder
+	if encoding == EncodingConsole {
+		encodeLevel = zapcore.CapitalColorLevelEncoder
 	}
 
 	config := zap.Config{
-		Level:            zap.NewAtomicLevelAt(level),
+		Level:            atomicLevel,
 		Development:      cfg.Environment == "development",
-		Encoding:         "json",
-		OutputPaths:      []string{"stdout"},
+		Encoding:         encoding,
+		OutputPaths:      outputPaths,
 		ErrorOutputPaths: []string{"stderr"},
 		EncoderConfig: zapcore.EncoderConfig{
 			TimeKey:        "timestamp",
@@ -31,20 +68,35 @@ func NewLogger(cfg Config) (*zap.Logger, error) {
 			MessageKey:     "message",
 			StacktraceKey:  "stacktrace",
 			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.LowercaseLevelEncoder,
+			EncodeLevel:    encodeLevel,
 			EncodeTime:     zapcore.ISO8601TimeEncoder,
 			EncodeDuration: zapcore.StringDurationEncoder,
 			EncodeCaller:   zapcore.ShortCallerEncoder,
 		},
 	}
 
+	if cfg.SamplingInitial > 0 {
+		thereafter := cfg.SamplingThereafter
+		if thereafter <= 0 {
+			thereafter = cfg.SamplingInitial
+		}
+		config.Sampling = &zap.SamplingConfig{
+			Initial:    cfg.SamplingInitial,
+			Thereafter: thereafter,
+		}
+	}
+
 	logger, err := config.Build(
 		zap.AddCallerSkip(1),
 		zap.AddStacktrace(zapcore.ErrorLevel),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build logger: %w", err)
+		return nil, atomicLevel, fmt.Errorf("failed to build logger: %w", err)
 	}
 
-	return logger, nil
+	return logger, atomicLevel, nil
+}
+
+func IsValidEncoding(encoding string) bool {
+	return encoding == EncodingJSON || encoding == EncodingConsole
 }
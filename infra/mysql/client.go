@@ -2,39 +2,60 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	mysqlDriver "github.com/go-sql-driver/mysql"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"gorm.io/plugin/opentelemetry/tracing"
+	"kredit-plus/internal/entity"
+	"math/rand/v2"
 	"time"
 )
 
+const (
+	DefaultMaxRetries = 3
+
+	retryBaseDelay = 50 * time.Millisecond
+
+	errCodeDuplicateEntry  = 1062
+	errCodeLockWaitTimeout = 1205
+	errCodeDeadlock        = 1213
+
+	queryTimeoutCallback  = "mysql:query_timeout"
+	queryTimeoutCancelKey = "mysql:query_timeout_cancel"
+)
+
 type Config struct {
-	Host         string
-	Port         int
-	User         string
-	Password     string
-	Database     string
-	MaxOpenConns int
-	MaxIdleConns int
-	MaxLifetime  time.Duration
-	Debug        bool
+	Host          string
+	Port          int
+	User          string
+	Password      string
+	Database      string
+	MaxOpenConns  int
+	MaxIdleConns  int
+	MaxLifetime   time.Duration
+	Debug         bool
+	AutoMigrate   bool
+	QueryTimeout  time.Duration
+	Replicas      []string
+	Tracing       bool
+	StatsInterval time.Duration
 }
 
 type Client struct {
-	db     *gorm.DB
-	logger *zap.Logger
+	db           *gorm.DB
+	logger       *zap.Logger
+	hasReplicas  bool
+	queryTimeout time.Duration
 }
 
 func NewClient(ctx context.Context, cfg Config, logger *zap.Logger) (*Client, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.User,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.Database,
-	)
+	dsn := buildDSN(cfg, fmt.Sprintf("%s:%d", cfg.Host, cfg.Port))
 
 	gormConfig := &gorm.Config{
 		Logger: NewGormLogger(logger),
@@ -61,21 +82,92 @@ func NewClient(ctx context.Context, cfg Config, logger *zap.Logger) (*Client, er
 		return nil, fmt.Errorf("failed to verify database connection: %w", err)
 	}
 
+	if len(cfg.Replicas) > 0 {
+		replicas := make([]gorm.Dialector, len(cfg.Replicas))
+		for i, addr := range cfg.Replicas {
+			replicas[i] = mysql.Open(buildDSN(cfg, addr))
+		}
+
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		}).
+			SetMaxOpenConns(cfg.MaxOpenConns).
+			SetMaxIdleConns(cfg.MaxIdleConns).
+			SetConnMaxLifetime(cfg.MaxLifetime)
+
+		if err := db.Use(resolver); err != nil {
+			return nil, fmt.Errorf("failed to register read replicas: %w", err)
+		}
+	}
+
+	//Query spans nest under the repository spans through the request context.
+	//Bind values are left out so customer data never reaches the trace backend.
+	if cfg.Tracing {
+		if err := db.Use(tracing.NewPlugin(
+			tracing.WithDBName(cfg.Database),
+			tracing.WithoutQueryVariables(),
+			tracing.WithoutMetrics(),
+		)); err != nil {
+			return nil, fmt.Errorf("failed to register query tracing: %w", err)
+		}
+	}
+
+	if cfg.QueryTimeout > 0 {
+		if err := registerQueryTimeout(db, cfg.QueryTimeout); err != nil {
+			return nil, fmt.Errorf("failed to register query timeout: %w", err)
+		}
+	}
+
 	if cfg.Debug {
 		db = db.Debug()
 	}
 
+	return &Client{
+		db:           db,
+		logger:       logger,
+		hasReplicas:  len(cfg.Replicas) > 0,
+		queryTimeout: cfg.QueryTimeout,
+	}, nil
+}
+
+// NewClientFromDB wraps an already opened connection, such as one backed by
+// sqlmock in tests.
+func NewClientFromDB(db *gorm.DB, logger *zap.Logger) *Client {
 	return &Client{
 		db:     db,
 		logger: logger,
-	}, nil
+	}
+}
+
+func buildDSN(cfg Config, addr string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User,
+		cfg.Password,
+		addr,
+		cfg.Database,
+	)
 }
 
 func (c *Client) DB() *gorm.DB {
 	return c.db
 }
 
+// WithContext always targets the primary, so reads right after a write (and
+// the cache backfills built on them) never see replication lag.
 func (c *Client) WithContext(ctx context.Context) *gorm.DB {
+	if c.hasReplicas {
+		return c.db.WithContext(ctx).Clauses(dbresolver.Write)
+	}
+	return c.db.WithContext(ctx)
+}
+
+// WithReplica targets a read replica when one is configured and the primary
+// otherwise. Use it only for reads that tolerate replication lag.
+func (c *Client) WithReplica(ctx context.Context) *gorm.DB {
+	if c.hasReplicas {
+		return c.db.WithContext(ctx).Clauses(dbresolver.Read)
+	}
 	return c.db.WithContext(ctx)
 }
 
@@ -84,11 +176,130 @@ func (c *Client) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) er
 	ctx, span := tr.Start(ctx, "mysql.transaction")
 	defer span.End()
 
+	if c.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.queryTimeout)
+		defer cancel()
+	}
+
 	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		return fn(tx)
 	})
 }
 
+// Migrate creates or alters the tables for every model. Models are listed
+// parents first so foreign keys always reference an existing table.
+func (c *Client) Migrate(ctx context.Context) error {
+	tr := otel.Tracer("gorm")
+	ctx, span := tr.Start(ctx, "mysql.migrate")
+	defer span.End()
+
+	if err := c.db.WithContext(ctx).AutoMigrate(
+		&entity.Asset{},
+		&entity.AssetPriceHistory{},
+		&entity.Customer{},
+		&entity.CustomerDocument{},
+		&entity.CreditLimit{},
+		&entity.CreditLimitLedger{},
+		&entity.Transaction{},
+		&entity.TransactionDetail{},
+	); err != nil {
+		c.logger.Error("failed to auto migrate database", zap.Error(err))
+		return fmt.Errorf("failed to auto migrate database: %w", err)
+	}
+
+	return nil
+}
+
+// TransactionWithRetry runs fn in a transaction and reruns it, up to
+// maxRetries more times, when MySQL aborts it with a deadlock or lock wait
+// timeout. Any other error is returned immediately. fn must be safe to rerun.
+func (c *Client) TransactionWithRetry(ctx context.Context, fn func(tx *gorm.DB) error, maxRetries int) error {
+	for attempt := 0; ; attempt++ {
+		err := c.Transaction(ctx, fn)
+		if err == nil || !IsRetryableError(err) || attempt >= maxRetries {
+			return err
+		}
+
+		backoff := retryBackoff(attempt)
+		c.logger.Warn("retrying transaction after transient mysql error",
+			zap.Error(err),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+		)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// IsRetryableError reports whether err is a deadlock or lock wait timeout.
+func IsRetryableError(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == errCodeDeadlock || mysqlErr.Number == errCodeLockWaitTimeout
+}
+
+// IsDuplicateKeyError reports whether err is a unique key violation.
+func IsDuplicateKeyError(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errCodeDuplicateEntry
+}
+
+// retryBackoff doubles the delay per attempt and adds up to 50% jitter so
+// competing transactions do not retry in lockstep.
+func retryBackoff(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	return delay + time.Duration(rand.Int64N(int64(delay)/2+1))
+}
+
+// registerQueryTimeout bounds every statement by timeout, unless its context
+// already has an earlier deadline, and releases the child context as soon as
+// the statement finishes. Row and Rows are left to the caller's context since
+// their results are read after the callbacks have run.
+func registerQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	begin := func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		db.Statement.Context = ctx
+		db.InstanceSet(queryTimeoutCancelKey, cancel)
+	}
+	end := func(db *gorm.DB) {
+		if cancel, ok := db.InstanceGet(queryTimeoutCancelKey); ok {
+			cancel.(context.CancelFunc)()
+		}
+	}
+
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register(queryTimeoutCallback+":begin", begin),
+		callbacks.Create().After("*").Register(queryTimeoutCallback+":end", end),
+		callbacks.Query().Before("*").Register(queryTimeoutCallback+":begin", begin),
+		callbacks.Query().After("*").Register(queryTimeoutCallback+":end", end),
+		callbacks.Update().Before("*").Register(queryTimeoutCallback+":begin", begin),
+		callbacks.Update().After("*").Register(queryTimeoutCallback+":end", end),
+		callbacks.Delete().Before("*").Register(queryTimeoutCallback+":begin", begin),
+		callbacks.Delete().After("*").Register(queryTimeoutCallback+":end", end),
+		callbacks.Raw().Before("*").Register(queryTimeoutCallback+":begin", begin),
+		callbacks.Raw().After("*").Register(queryTimeoutCallback+":end", end),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c *Client) Close() error {
 	sqlDB, err := c.db.DB()
 	if err != nil {
@@ -97,6 +308,15 @@ func (c *Client) Close() error {
 	return sqlDB.Close()
 }
 
+// Stats returns the connection pool statistics of the primary.
+func (c *Client) Stats() (sql.DBStats, error) {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return sql.DBStats{}, fmt.Errorf("failed to get database instance: %w", err)
+	}
+	return sqlDB.Stats(), nil
+}
+
 func (c *Client) Health(ctx context.Context) error {
 	sqlDB, err := c.db.DB()
 	if err != nil {
@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+func newMockClient(t *testing.T) (*Client, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet sql expectations: %v", err)
+		}
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+
+	return NewClientFromDB(db, zap.NewNop()), mock
+}
+
+func runStatement(tx *gorm.DB) error {
+	return tx.Exec("UPDATE credit_limits SET used_amount = used_amount + 1").Error
+}
+
+func TestTransactionWithRetryRetriesDeadlock(t *testing.T) {
+	client, mock := newMockClient(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE credit_limits").WillReturnError(&mysqlDriver.MySQLError{Number: errCodeDeadlock, Message: "Deadlock found when trying to get lock"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE credit_limits").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	attempts := 0
+	err := client.TransactionWithRetry(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		return runStatement(tx)
+	}, DefaultMaxRetries)
+	if err != nil {
+		t.Fatalf("TransactionWithRetry returned error: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("ran %d attempts, want 2", attempts)
+	}
+}
+
+func TestTransactionWithRetryGivesUpAfterMaxRetries(t *testing.T) {
+	client, mock := newMockClient(t)
+
+	lockWait := &mysqlDriver.MySQLError{Number: errCodeLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+	for i := 0; i <= 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE credit_limits").WillReturnError(lockWait)
+		mock.ExpectRollback()
+	}
+
+	attempts := 0
+	err := client.TransactionWithRetry(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		return runStatement(tx)
+	}, 2)
+	if !IsRetryableError(err) {
+		t.Fatalf("TransactionWithRetry returned %v, want the lock wait timeout", err)
+	}
+	if attempts != 3 {
+		t.Errorf("ran %d attempts, want 3", attempts)
+	}
+}
+
+func TestTransactionWithRetrySurfacesOtherErrors(t *testing.T) {
+	client, mock := newMockClient(t)
+
+	duplicate := &mysqlDriver.MySQLError{Number: errCodeDuplicateEntry, Message: "Duplicate entry"}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE credit_limits").WillReturnError(duplicate)
+	mock.ExpectRollback()
+
+	attempts := 0
+	err := client.TransactionWithRetry(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		return runStatement(tx)
+	}, DefaultMaxRetries)
+	if !errors.Is(err, duplicate) {
+		t.Fatalf("TransactionWithRetry returned %v, want the duplicate entry error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("ran %d attempts, want 1", attempts)
+	}
+}
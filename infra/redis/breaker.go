@@ -0,0 +1,125 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting Redis while the circuit
+// breaker is open. Callers treat it like any other cache failure.
+var ErrCircuitOpen = errors.New("redis circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// breaker opens after threshold consecutive failures so that cache calls fail
+// fast instead of each waiting on the Redis timeout. Once cooldown has passed
+// a single probe call is let through; its outcome closes or reopens the
+// circuit.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	logger    *zap.Logger
+}
+
+func newBreaker(threshold int, cooldown time.Duration, logger *zap.Logger) *breaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+		logger:    logger,
+	}
+}
+
+// allow reports whether a call may go to Redis. Every allowed call must be
+// followed by record.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a call. A missing key is a
+// successful round trip, and a call cancelled by its caller says nothing about
+// Redis, so neither counts as a failure.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil || errors.Is(err, redis.Nil) {
+		if b.state != BreakerClosed {
+			b.logger.Info("redis circuit breaker closed")
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		if b.state != BreakerOpen {
+			b.logger.Warn("redis circuit breaker opened",
+				zap.Int("consecutive_failures", b.failures),
+				zap.Duration("cooldown", b.cooldown),
+				zap.Error(err),
+			)
+		}
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+var errTimeout = errors.New("i/o timeout")
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	b := newBreaker(3, time.Minute, zap.NewNop())
+
+	for i := 0; i < 2; i++ {
+		b.allow()
+		b.record(errTimeout)
+	}
+	if state := b.State(); state != BreakerClosed {
+		t.Fatalf("breaker %s after 2 failures, want closed below the threshold", state)
+	}
+
+	b.allow()
+	b.record(errTimeout)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("breaker %s after 3 failures, want open", state)
+	}
+	if b.allow() {
+		t.Error("open breaker allowed a call during the cooldown")
+	}
+}
+
+func TestBreakerHalfOpenLetsOneProbeThrough(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		probeErr error
+		want     BreakerState
+	}{
+		{"probe succeeds", nil, BreakerClosed},
+		{"probe fails", errTimeout, BreakerOpen},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBreaker(1, 10*time.Millisecond, zap.NewNop())
+			b.allow()
+			b.record(errTimeout)
+
+			time.Sleep(20 * time.Millisecond)
+			if !b.allow() {
+				t.Fatal("breaker refused the probe after the cooldown")
+			}
+			if state := b.State(); state != BreakerHalfOpen {
+				t.Fatalf("breaker %s while probing, want half_open", state)
+			}
+			if b.allow() {
+				t.Fatal("breaker let a second call through while the probe was in flight")
+			}
+
+			b.record(tc.probeErr)
+			if state := b.State(); state != tc.want {
+				t.Errorf("breaker %s after the probe, want %s", state, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreakerIgnoresMissingKeysAndCancellation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		//A missing key is a successful round trip and resets the count; a
+		//cancelled call leaves it as it was
+		want BreakerState
+	}{
+		{"missing key", redis.Nil, BreakerClosed},
+		{"cancelled", context.Canceled, BreakerOpen},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBreaker(2, time.Minute, zap.NewNop())
+
+			b.allow()
+			b.record(errTimeout)
+			for i := 0; i < 5; i++ {
+				b.allow()
+				b.record(tc.err)
+			}
+			if state := b.State(); state != BreakerClosed {
+				t.Fatalf("breaker %s after %v, want it not counted as a failure", state, tc.err)
+			}
+
+			b.allow()
+			b.record(errTimeout)
+			if state := b.State(); state != tc.want {
+				t.Errorf("breaker %s after the next failure, want %s", state, tc.want)
+			}
+		})
+	}
+}
@@ -2,31 +2,64 @@ package redis
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	mathrand "math/rand/v2"
 	"time"
 )
 
+// TTLJitterFraction is how far SetWithJitter may move a TTL in either
+// direction, so entries written together do not all expire together.
+const TTLJitterFraction = 0.1
+
+// releaseLockScript deletes the lock only while it still holds the caller's
+// token, so an expired lock re-acquired by someone else is left untouched.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Config holds the connection settings. Zero pool and timeout values fall back
+// to the go-redis defaults.
 type Config struct {
-	Host     string
-	Port     int
-	Password string
-	DB       int
+	Host         string
+	Port         int
+	Password     string
+	DB           int
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	//Consecutive failures before cache calls are short-circuited, and how long
+	//they stay short-circuited before a probe. Zero uses the breaker defaults.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 type Client struct {
-	client *redis.Client
-	logger *zap.Logger
+	client  *redis.Client
+	breaker *breaker
+	logger  *zap.Logger
 }
 
 func NewClient(cfg Config, logger *zap.Logger) (*Client, error) {
 	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
+		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -37,8 +70,9 @@ func NewClient(cfg Config, logger *zap.Logger) (*Client, error) {
 	}
 
 	return &Client{
-		client: client,
-		logger: logger,
+		client:  client,
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, logger),
+		logger:  logger,
 	}, nil
 }
 
@@ -52,7 +86,12 @@ func (c *Client) Get(ctx context.Context, key string) (string, error) {
 		attribute.String("redis.operation", "GET"),
 	)
 
+	if !c.breaker.allow() {
+		return "", fmt.Errorf("failed to get key from redis: %w", ErrCircuitOpen)
+	}
+
 	val, err := c.client.Get(ctx, key).Result()
+	c.breaker.record(err)
 	if err != nil {
 		if err != redis.Nil {
 			c.logger.Error("failed to get key from redis",
@@ -76,7 +115,12 @@ func (c *Client) Set(ctx context.Context, key string, value interface{}, expirat
 		attribute.String("redis.operation", "SET"),
 	)
 
+	if !c.breaker.allow() {
+		return fmt.Errorf("failed to set key in redis: %w", ErrCircuitOpen)
+	}
+
 	err := c.client.Set(ctx, key, value, expiration).Err()
+	c.breaker.record(err)
 	if err != nil {
 		c.logger.Error("failed to set key in redis",
 			zap.String("key", key),
@@ -88,6 +132,62 @@ func (c *Client) Set(ctx context.Context, key string, value interface{}, expirat
 	return nil
 }
 
+// SetWithJitter behaves like Set with the expiration randomized by up to
+// TTLJitterFraction.
+func (c *Client) SetWithJitter(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.Set(ctx, key, value, JitterTTL(expiration))
+}
+
+// JitterTTL returns ttl shifted by a random amount within ±TTLJitterFraction.
+// Non-positive TTLs are returned unchanged.
+func JitterTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * TTLJitterFraction
+	return ttl + time.Duration((mathrand.Float64()*2-1)*spread)
+}
+
+// MGet fetches keys in one round trip. The result is aligned with keys, with an
+// empty string for every missing key.
+func (c *Client) MGet(ctx context.Context, keys ...string) ([]string, error) {
+	tr := otel.Tracer("redis")
+	ctx, span := tr.Start(ctx, "redis.mget")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.StringSlice("redis.keys", keys),
+		attribute.String("redis.operation", "MGET"),
+	)
+
+	values := make([]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	if !c.breaker.allow() {
+		return nil, fmt.Errorf("failed to get keys from redis: %w", ErrCircuitOpen)
+	}
+
+	results, err := c.client.MGet(ctx, keys...).Result()
+	c.breaker.record(err)
+	if err != nil {
+		c.logger.Error("failed to get keys from redis",
+			zap.Strings("keys", keys),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to get keys from redis: %w", err)
+	}
+
+	for i, result := range results {
+		if value, ok := result.(string); ok {
+			values[i] = value
+		}
+	}
+
+	return values, nil
+}
+
 func (c *Client) Del(ctx context.Context, keys ...string) error {
 	tr := otel.Tracer("redis")
 	ctx, span := tr.Start(ctx, "redis.del")
@@ -98,7 +198,12 @@ func (c *Client) Del(ctx context.Context, keys ...string) error {
 		attribute.String("redis.operation", "DEL"),
 	)
 
+	if !c.breaker.allow() {
+		return fmt.Errorf("failed to delete keys from redis: %w", ErrCircuitOpen)
+	}
+
 	err := c.client.Del(ctx, keys...).Err()
+	c.breaker.record(err)
 	if err != nil {
 		c.logger.Error("failed to delete keys from redis",
 			zap.Strings("keys", keys),
@@ -110,6 +215,155 @@ func (c *Client) Del(ctx context.Context, keys ...string) error {
 	return nil
 }
 
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	tr := otel.Tracer("redis")
+	ctx, span := tr.Start(ctx, "redis.exists")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("redis.key", key),
+		attribute.String("redis.operation", "EXISTS"),
+	)
+
+	if !c.breaker.allow() {
+		return false, fmt.Errorf("failed to check key existence in redis: %w", ErrCircuitOpen)
+	}
+
+	count, err := c.client.Exists(ctx, key).Result()
+	c.breaker.record(err)
+	if err != nil {
+		c.logger.Error("failed to check key existence in redis",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to check key existence in redis: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	tr := otel.Tracer("redis")
+	ctx, span := tr.Start(ctx, "redis.setnx")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("redis.key", key),
+		attribute.String("redis.operation", "SETNX"),
+	)
+
+	if !c.breaker.allow() {
+		return false, fmt.Errorf("failed to set key if not exists in redis: %w", ErrCircuitOpen)
+	}
+
+	ok, err := c.client.SetNX(ctx, key, value, expiration).Result()
+	c.breaker.record(err)
+	if err != nil {
+		c.logger.Error("failed to set key if not exists in redis",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to set key if not exists in redis: %w", err)
+	}
+
+	return ok, nil
+}
+
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	tr := otel.Tracer("redis")
+	ctx, span := tr.Start(ctx, "redis.incr")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("redis.key", key),
+		attribute.String("redis.operation", "INCR"),
+	)
+
+	if !c.breaker.allow() {
+		return 0, fmt.Errorf("failed to increment key in redis: %w", ErrCircuitOpen)
+	}
+
+	val, err := c.client.Incr(ctx, key).Result()
+	c.breaker.record(err)
+	if err != nil {
+		c.logger.Error("failed to increment key in redis",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return 0, fmt.Errorf("failed to increment key in redis: %w", err)
+	}
+
+	return val, nil
+}
+
+// Lock tries once to take an advisory lock on key for ttl. When the lock is
+// already held it returns acquired false without blocking. The returned
+// release func is always safe to call.
+func (c *Client) Lock(ctx context.Context, key string, ttl time.Duration) (release func(), acquired bool, err error) {
+	tr := otel.Tracer("redis")
+	ctx, span := tr.Start(ctx, "redis.lock")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("redis.key", key),
+		attribute.String("redis.operation", "LOCK"),
+	)
+
+	noop := func() {}
+
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return noop, false, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	acquired, err = c.SetNX(ctx, key, token, ttl)
+	if err != nil || !acquired {
+		return noop, false, err
+	}
+
+	release = func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := releaseLockScript.Run(releaseCtx, c.client, []string{key}, token).Err(); err != nil {
+			c.logger.Warn("failed to release redis lock",
+				zap.String("key", key),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return release, true, nil
+}
+
+func (c *Client) Health(ctx context.Context) error {
+	tr := otel.Tracer("redis")
+	ctx, span := tr.Start(ctx, "redis.ping")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("redis.operation", "PING"))
+
+	//Gated like any other call, so after the cooldown a health check can be
+	//the probe that closes the breaker
+	if !c.breaker.allow() {
+		return fmt.Errorf("failed to ping redis: %w", ErrCircuitOpen)
+	}
+
+	err := c.client.Ping(ctx).Err()
+	c.breaker.record(err)
+	if err != nil {
+		return fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return nil
+}
+
+// BreakerState reports whether cache calls are currently reaching Redis.
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
 func (c *Client) Close() error {
 	return c.client.Close()
 }
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+	"strconv"
+	"testing"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	port, err := strconv.Atoi(server.Port())
+	if err != nil {
+		t.Fatalf("invalid miniredis port: %v", err)
+	}
+	client, err := NewClient(Config{Host: server.Host(), Port: port}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, server
+}
+
+func TestMGetReturnsEmptyValuesForMissingKeysInOrder(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Set("asset:1", "first")
+	server.Set("asset:3", "third")
+
+	values, err := client.MGet(context.Background(), "asset:1", "asset:2", "asset:3", "asset:4")
+	if err != nil {
+		t.Fatalf("MGet returned error: %v", err)
+	}
+
+	want := []string{"first", "", "third", ""}
+	if len(values) != len(want) {
+		t.Fatalf("MGet returned %d values, want %d", len(values), len(want))
+	}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Errorf("values[%d] = %q, want %q", i, values[i], want[i])
+		}
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("missing keys left the breaker %s", state)
+	}
+}
+
+func TestMGetWithoutKeysSkipsRedis(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Close()
+
+	values, err := client.MGet(context.Background())
+	if err != nil {
+		t.Fatalf("MGet returned error: %v", err)
+	}
+	if len(values) != 0 {
+		t.Errorf("MGet returned %v, want no values", values)
+	}
+}
+
+func TestDelRemovesEveryKeyInOneCall(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Set("customer:1", "a")
+	server.Set("customer:1:documents", "b")
+	server.Set("customer:2", "c")
+
+	if err := client.Del(context.Background(), "customer:1", "customer:1:documents"); err != nil {
+		t.Fatalf("Del returned error: %v", err)
+	}
+
+	for _, key := range []string{"customer:1", "customer:1:documents"} {
+		if server.Exists(key) {
+			t.Errorf("key %s survived Del", key)
+		}
+	}
+	if !server.Exists("customer:2") {
+		t.Error("Del removed a key it was not given")
+	}
+}
+
+func TestHealthDoesNotBypassOpenBreaker(t *testing.T) {
+	client, _ := newTestClient(t)
+	for i := 0; i < DefaultBreakerThreshold; i++ {
+		client.breaker.record(errors.New("i/o timeout"))
+	}
+
+	if err := client.Health(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Health returned %v, want ErrCircuitOpen", err)
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Errorf("health check moved the breaker to %s during the cooldown", state)
+	}
+}
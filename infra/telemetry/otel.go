@@ -16,11 +16,15 @@ import (
 	"time"
 )
 
+const DefaultSampleRatio = 0.5
+
 type Config struct {
+	Enabled        bool
 	ServiceName    string
 	ServiceVersion string
 	Environment    string
 	OTLPEndpoint   string
+	SampleRatio    float64 //Fraction of root traces sampled, DefaultSampleRatio when unset
 }
 
 func InitTracer(ctx context.Context, cfg Config, logger *zap.Logger) (func(), error) {
@@ -28,6 +32,16 @@ func InitTracer(ctx context.Context, cfg Config, logger *zap.Logger) (func(), er
 		return nil, fmt.Errorf("logger cannot be nil")
 	}
 
+	//Disabled tracing keeps the global no-op provider
+	if !cfg.Enabled {
+		return func() {}, nil
+	}
+
+	sampleRatio := cfg.SampleRatio
+	if sampleRatio <= 0 {
+		sampleRatio = DefaultSampleRatio
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -65,7 +79,7 @@ func InitTracer(ctx context.Context, cfg Config, logger *zap.Logger) (func(), er
 		sdkTrace.WithBatcher(traceExporter),
 		sdkTrace.WithResource(res),
 		sdkTrace.WithSampler(sdkTrace.ParentBased(
-			sdkTrace.TraceIDRatioBased(0.5),
+			sdkTrace.TraceIDRatioBased(sampleRatio),
 		)),
 	)
 
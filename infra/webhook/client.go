@@ -0,0 +1,228 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+
+	DefaultTimeout    = 5 * time.Second
+	DefaultMaxRetries = 3
+	DefaultQueueSize  = 100
+
+	baseRetryDelay = 500 * time.Millisecond
+)
+
+// Config holds the outbound webhook settings. An empty URL disables delivery;
+// zero timeout, retry and queue values fall back to the defaults above and a
+// negative MaxRetries disables retrying.
+type Config struct {
+	URL        string
+	Secret     string
+	Timeout    time.Duration
+	MaxRetries int
+	QueueSize  int
+}
+
+// Envelope is the JSON body POSTed to the webhook URL.
+type Envelope struct {
+	ID     string          `json:"id"`
+	Event  string          `json:"event"`
+	SentAt time.Time       `json:"sent_at"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Client delivers events to a single webhook URL from a background worker, so
+// publishing never waits on the receiving endpoint. Deliveries that still fail
+// after MaxRetries, or that do not fit in the queue, are written to the error
+// log as dead letters.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+	queue      chan Envelope
+	done       chan struct{}
+	mu         sync.RWMutex
+	closed     bool
+	logger     *zap.Logger
+}
+
+func NewClient(cfg Config, logger *zap.Logger) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+
+	c := &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		queue:      make(chan Envelope, cfg.QueueSize),
+		done:       make(chan struct{}),
+		logger:     logger,
+	}
+
+	if c.Enabled() {
+		go c.run()
+	} else {
+		close(c.done)
+	}
+
+	return c
+}
+
+func (c *Client) Enabled() bool {
+	return c.cfg.URL != ""
+}
+
+// Publish queues event for delivery and returns immediately.
+func (c *Client) Publish(ctx context.Context, event string, payload any) {
+	if !c.Enabled() {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to marshal webhook payload",
+			zap.Error(err),
+			zap.String("event", event),
+		)
+		return
+	}
+
+	envelope := Envelope{
+		ID:     uuid.New().String(),
+		Event:  event,
+		SentAt: time.Now().UTC(),
+		Data:   data,
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		c.deadLetter(envelope, 0, fmt.Errorf("webhook client is closed"))
+		return
+	}
+
+	select {
+	case c.queue <- envelope:
+	default:
+		c.deadLetter(envelope, 0, fmt.Errorf("webhook queue is full"))
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries to finish,
+// giving up when ctx is done.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.Enabled() && !c.closed {
+		close(c.queue)
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook queue not drained: %w", ctx.Err())
+	}
+}
+
+// Sign returns the SignatureHeader value for body: "sha256=" followed by the
+// hex HMAC-SHA256 of body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+
+	for envelope := range c.queue {
+		c.deliver(envelope)
+	}
+}
+
+func (c *Client) deliver(envelope Envelope) {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		c.deadLetter(envelope, 0, fmt.Errorf("failed to marshal webhook envelope: %w", err))
+		return
+	}
+
+	attempts := c.cfg.MaxRetries + 1
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = c.post(envelope.Event, body); err == nil {
+			return
+		}
+
+		c.logger.Warn("webhook delivery attempt failed",
+			zap.Error(err),
+			zap.String("webhook_id", envelope.ID),
+			zap.String("event", envelope.Event),
+			zap.Int("attempt", attempt),
+		)
+
+		if attempt < attempts {
+			time.Sleep(baseRetryDelay << (attempt - 1))
+		}
+	}
+
+	c.deadLetter(envelope, attempts, err)
+}
+
+func (c *Client) post(event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if c.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(c.cfg.Secret, body))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *Client) deadLetter(envelope Envelope, attempts int, err error) {
+	c.logger.Error("webhook dead letter",
+		zap.Error(err),
+		zap.String("webhook_id", envelope.ID),
+		zap.String("event", envelope.Event),
+		zap.Int("attempts", attempts),
+		zap.ByteString("data", envelope.Data),
+	)
+}
@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "webhook-secret"
+
+type statusChange struct {
+	TransactionID string `json:"transaction_id"`
+	OldStatus     string `json:"old_status"`
+	NewStatus     string `json:"new_status"`
+}
+
+type receivedRequest struct {
+	body      []byte
+	signature string
+	event     string
+}
+
+// newReceiver starts a server that records every request and answers with the
+// next status from statuses, then 200 once they run out.
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, receivedRequest{
+			body:      body,
+			signature: r.Header.Get(SignatureHeader),
+			event:     r.Header.Get(EventHeader),
+		})
+		status := http.StatusOK
+		if len(received) <= len(statuses) {
+			status = statuses[len(received)-1]
+		}
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedRequest(nil), received...)
+	}
+}
+
+func closeClient(t *testing.T, client *Client) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+}
+
+func TestClientDeliversSignedPayload(t *testing.T) {
+	server, received := newReceiver(t)
+	client := NewClient(Config{URL: server.URL, Secret: testSecret}, zap.NewNop())
+
+	client.Publish(context.Background(), "transaction.status_changed", statusChange{
+		TransactionID: "3f1c6a52-8d0e-4b8e-9a57-2a7c1f3d9b10",
+		OldStatus:     "pending",
+		NewStatus:     "active",
+	})
+	closeClient(t, client)
+
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	request := requests[0]
+
+	if want := Sign(testSecret, request.body); request.signature != want {
+		t.Errorf("signature = %q, want %q", request.signature, want)
+	}
+	if request.event != "transaction.status_changed" {
+		t.Errorf("event header = %q", request.event)
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal(request.body, &envelope); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	var payload statusChange
+	if err := json.Unmarshal(envelope.Data, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if envelope.ID == "" || envelope.Event != "transaction.status_changed" || envelope.SentAt.IsZero() {
+		t.Errorf("envelope = %+v", envelope)
+	}
+	if payload.OldStatus != "pending" || payload.NewStatus != "active" {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestSignRejectsTamperedBody(t *testing.T) {
+	signature := Sign(testSecret, []byte(`{"new_status":"active"}`))
+
+	if Sign(testSecret, []byte(`{"new_status":"cancelled"}`)) == signature {
+		t.Error("different bodies produced the same signature")
+	}
+	if Sign("other-secret", []byte(`{"new_status":"active"}`)) == signature {
+		t.Error("different secrets produced the same signature")
+	}
+}
+
+func TestClientRetriesFailedDelivery(t *testing.T) {
+	server, received := newReceiver(t, http.StatusServiceUnavailable)
+	client := NewClient(Config{URL: server.URL, Secret: testSecret, MaxRetries: 1}, zap.NewNop())
+
+	client.Publish(context.Background(), "transaction.status_changed", statusChange{NewStatus: "completed"})
+	closeClient(t, client)
+
+	requests := received()
+	if len(requests) != 2 {
+		t.Fatalf("received %d requests, want 2", len(requests))
+	}
+	if string(requests[0].body) != string(requests[1].body) {
+		t.Error("retry sent a different body")
+	}
+}
+
+func TestClientDeadLettersAfterRetries(t *testing.T) {
+	server, received := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	core, logs := observer.New(zap.ErrorLevel)
+	client := NewClient(Config{URL: server.URL, Secret: testSecret, MaxRetries: -1}, zap.New(core))
+
+	client.Publish(context.Background(), "transaction.status_changed", statusChange{NewStatus: "cancelled"})
+	closeClient(t, client)
+
+	if got := len(received()); got != 1 {
+		t.Errorf("received %d requests, want 1 with retries disabled", got)
+	}
+	deadLetters := logs.FilterMessage("webhook dead letter").All()
+	if len(deadLetters) != 1 {
+		t.Fatalf("logged %d dead letters, want 1", len(deadLetters))
+	}
+	if attempts := deadLetters[0].ContextMap()["attempts"]; attempts != int64(1) {
+		t.Errorf("dead letter attempts = %v, want 1", attempts)
+	}
+}
+
+func TestClientPublishDoesNotWaitForEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	client := NewClient(Config{URL: server.URL}, zap.NewNop())
+
+	start := time.Now()
+	client.Publish(context.Background(), "transaction.status_changed", statusChange{NewStatus: "active"})
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Publish blocked for %v", elapsed)
+	}
+
+	close(release)
+	closeClient(t, client)
+}
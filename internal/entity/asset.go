@@ -5,50 +5,74 @@ import (
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"html"
 	"time"
 )
 
 type (
 	Asset struct {
-		ID           uuid.UUID     `gorm:"type:char(36);primary_key"`
-		Name         string        `gorm:"type:varchar(100);not null"`
-		Category     string        `gorm:"type:varchar(50);not null"` //In Ex Case : (white_goods, motor, mobil)
-		Description  string        `gorm:"type:text"`
-		Price        float64       `gorm:"type:decimal(15,2);not null"`
-		CreatedAt    time.Time     `gorm:"type:timestamp;not null"`
-		UpdatedAt    time.Time     `gorm:"type:timestamp;not null"`
-		Transactions []Transaction `gorm:"foreignKey:AssetID"`
+		ID           uuid.UUID      `gorm:"type:char(36);primary_key"`
+		Name         string         `gorm:"type:varchar(100);not null"`
+		Category     string         `gorm:"type:varchar(50);not null"` //In Ex Case : (white_goods, motor, mobil)
+		Description  string         `gorm:"type:text"`
+		Price        float64        `gorm:"type:decimal(15,2);not null"`
+		Stock        int            `gorm:"type:int;not null;default:0"`
+		Version      int            `gorm:"type:int;not null;default:0"` //Bumped on every update, guards against lost updates
+		CreatedAt    time.Time      `gorm:"type:timestamp;not null"`
+		UpdatedAt    time.Time      `gorm:"type:timestamp;not null"`
+		DeletedAt    gorm.DeletedAt `gorm:"index"` //Set when an asset with transactions is retired instead of removed
+		Transactions []Transaction  `gorm:"foreignKey:AssetID"`
+	}
+
+	// AssetPriceHistory records every change to an asset's price, written in
+	// the same database transaction as the update itself.
+	AssetPriceHistory struct {
+		ID        uuid.UUID `gorm:"type:char(36);primary_key"`
+		AssetID   uuid.UUID `gorm:"type:char(36);index;not null"`
+		OldPrice  float64   `gorm:"type:decimal(15,2);not null"`
+		NewPrice  float64   `gorm:"type:decimal(15,2);not null"`
+		ChangedAt time.Time `gorm:"type:timestamp;not null"`
 	}
 
 	AssetService interface {
 		Create(ctx context.Context, req CreateAssetRequest) (*AssetResponse, error)
 		GetByID(ctx context.Context, id uuid.UUID) (*AssetResponse, error)
 		GetAll(ctx context.Context, filter AssetFilterRequest) ([]AssetResponse, int64, error)
+		GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]AssetResponse, error)
 		Update(ctx context.Context, id uuid.UUID, req UpdateAssetRequest) (*AssetResponse, error)
 		Delete(ctx context.Context, id uuid.UUID) error
+		GetPriceHistory(ctx context.Context, id uuid.UUID, page, perPage int) ([]AssetPriceHistoryResponse, int64, error)
 	}
 
 	AssetRepository interface {
 		Create(ctx context.Context, asset *Asset) error
 		GetByID(ctx context.Context, id uuid.UUID) (*Asset, error)
 		GetAllWithFilter(ctx context.Context, filter AssetFilterRepository) (assets []Asset, count int64, err error)
-		Update(ctx context.Context, asset *Asset) error
+		GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*Asset, error)
+		Update(ctx context.Context, asset *Asset, updateStock bool) error
 		Delete(ctx context.Context, id uuid.UUID) error
+		GetPriceHistory(ctx context.Context, assetID uuid.UUID, limit, offset int) ([]AssetPriceHistory, int64, error)
 	}
 
 	AssetFilterRequest struct {
+		Name     string //Case-insensitive partial match
 		Category string
 		MinPrice float64
 		MaxPrice float64
+		SortBy   string
+		SortDir  string
 		Limit    int
 		Offset   int
 	}
 
 	AssetFilterRepository struct {
+		Name     string
 		Category string
 		MinPrice float64
 		MaxPrice float64
+		SortBy   string
+		SortDir  string
 		Limit    int
 		Offset   int
 	}
@@ -58,12 +82,20 @@ type (
 		Category    string  `json:"category" validate:"required,oneof=white_goods motor mobil"`
 		Description string  `json:"description" validate:"required"`
 		Price       float64 `json:"price" validate:"required,gt=0"`
+		Stock       int     `json:"stock" validate:"gte=0"`
 	}
 
 	UpdateAssetRequest struct {
 		Name        string  `json:"name" validate:"required,min=3,max=100"`
+		Category    string  `json:"category" validate:"omitempty,oneof=white_goods motor mobil"` //Optional, unchanged when empty
 		Description string  `json:"description" validate:"required"`
 		Price       float64 `json:"price" validate:"required,gt=0"`
+		Stock       *int    `json:"stock" validate:"omitempty,gte=0"` //Optional, sets the absolute stock level for restocking
+		Version     *int    `json:"version"`                          //Optional, the version the client last read; a mismatch is a stale write
+	}
+
+	BatchGetAssetsRequest struct {
+		IDs []uuid.UUID `json:"ids" validate:"required,min=1,max=100"`
 	}
 
 	AssetResponse struct {
@@ -72,23 +104,73 @@ type (
 		Category    string    `json:"category"`
 		Description string    `json:"description"`
 		Price       float64   `json:"price"`
+		Stock       int       `json:"stock"`
+		Version     int       `json:"version"`
 		CreatedAt   string    `json:"created_at"`
 		UpdatedAt   string    `json:"updated_at"`
+		DeletedAt   string    `json:"deleted_at,omitempty"`
+	}
+
+	AssetError struct {
+		Code    string
+		Message string
+	}
+
+	AssetPriceHistoryResponse struct {
+		ID        uuid.UUID `json:"id"`
+		AssetID   uuid.UUID `json:"asset_id"`
+		OldPrice  float64   `json:"old_price"`
+		NewPrice  float64   `json:"new_price"`
+		ChangedAt string    `json:"changed_at"`
 	}
 )
 
-func (req CreateAssetRequest) Validate() []string {
-	//XSS Protection
-	html.EscapeString(req.Name)
-	html.EscapeString(req.Description)
-	html.EscapeString(req.Category)
+var (
+	ErrAssetNotFound = &AssetError{Code: "ASSET_NOT_FOUND", Message: "asset not found"}
+)
 
+func (e *AssetError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *AssetError) ErrorCode() string {
+	return e.Code
+}
+
+// Retired reports whether the asset was soft deleted. Retired assets stay
+// readable by ID for the transactions that reference them but cannot be
+// updated or used for new transactions.
+func (a *Asset) Retired() bool {
+	return a.DeletedAt.Valid
+}
+
+const (
+	DefaultAssetSortBy  = "created_at"
+	DefaultAssetSortDir = "desc"
+	MaxAssetBatchSize   = 100
+)
+
+// assetSortColumns is the allowlist of columns an asset listing may be ordered by.
+var assetSortColumns = map[string]bool{
+	"name":       true,
+	"price":      true,
+	"created_at": true,
+}
+
+// Sanitize HTML-escapes the free-text fields so they are stored safe to render.
+// It runs before Validate so length limits apply to the stored values.
+func (req *CreateAssetRequest) Sanitize() {
+	req.Name = html.EscapeString(req.Name)
+	req.Description = html.EscapeString(req.Description)
+}
+
+func (req CreateAssetRequest) Validate() []string {
 	//Validate
 	var errors []string
 	err := validate.Struct(req)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
-			switch err.Field() {
+			switch err.StructField() {
 			case "Name":
 				errors = append(errors, fmt.Sprintf("name %s", err.Tag()))
 			case "Category":
@@ -97,40 +179,71 @@ func (req CreateAssetRequest) Validate() []string {
 				errors = append(errors, fmt.Sprintf("description %s", err.Tag()))
 			case "Price":
 				errors = append(errors, fmt.Sprintf("price must be greater than 0"))
+			case "Stock":
+				errors = append(errors, "stock must not be negative")
 			}
 		}
 	}
 	return errors
 }
 
-func (req UpdateAssetRequest) Validate() []string {
-	//XSS Protection
-	html.EscapeString(req.Name)
-	html.EscapeString(req.Description)
+// Sanitize HTML-escapes the free-text fields, see CreateAssetRequest.Sanitize.
+func (req *UpdateAssetRequest) Sanitize() {
+	req.Name = html.EscapeString(req.Name)
+	req.Description = html.EscapeString(req.Description)
+}
 
+func (req UpdateAssetRequest) Validate() []string {
 	//Validate
 	var errors []string
 	err := validate.Struct(req)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
-			switch err.Field() {
+			switch err.StructField() {
 			case "Name":
 				errors = append(errors, fmt.Sprintf("name %s", err.Tag()))
+			case "Category":
+				errors = append(errors, "category must be one of: white_goods, motor, mobil")
 			case "Description":
 				errors = append(errors, fmt.Sprintf("description %s", err.Tag()))
 			case "Price":
 				errors = append(errors, fmt.Sprintf("price must be greater than 0"))
+			case "Stock":
+				errors = append(errors, "stock must not be negative")
 			}
 		}
 	}
 	return errors
 }
 
+func (req AssetFilterRequest) Validate() []string {
+	var errors []string
+	if req.SortBy != "" && !assetSortColumns[req.SortBy] {
+		errors = append(errors, "sort_by must be one of: name, price, created_at")
+	}
+	if req.SortDir != "" && req.SortDir != "asc" && req.SortDir != "desc" {
+		errors = append(errors, "sort_dir must be either 'asc' or 'desc'")
+	}
+	return errors
+}
+
 func (req AssetFilterRequest) ToAssetFilterRepo() AssetFilterRepository {
+	sortBy := req.SortBy
+	if !assetSortColumns[sortBy] {
+		sortBy = DefaultAssetSortBy
+	}
+	sortDir := req.SortDir
+	if sortDir != "asc" {
+		sortDir = DefaultAssetSortDir
+	}
+
 	return AssetFilterRepository{
+		Name:     req.Name,
 		Category: req.Category,
 		MinPrice: req.MinPrice,
 		MaxPrice: req.MaxPrice,
+		SortBy:   sortBy,
+		SortDir:  sortDir,
 		Limit:    req.Limit,
 		Offset:   req.Offset,
 	}
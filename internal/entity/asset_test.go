@@ -0,0 +1,24 @@
+package entity
+
+import "testing"
+
+func TestAssetRequestSanitizeKeepsEscapedValues(t *testing.T) {
+	const (
+		name        = `<script>alert("x")</script>`
+		escapedName = `&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;`
+		description = `Fridge & "freezer"`
+		escapedDesc = `Fridge &amp; &#34;freezer&#34;`
+	)
+
+	create := CreateAssetRequest{Name: name, Description: description}
+	create.Sanitize()
+	if create.Name != escapedName || create.Description != escapedDesc {
+		t.Errorf("CreateAssetRequest.Sanitize left %q and %q", create.Name, create.Description)
+	}
+
+	update := UpdateAssetRequest{Name: name, Description: description}
+	update.Sanitize()
+	if update.Name != escapedName || update.Description != escapedDesc {
+		t.Errorf("UpdateAssetRequest.Sanitize left %q and %q", update.Name, update.Description)
+	}
+}
@@ -1,7 +1,44 @@
 package entity
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 const (
-	DefaultCacheTTL = 24 * time.Hour
+	DefaultCacheTTL     = 24 * time.Hour
+	IdempotencyCacheTTL = 24 * time.Hour
+	IdempotencyWaitTime = 10 * time.Second //How long a retry waits for the request holding its key
+	CreditLimitLockTTL  = 10 * time.Second
+	ReminderMarkerTTL   = 48 * time.Hour
 )
+
+// EventPublisher hands domain events to external subscribers. Publish must not
+// block on delivery.
+type EventPublisher interface {
+	Publish(ctx context.Context, event string, payload any)
+}
+
+// Clock is the source of the current time for services, so it can be fixed
+// when time-dependent behaviour needs to be reproduced.
+type Clock interface {
+	Now() time.Time
+}
+
+// StaleWriteError is returned when an update was based on an outdated version
+// of a record. Clients should re-fetch the record and retry.
+type StaleWriteError struct {
+	Code    string
+	Message string
+}
+
+var ErrStaleWrite = &StaleWriteError{Code: "STALE_WRITE", Message: "record was modified by another request, re-fetch and retry"}
+
+func (e *StaleWriteError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *StaleWriteError) ErrorCode() string {
+	return e.Code
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"github.com/google/uuid"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type (
 	CreditLimit struct {
 		ID          uuid.UUID `gorm:"type:char(36);primary_key"`
-		CustomerID  uuid.UUID `gorm:"type:char(36);index;not null"`
-		TenorMonth  int       `gorm:"type:int;not null"` //In Ex Case : (1, 2, 3, or 6 months)
+		CustomerID  uuid.UUID `gorm:"type:char(36);index;uniqueIndex:uniq_credit_limits_customer_tenor;not null"`
+		TenorMonth  int       `gorm:"type:int;uniqueIndex:uniq_credit_limits_customer_tenor;not null"` //One of the allowed tenors, see AllowedTenors
 		LimitAmount float64   `gorm:"type:decimal(15,2);not null"`
 		UsedAmount  float64   `gorm:"type:decimal(15,2);not null;default:0"`
 		CreatedAt   time.Time `gorm:"type:timestamp;not null"`
@@ -19,13 +23,45 @@ type (
 		Customer    Customer  `gorm:"foreignKey:CustomerID"`
 	}
 
+	// CreditLimitLedger records every change to a credit limit's used amount,
+	// written in the same database transaction as the change itself.
+	CreditLimitLedger struct {
+		ID            uuid.UUID `gorm:"type:char(36);primary_key"`
+		CreditLimitID uuid.UUID `gorm:"type:char(36);index;not null"`
+		Delta         float64   `gorm:"type:decimal(15,2);not null"`
+		Reason        string    `gorm:"type:varchar(255);not null"`
+		BalanceAfter  float64   `gorm:"type:decimal(15,2);not null"`
+		CreatedAt     time.Time `gorm:"type:timestamp;not null"`
+	}
+
+	// CreditPolicy drives customer eligibility and the default credit limits
+	// seeded for new customers.
+	CreditPolicy struct {
+		AutoProvisionLimits  bool
+		TenorMultipliers     map[int]float64
+		MinimumAge           int
+		MaxInterestRates     map[string]float64 //Percent, keyed by asset category
+		MaxDebtToIncome      float64            //Fraction of the monthly salary, 0 disables the check
+		RequiredDocuments    []string           //Document types required before financing, empty disables the check
+		DefaultInterestRates map[int]float64    //Percent, keyed by tenor month; used when a request omits the rate
+		DefaultAdminFees     map[string]float64 //Keyed by asset category; used when a request omits the fee
+		MaxAdminFee          float64            //0 disables the ceiling
+		AllowedTenors        []int              //Tenor months offered, empty means DefaultTenors
+		RecalculateOnSalary  bool               //Re-derive limits from TenorMultipliers when a customer's salary changes
+	}
+
 	CreditLimitService interface {
 		Create(ctx context.Context, req CreateCreditLimitRequest) (*CreditLimitResponse, error)
+		CreateIfAbsent(ctx context.Context, req CreateCreditLimitRequest) (limit *CreditLimitResponse, created bool, err error)
 		GetByID(ctx context.Context, id uuid.UUID) (*CreditLimitResponse, error)
 		GetByCustomerIDAndTenor(ctx context.Context, customerID uuid.UUID, tenorMonth int) (*CreditLimitResponse, error)
-		GetAllByCustomerID(ctx context.Context, customerID uuid.UUID) ([]CreditLimitResponse, error)
+		GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, page, perPage int) ([]CreditLimitResponse, int64, error)
+		GetAll(ctx context.Context, filter CreditLimitFilterRequest) ([]CreditLimitResponse, int64, error)
 		Delete(ctx context.Context, id uuid.UUID) error
-		UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64) error
+		UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64, reason string) error
+		UpdateLimitAmount(ctx context.Context, id uuid.UUID, newLimit float64) (*CreditLimitResponse, error)
+		GetLedger(ctx context.Context, id uuid.UUID, page, perPage int) ([]CreditLimitLedgerResponse, int64, error)
+		RecalculateUsedAmount(ctx context.Context, id uuid.UUID) (*CreditLimitRecalculation, error)
 	}
 
 	CreditLimitRepository interface {
@@ -33,24 +69,60 @@ type (
 		GetByID(ctx context.Context, id uuid.UUID) (*CreditLimit, error)
 		GetByCustomerIDAndTenor(ctx context.Context, customerID uuid.UUID, tenorMonth int) (*CreditLimit, error)
 		GetAllByCustomerID(ctx context.Context, customerID uuid.UUID) ([]CreditLimit, error)
-		UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64) error
+		GetAll(ctx context.Context, filter CreditLimitFilterRepository) (limits []CreditLimit, count int64, err error)
+		UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64, reason string) error
+		UpdateLimitAmount(ctx context.Context, id uuid.UUID, newLimit float64) (*CreditLimit, error)
 		Delete(ctx context.Context, id uuid.UUID) error
+		GetLedger(ctx context.Context, creditLimitID uuid.UUID, limit, offset int) ([]CreditLimitLedger, int64, error)
+		RecalculateUsedAmount(ctx context.Context, creditLimitID uuid.UUID) (*CreditLimitRecalculation, error)
+	}
+
+	CreditLimitFilterRepository struct {
+		TenorMonth     int
+		MinUtilization float64
+		Limit          int
+		Offset         int
 	}
 
 	CreateCreditLimitRequest struct {
 		CustomerID  uuid.UUID `json:"customer_id" validate:"required"`
-		TenorMonth  int       `json:"tenor_month" validate:"required,oneof=1 2 3 6"`
+		TenorMonth  int       `json:"tenor_month" validate:"required,tenor"`
 		LimitAmount float64   `json:"limit_amount" validate:"required,gt=0"`
 	}
 
+	CreditLimitFilterRequest struct {
+		TenorMonth     int     `json:"tenor_month"`
+		MinUtilization float64 `json:"min_utilization" validate:"min=0,max=100"`
+		Page           int     `json:"page" validate:"min=1"`
+		PerPage        int     `json:"per_page" validate:"min=1,max=100"`
+	}
+
 	CreditLimitResponse struct {
-		ID          uuid.UUID `json:"id"`
-		CustomerID  uuid.UUID `json:"customer_id"`
-		TenorMonth  int       `json:"tenor_month"`
-		LimitAmount float64   `json:"limit_amount"`
-		UsedAmount  float64   `json:"used_amount"`
-		CreatedAt   string    `json:"created_at"`
-		UpdatedAt   string    `json:"updated_at"`
+		ID                 uuid.UUID `json:"id"`
+		CustomerID         uuid.UUID `json:"customer_id"`
+		TenorMonth         int       `json:"tenor_month"`
+		LimitAmount        float64   `json:"limit_amount"`
+		UsedAmount         float64   `json:"used_amount"`
+		AvailableAmount    float64   `json:"available_amount"`
+		UtilizationPercent float64   `json:"utilization_percent"`
+		CreatedAt          string    `json:"created_at"`
+		UpdatedAt          string    `json:"updated_at"`
+	}
+
+	CreditLimitLedgerResponse struct {
+		ID            uuid.UUID `json:"id"`
+		CreditLimitID uuid.UUID `json:"credit_limit_id"`
+		Delta         float64   `json:"delta"`
+		Reason        string    `json:"reason"`
+		BalanceAfter  float64   `json:"balance_after"`
+		CreatedAt     string    `json:"created_at"`
+	}
+
+	CreditLimitRecalculation struct {
+		CreditLimitID      uuid.UUID `json:"credit_limit_id"`
+		PreviousUsedAmount float64   `json:"previous_used_amount"`
+		UsedAmount         float64   `json:"used_amount"`
+		Difference         float64   `json:"difference"`
 	}
 
 	CreditLimitError struct {
@@ -62,16 +134,11 @@ type (
 func (r *CreateCreditLimitRequest) Validate() []string {
 	var errors []string
 
-	isValidTenor := func(tenor int) bool {
-		validTenors := map[int]bool{1: true, 2: true, 3: true, 6: true}
-		return validTenors[tenor]
-	}
-
 	if r.CustomerID == uuid.Nil {
 		errors = append(errors, "customer_id is required")
 	}
-	if !isValidTenor(r.TenorMonth) {
-		errors = append(errors, "tenor_month must be 1, 2, 3, or 6")
+	if !IsAllowedTenor(r.TenorMonth) {
+		errors = append(errors, InvalidTenorMessage())
 	}
 	if r.LimitAmount <= 0 {
 		errors = append(errors, "limit_amount must be greater than 0")
@@ -80,13 +147,199 @@ func (r *CreateCreditLimitRequest) Validate() []string {
 	return errors
 }
 
+func (r CreditLimitFilterRequest) Validate() []string {
+	var errors []string
+	if r.TenorMonth != 0 && !IsAllowedTenor(r.TenorMonth) {
+		errors = append(errors, InvalidTenorMessage())
+	}
+	if r.MinUtilization < 0 || r.MinUtilization > 100 {
+		errors = append(errors, "min_utilization must be between 0 and 100")
+	}
+	if r.Page < 1 {
+		errors = append(errors, "page must be greater than 0")
+	}
+	if r.PerPage < 1 {
+		errors = append(errors, "per_page must be greater than 0")
+	}
+	if r.PerPage > 100 {
+		errors = append(errors, "per_page must not exceed 100")
+	}
+	return errors
+}
+
+func (r CreditLimitFilterRequest) ToCreditLimitFilterRepo() CreditLimitFilterRepository {
+	return CreditLimitFilterRepository{
+		TenorMonth:     r.TenorMonth,
+		MinUtilization: r.MinUtilization,
+		Limit:          r.PerPage,
+		Offset:         (r.Page - 1) * r.PerPage,
+	}
+}
+
+const (
+	LedgerReasonManualAdjustment = "manual adjustment"
+	LedgerReasonRecalculation    = "recalculation"
+	LedgerReasonSettlementPrefix = "early settlement of transaction "
+)
+
+func LedgerReasonTransaction(transactionID uuid.UUID) string {
+	return fmt.Sprintf("transaction %s", transactionID.String())
+}
+
+func LedgerReasonCancellation(transactionID uuid.UUID) string {
+	return fmt.Sprintf("cancellation of transaction %s", transactionID.String())
+}
+
+func LedgerReasonSettlement(transactionID uuid.UUID) string {
+	return LedgerReasonSettlementPrefix + transactionID.String()
+}
+
+// DefaultTenors are the tenor months offered when the credit policy does not
+// configure AllowedTenors.
+var DefaultTenors = []int{1, 2, 3, 6}
+
+var allowedTenors = DefaultTenors
+
+// SetAllowedTenors replaces the tenor months accepted by request validation,
+// falling back to DefaultTenors when tenors is empty. It is meant to be called
+// once at startup, before requests are served.
+func SetAllowedTenors(tenors []int) {
+	if len(tenors) == 0 {
+		allowedTenors = DefaultTenors
+		return
+	}
+
+	allowedTenors = append([]int(nil), tenors...)
+	sort.Ints(allowedTenors)
+}
+
+// AllowedTenors returns the tenor months accepted by request validation, in
+// ascending order.
+func AllowedTenors() []int {
+	return append([]int(nil), allowedTenors...)
+}
+
+func IsAllowedTenor(tenor int) bool {
+	for _, allowed := range allowedTenors {
+		if allowed == tenor {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidTenorMessage is the validation message for a tenor_month outside the
+// allowed set.
+func InvalidTenorMessage() string {
+	return fmt.Sprintf("tenor_month must be one of: %s", allowedTenorList())
+}
+
+func allowedTenorList() string {
+	tenors := make([]string, len(allowedTenors))
+	for i, tenor := range allowedTenors {
+		tenors[i] = strconv.Itoa(tenor)
+	}
+	return strings.Join(tenors, ", ")
+}
+
+// DefaultLimitAmount derives the limit for a tenor from the customer's monthly
+// salary. Tenors without a configured multiplier get no default limit.
+func (p CreditPolicy) DefaultLimitAmount(salary float64, tenorMonth int) float64 {
+	multiplier, ok := p.TenorMultipliers[tenorMonth]
+	if !ok || multiplier <= 0 || salary <= 0 {
+		return 0
+	}
+
+	return math.Round(salary*multiplier*100) / 100
+}
+
+// MaxInterestRate is the interest rate cap for an asset category, falling back
+// to MaxInterestRate percent for categories without a configured cap.
+func (p CreditPolicy) MaxInterestRate(category string) float64 {
+	if rate, ok := p.MaxInterestRates[category]; ok && rate > 0 {
+		return math.Min(rate, MaxInterestRate)
+	}
+
+	return MaxInterestRate
+}
+
+// ResolveInterestRate returns rate, or the default rate for tenorMonth when
+// rate is zero. It fails when neither is available.
+func (p CreditPolicy) ResolveInterestRate(rate float64, tenorMonth int) (float64, error) {
+	if rate != 0 {
+		return rate, nil
+	}
+	if defaultRate, ok := p.DefaultInterestRates[tenorMonth]; ok {
+		return defaultRate, nil
+	}
+
+	return 0, NewNoDefaultInterestRateError(tenorMonth)
+}
+
+// ResolveAdminFee returns fee, or the default fee for the asset category when
+// fee is zero. Categories without a default are charged no fee.
+func (p CreditPolicy) ResolveAdminFee(fee float64, category string) float64 {
+	if fee != 0 {
+		return fee
+	}
+
+	return p.DefaultAdminFees[category]
+}
+
+// MaxMonthlyInstallments is the most a customer with salary may pay in
+// installments per month across all open transactions. ok is false when the
+// debt-to-income check is disabled.
+func (p CreditPolicy) MaxMonthlyInstallments(salary float64) (amount float64, ok bool) {
+	if p.MaxDebtToIncome <= 0 {
+		return 0, false
+	}
+
+	return salary * p.MaxDebtToIncome, true
+}
+
+// RequiredDocumentTypes is the set of documents a customer must have uploaded
+// before a transaction can be financed.
+func (p CreditPolicy) RequiredDocumentTypes() []DocumentType {
+	types := make([]DocumentType, len(p.RequiredDocuments))
+	for i, documentType := range p.RequiredDocuments {
+		types[i] = DocumentType(documentType)
+	}
+
+	return types
+}
+
+// AvailableAmount is the part of the limit that can still be financed. It is
+// clamped at zero so inconsistent data never surfaces as a negative balance.
+func (l *CreditLimit) AvailableAmount() float64 {
+	return math.Max(l.LimitAmount-l.UsedAmount, 0)
+}
+
+// UtilizationPercent is the used amount as a percentage of the limit, rounded to
+// two decimals. A zero limit reports 0 when unused and 100 otherwise.
+func (l *CreditLimit) UtilizationPercent() float64 {
+	if l.LimitAmount <= 0 {
+		if l.UsedAmount > 0 {
+			return 100
+		}
+		return 0
+	}
+
+	return math.Round(l.UsedAmount/l.LimitAmount*100*100) / 100
+}
+
 func (e *CreditLimitError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Code, e.Message)
 }
 
+func (e *CreditLimitError) ErrorCode() string {
+	return e.Code
+}
+
 var (
 	ErrCreditLimitNotFound     = &CreditLimitError{Code: "CREDIT_LIMIT_NOT_FOUND", Message: "credit limit not found"}
 	ErrInsufficientCreditLimit = &CreditLimitError{Code: "INSUFFICIENT_CREDIT_LIMIT", Message: "insufficient credit limit"}
 	ErrDuplicateCreditLimit    = &CreditLimitError{Code: "DUPLICATE_CREDIT_LIMIT", Message: "credit limit already exists for this tenor"}
 	ErrCreditLimitInUse        = &CreditLimitError{Code: "CREDIT_LIMIT_IN_USE", Message: "credit limit is currently in use"}
+	ErrInvalidLimitAmount      = &CreditLimitError{Code: "INVALID_LIMIT_AMOUNT", Message: "limit amount must be greater than 0"}
+	ErrLimitBelowUsedAmount    = &CreditLimitError{Code: "LIMIT_BELOW_USED_AMOUNT", Message: "limit amount cannot be lower than the used amount"}
 )
@@ -2,7 +2,11 @@ package entity
 
 import (
 	"context"
+	"fmt"
 	"github.com/google/uuid"
+	"io"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -11,13 +15,16 @@ type (
 
 	Customer struct {
 		ID           uuid.UUID          `gorm:"type:char(36);primary_key"`
-		NIK          string             `gorm:"type:varchar(16);unique_index;not null"`
+		NIK          string             `gorm:"type:varchar(16);uniqueIndex;not null"`
 		FullName     string             `gorm:"type:varchar(100);not null"`
 		LegalName    string             `gorm:"type:varchar(100);not null"`
 		BirthPlace   string             `gorm:"type:varchar(100);not null"`
 		BirthDate    time.Time          `gorm:"type:date;not null"`
 		Salary       float64            `gorm:"type:decimal(15,2);not null"`
+		Email        string             `gorm:"type:varchar(100);not null;default:''"`
+		PhoneNumber  string             `gorm:"type:varchar(20);not null;default:''"`
 		IsActive     bool               `gorm:"type:boolean;default:true"`
+		Version      int                `gorm:"type:int;not null;default:0"` //Bumped on every update, guards against lost updates
 		CreatedAt    time.Time          `gorm:"type:timestamp;not null"`
 		UpdatedAt    time.Time          `gorm:"type:timestamp;not null"`
 		Documents    []CustomerDocument `gorm:"foreignKey:CustomerID"`
@@ -28,7 +35,7 @@ type (
 	CustomerDocument struct {
 		ID           uuid.UUID    `gorm:"type:char(36);primary_key"`
 		CustomerID   uuid.UUID    `gorm:"type:char(36);index;not null"`
-		DocumentType DocumentType `gorm:"type:varchar(50);not null;check:document_type in ('ktp', 'selfie')"`
+		DocumentType DocumentType `gorm:"type:varchar(50);not null;check:document_type in ('ktp', 'selfie', 'npwp', 'payslip')"`
 		DocumentURL  string       `gorm:"type:varchar(255);not null"`
 		CreatedAt    time.Time    `gorm:"type:timestamp;not null"`
 		UpdatedAt    time.Time    `gorm:"type:timestamp;not null"`
@@ -37,22 +44,43 @@ type (
 
 	CustomerService interface {
 		Create(ctx context.Context, req CreateCustomerRequest) (*CustomerResponse, error)
-		GetByID(ctx context.Context, id uuid.UUID) (*CustomerResponse, error)
+		GetByID(ctx context.Context, id uuid.UUID, includeStats bool) (*CustomerResponse, error)
 		GetByNIK(ctx context.Context, nik string) (*CustomerResponse, error)
 		Update(ctx context.Context, id uuid.UUID, req UpdateCustomerRequest) (*CustomerResponse, error)
 		Delete(ctx context.Context, id uuid.UUID) error
 		UploadDocument(ctx context.Context, customerID uuid.UUID, req UploadDocumentRequest) (*CustomerDocumentResponse, error)
 		GetDocuments(ctx context.Context, customerID uuid.UUID, filter DocumentFilterRequest) ([]CustomerDocumentResponse, int64, error)
+		List(ctx context.Context, filter CustomerFilterRequest) ([]CustomerResponse, int64, error)
+		Reactivate(ctx context.Context, id uuid.UUID) (*CustomerResponse, error)
+		DeleteDocument(ctx context.Context, customerID, documentID uuid.UUID) error
+		UpsertDocument(ctx context.Context, customerID uuid.UUID, req UploadDocumentRequest) (doc *CustomerDocumentResponse, created bool, err error)
+		BulkCreate(ctx context.Context, reqs []CreateCustomerRequest) (*BulkCustomerImportResponse, error)
+		Export(ctx context.Context, filter CustomerFilterRequest, w io.Writer) (int, error)
+		RecalculateLimitsFromSalary(ctx context.Context, customerID uuid.UUID) error
 	}
 
 	CustomerRepository interface {
 		Create(ctx context.Context, customer *Customer) error
-		GetByID(ctx context.Context, id uuid.UUID) (*Customer, error)
-		GetByNIK(ctx context.Context, nik string) (*Customer, error)
+		GetByID(ctx context.Context, id uuid.UUID, includeInactive bool) (*Customer, error)
+		GetByNIK(ctx context.Context, nik string, includeInactive bool) (*Customer, error)
 		Update(ctx context.Context, customer *Customer) error
 		Delete(ctx context.Context, id uuid.UUID) error
 		CreateDocument(ctx context.Context, doc *CustomerDocument) error
 		GetDocuments(ctx context.Context, filter DocumentFilterRepository) (documents []CustomerDocument, count int64, err error)
+		List(ctx context.Context, filter CustomerFilterRepository) (customers []Customer, count int64, err error)
+		StreamList(ctx context.Context, filter CustomerFilterRepository, fn func(customer *Customer) error) (int, error)
+		Reactivate(ctx context.Context, id uuid.UUID) (*Customer, error)
+		DeleteDocument(ctx context.Context, customerID, documentID uuid.UUID) error
+		UpsertDocument(ctx context.Context, doc *CustomerDocument) (created bool, err error)
+		DocumentsComplete(ctx context.Context, customerID uuid.UUID, required []DocumentType) (bool, error)
+		GetTransactionStats(ctx context.Context, customerID uuid.UUID) (*CustomerTransactionStats, error)
+	}
+
+	CustomerFilterRepository struct {
+		FullName string
+		IsActive *bool
+		Limit    int
+		Offset   int
 	}
 
 	DocumentFilterRepository struct {
@@ -63,24 +91,30 @@ type (
 	}
 
 	CreateCustomerRequest struct {
-		NIK        string    `json:"nik" validate:"required,len=16"`
-		FullName   string    `json:"full_name" validate:"required,max=100"`
-		LegalName  string    `json:"legal_name" validate:"required,max=100"`
-		BirthPlace string    `json:"birth_place" validate:"required"`
-		BirthDate  time.Time `json:"birth_date" validate:"required"`
-		Salary     float64   `json:"salary" validate:"required,min=0"`
+		NIK         string    `json:"nik" validate:"required,len=16"`
+		FullName    string    `json:"full_name" validate:"required,max=100"`
+		LegalName   string    `json:"legal_name" validate:"required,max=100"`
+		BirthPlace  string    `json:"birth_place" validate:"required"`
+		BirthDate   time.Time `json:"birth_date" validate:"required"`
+		Salary      float64   `json:"salary" validate:"required,min=0"`
+		Email       string    `json:"email" validate:"required,email,max=100"`
+		PhoneNumber string    `json:"phone_number" validate:"required"`
 	}
 
+	// UpdateCustomerRequest leaves Email and PhoneNumber unchanged when they are empty.
 	UpdateCustomerRequest struct {
-		FullName   string    `json:"full_name" validate:"required,max=100"`
-		LegalName  string    `json:"legal_name" validate:"required,max=100"`
-		BirthPlace string    `json:"birth_place" validate:"required"`
-		BirthDate  time.Time `json:"birth_date" validate:"required"`
-		Salary     float64   `json:"salary" validate:"required,min=0"`
+		FullName    string    `json:"full_name" validate:"required,max=100"`
+		LegalName   string    `json:"legal_name" validate:"required,max=100"`
+		BirthPlace  string    `json:"birth_place" validate:"required"`
+		BirthDate   time.Time `json:"birth_date" validate:"required"`
+		Salary      float64   `json:"salary" validate:"required,min=0"`
+		Email       string    `json:"email" validate:"omitempty,email,max=100"`
+		PhoneNumber string    `json:"phone_number"`
+		Version     *int      `json:"version"` //Optional, the version the client last read; a mismatch is a stale write
 	}
 
 	UploadDocumentRequest struct {
-		DocumentType DocumentType `json:"document_type" validate:"required,oneof=ktp selfie"`
+		DocumentType DocumentType `json:"document_type" validate:"required,oneof=ktp selfie npwp payslip"` //Keep in sync with documentTypes
 		DocumentURL  string       `json:"document_url" validate:"required,url"`
 	}
 
@@ -90,18 +124,37 @@ type (
 		PerPage      int           `json:"per_page" validate:"min=1,max=100"`
 	}
 
+	CustomerFilterRequest struct {
+		FullName string `json:"full_name" validate:"max=100"`
+		IsActive *bool  `json:"is_active"`
+		Page     int    `json:"page" validate:"min=1"`
+		PerPage  int    `json:"per_page" validate:"min=1,max=100"`
+	}
+
 	CustomerResponse struct {
-		ID         uuid.UUID                  `json:"id"`
-		NIK        string                     `json:"nik"`
-		FullName   string                     `json:"full_name"`
-		LegalName  string                     `json:"legal_name"`
-		BirthPlace string                     `json:"birth_place"`
-		BirthDate  string                     `json:"birth_date"` // Format: YYYY-MM-DD
-		Salary     float64                    `json:"salary"`
-		IsActive   bool                       `json:"is_active"`
-		Documents  []CustomerDocumentResponse `json:"documents,omitempty"`
-		CreatedAt  string                     `json:"created_at"` // RFC3339 format
-		UpdatedAt  string                     `json:"updated_at"` // RFC3339 format
+		ID          uuid.UUID                  `json:"id"`
+		NIK         string                     `json:"nik"`
+		FullName    string                     `json:"full_name"`
+		LegalName   string                     `json:"legal_name"`
+		BirthPlace  string                     `json:"birth_place"`
+		BirthDate   string                     `json:"birth_date"` // Format: YYYY-MM-DD
+		Salary      float64                    `json:"salary"`
+		Email       string                     `json:"email"`
+		PhoneNumber string                     `json:"phone_number"`
+		IsActive    bool                       `json:"is_active"`
+		Version     int                        `json:"version"`
+		Documents   []CustomerDocumentResponse `json:"documents,omitempty"`
+		Stats       *CustomerTransactionStats  `json:"stats,omitempty"` // Only with ?include=stats
+		CreatedAt   string                     `json:"created_at"`      // RFC3339 format
+		UpdatedAt   string                     `json:"updated_at"`      // RFC3339 format
+	}
+
+	// CustomerTransactionStats aggregates a customer's transactions. Cancelled
+	// transactions do not count towards the outstanding amount.
+	CustomerTransactionStats struct {
+		ActiveTransactions    int64   `json:"active_transactions"`
+		CompletedTransactions int64   `json:"completed_transactions"`
+		TotalOutstanding      float64 `json:"total_outstanding"`
 	}
 
 	CustomerDocumentResponse struct {
@@ -118,6 +171,28 @@ type (
 		Metadata  PaginationMetadata         `json:"metadata"`
 	}
 
+	// BulkCustomerResult reports the outcome of one row of a bulk import, by its
+	// position in the request array.
+	BulkCustomerResult struct {
+		Index     int               `json:"index"`
+		Success   bool              `json:"success"`
+		Customer  *CustomerResponse `json:"customer,omitempty"`
+		Error     string            `json:"error,omitempty"`
+		ErrorCode string            `json:"error_code,omitempty"`
+	}
+
+	BulkCustomerImportResponse struct {
+		Total     int                  `json:"total"`
+		Succeeded int                  `json:"succeeded"`
+		Failed    int                  `json:"failed"`
+		Results   []BulkCustomerResult `json:"results"`
+	}
+
+	CustomerError struct {
+		Code    string
+		Message string
+	}
+
 	PaginationMetadata struct {
 		CurrentPage int   `json:"current_page"`
 		PerPage     int   `json:"per_page"`
@@ -127,23 +202,70 @@ type (
 )
 
 const (
-	DocumentTypeKTP    DocumentType = "ktp"
-	DocumentTypeSelfie DocumentType = "selfie"
+	DefaultMinimumCustomerAge = 17
+	MaxBulkCustomerImport     = 500
+	MaxCustomerExportRows     = 10000
+)
+
+const (
+	DocumentTypeKTP     DocumentType = "ktp"
+	DocumentTypeSelfie  DocumentType = "selfie"
+	DocumentTypeNPWP    DocumentType = "npwp"
+	DocumentTypePayslip DocumentType = "payslip"
+)
+
+// documentTypes lists every accepted document type. A new type also needs the
+// UploadDocumentRequest oneof tag, the CustomerDocument check constraint and a
+// migration updating the database constraint.
+var documentTypes = []DocumentType{
+	DocumentTypeKTP,
+	DocumentTypeSelfie,
+	DocumentTypeNPWP,
+	DocumentTypePayslip,
+}
+
+var (
+	ErrCustomerNotFound      = &CustomerError{Code: "CUSTOMER_NOT_FOUND", Message: "customer not found"}
+	ErrCustomerAlreadyActive = &CustomerError{Code: "CUSTOMER_ALREADY_ACTIVE", Message: "customer is already active"}
+	ErrCustomerInactive      = &CustomerError{Code: "CUSTOMER_INACTIVE", Message: "customer is inactive"}
+	ErrDocumentNotFound      = &CustomerError{Code: "DOCUMENT_NOT_FOUND", Message: "document not found"}
+	ErrDuplicateNIK          = &CustomerError{Code: "DUPLICATE_NIK", Message: "customer with this NIK already exists"}
+	ErrDuplicateDocument     = &CustomerError{Code: "DUPLICATE_DOCUMENT", Message: "document type already exists for customer"}
+	ErrBulkImportTooLarge    = &CustomerError{Code: "BULK_IMPORT_TOO_LARGE", Message: "bulk import exceeds the maximum batch size"}
+	ErrBulkImportEmpty       = &CustomerError{Code: "BULK_IMPORT_EMPTY", Message: "bulk import contains no customers"}
 )
 
+func (e *CustomerError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *CustomerError) ErrorCode() string {
+	return e.Code
+}
+
 func (dt DocumentType) IsValid() bool {
-	switch dt {
-	case DocumentTypeKTP,
-		DocumentTypeSelfie:
-		return true
+	for _, documentType := range documentTypes {
+		if dt == documentType {
+			return true
+		}
 	}
 	return false
 }
 
-func (r CreateCustomerRequest) Validate() []string {
+// DocumentTypesMessage is the validation message listing the accepted types.
+func DocumentTypesMessage() string {
+	names := make([]string, len(documentTypes))
+	for i, documentType := range documentTypes {
+		names[i] = string(documentType)
+	}
+	return fmt.Sprintf("document_type must be one of: %s", strings.Join(names, ", "))
+}
+
+// Validate checks the request; now is the reference time for the age rule.
+func (r CreateCustomerRequest) Validate(minimumAge int, now time.Time) []string {
 	var errors []string
-	if len(r.NIK) != 16 {
-		errors = append(errors, "NIK must be 16 characters")
+	if err := ValidateNIK(r.NIK); err != nil {
+		errors = append(errors, err.Error())
 	}
 	if r.FullName == "" {
 		errors = append(errors, "full name is required")
@@ -162,14 +284,26 @@ func (r CreateCustomerRequest) Validate() []string {
 	}
 	if r.BirthDate.IsZero() {
 		errors = append(errors, "birth date is required")
+	} else {
+		errors = append(errors, validateBirthDate(r.BirthDate, minimumAge, now)...)
 	}
 	if r.Salary <= 0 {
 		errors = append(errors, "salary must be greater than 0")
 	}
+	if r.Email == "" {
+		errors = append(errors, "email is required")
+	} else if !IsValidEmail(r.Email) {
+		errors = append(errors, "email must be a valid email address")
+	}
+	if r.PhoneNumber == "" {
+		errors = append(errors, "phone number is required")
+	} else if !IsValidPhoneNumber(r.PhoneNumber) {
+		errors = append(errors, "phone number must be a valid Indonesian mobile number")
+	}
 	return errors
 }
 
-func (r UpdateCustomerRequest) Validate() []string {
+func (r UpdateCustomerRequest) Validate(minimumAge int, now time.Time) []string {
 	var errors []string
 	if r.FullName == "" {
 		errors = append(errors, "full name is required")
@@ -188,17 +322,101 @@ func (r UpdateCustomerRequest) Validate() []string {
 	}
 	if r.BirthDate.IsZero() {
 		errors = append(errors, "birth date is required")
+	} else {
+		errors = append(errors, validateBirthDate(r.BirthDate, minimumAge, now)...)
 	}
 	if r.Salary <= 0 {
 		errors = append(errors, "salary must be greater than 0")
 	}
+	if r.Email != "" && !IsValidEmail(r.Email) {
+		errors = append(errors, "email must be a valid email address")
+	}
+	if r.PhoneNumber != "" && !IsValidPhoneNumber(r.PhoneNumber) {
+		errors = append(errors, "phone number must be a valid Indonesian mobile number")
+	}
 	return errors
 }
 
+// ValidateNIK checks the structure of an Indonesian NIK: 16 digits made of a
+// 6 digit region code, the birth date as DDMMYY (day + 40 for women) and a 4
+// digit serial. Region codes are not checked against a registry, so any
+// non-zero code is accepted.
+func ValidateNIK(nik string) error {
+	if len(nik) != 16 {
+		return fmt.Errorf("NIK must be 16 digits")
+	}
+	for _, c := range nik {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("NIK must contain digits only")
+		}
+	}
+
+	if nik[0:2] == "00" || nik[2:4] == "00" || nik[4:6] == "00" {
+		return fmt.Errorf("NIK has an invalid region code")
+	}
+	if nik[12:16] == "0000" {
+		return fmt.Errorf("NIK has an invalid serial number")
+	}
+
+	day := int(nik[6]-'0')*10 + int(nik[7]-'0')
+	month := int(nik[8]-'0')*10 + int(nik[9]-'0')
+	if day > 40 {
+		day -= 40
+	}
+	// 2000 is a leap year, so 29 February is accepted for any birth year.
+	if month < 1 || month > 12 || day < 1 ||
+		day > time.Date(2000, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+		return fmt.Errorf("NIK has an invalid birth date segment")
+	}
+
+	return nil
+}
+
+var (
+	emailPattern       = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}$`)
+	phoneNumberPattern = regexp.MustCompile(`^(\+62|0)8\d{7,11}$`)
+)
+
+func IsValidEmail(email string) bool {
+	return len(email) <= 100 && emailPattern.MatchString(email)
+}
+
+// IsValidPhoneNumber accepts Indonesian mobile numbers in local (08...) or
+// international (+628...) form.
+func IsValidPhoneNumber(phoneNumber string) bool {
+	return phoneNumberPattern.MatchString(phoneNumber)
+}
+
+// CustomerAge returns the age in completed years at now.
+func CustomerAge(birthDate, now time.Time) int {
+	age := now.Year() - birthDate.Year()
+	if now.Month() < birthDate.Month() || (now.Month() == birthDate.Month() && now.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// validateBirthDate rejects future birth dates and applicants younger than
+// minimumAge, falling back to DefaultMinimumCustomerAge when it is not set.
+func validateBirthDate(birthDate time.Time, minimumAge int, now time.Time) []string {
+	if birthDate.After(now) {
+		return []string{"birth date cannot be in the future"}
+	}
+
+	if minimumAge <= 0 {
+		minimumAge = DefaultMinimumCustomerAge
+	}
+	if CustomerAge(birthDate, now) < minimumAge {
+		return []string{fmt.Sprintf("customer must be at least %d years old", minimumAge)}
+	}
+
+	return nil
+}
+
 func (r UploadDocumentRequest) Validate() []string {
 	var errors []string
 	if !r.DocumentType.IsValid() {
-		errors = append(errors, "invalid document type, must be either 'ktp' or 'selfie'")
+		errors = append(errors, DocumentTypesMessage())
 	}
 	if r.DocumentURL == "" {
 		errors = append(errors, "document URL is required")
@@ -234,3 +452,45 @@ func (r DocumentFilterRequest) ToDocumentFilterRepo(customerID uuid.UUID) Docume
 		Offset:       (r.Page - 1) * r.PerPage,
 	}
 }
+
+func (r CustomerFilterRequest) Validate() []string {
+	var errors []string
+	if len(r.FullName) > 100 {
+		errors = append(errors, "full name must not exceed 100 characters")
+	}
+	if r.Page < 1 {
+		errors = append(errors, "page must be greater than 0")
+	}
+	if r.PerPage < 1 {
+		errors = append(errors, "per_page must be greater than 0")
+	}
+	if r.PerPage > 100 {
+		errors = append(errors, "per_page must not exceed 100")
+	}
+	return errors
+}
+
+// ValidateExport checks only the filters, since exports are not paginated.
+func (r CustomerFilterRequest) ValidateExport() []string {
+	var errors []string
+	if len(r.FullName) > 100 {
+		errors = append(errors, "full name must not exceed 100 characters")
+	}
+	return errors
+}
+
+// ToCustomerFilterRepo defaults to active customers unless IsActive is set explicitly.
+func (r CustomerFilterRequest) ToCustomerFilterRepo() CustomerFilterRepository {
+	isActive := r.IsActive
+	if isActive == nil {
+		active := true
+		isActive = &active
+	}
+
+	return CustomerFilterRepository{
+		FullName: r.FullName,
+		IsActive: isActive,
+		Limit:    r.PerPage,
+		Offset:   (r.Page - 1) * r.PerPage,
+	}
+}
@@ -0,0 +1,114 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// FinancingRule names an eligibility rule checked when applying for financing.
+type FinancingRule string
+
+const (
+	FinancingRuleCustomerActive FinancingRule = "customer_active"
+	FinancingRuleKYCComplete    FinancingRule = "kyc_complete"
+	FinancingRuleAssetAvailable FinancingRule = "asset_available"
+	FinancingRuleAmount         FinancingRule = "amount"
+	FinancingRuleAdminFee       FinancingRule = "admin_fee"
+	FinancingRuleInterestRate   FinancingRule = "interest_rate"
+	FinancingRuleCreditLimit    FinancingRule = "credit_limit"
+	FinancingRuleDebtToIncome   FinancingRule = "debt_to_income"
+)
+
+type (
+	FinancingService interface {
+		Apply(ctx context.Context, req FinancingApplicationRequest) (*FinancingApplicationResponse, error)
+		Check(ctx context.Context, req FinancingApplicationRequest) (*FinancingCheckResponse, error)
+	}
+
+	// FinancingApplicationRequest asks to finance an asset for a customer in one
+	// call. Amount is what the customer wants financed and must cover the asset
+	// price; the remaining fields are passed on to the created transaction.
+	FinancingApplicationRequest struct {
+		CustomerID   uuid.UUID    `json:"customer_id" validate:"required"`
+		AssetID      uuid.UUID    `json:"asset_id" validate:"required"`
+		TenorMonth   int          `json:"tenor_month" validate:"required,tenor"`
+		Amount       float64      `json:"amount" validate:"gt=0"`
+		AdminFee     float64      `json:"admin_fee" validate:"min=0"`                              //Optional, defaults to the asset category's configured fee
+		InterestRate float64      `json:"interest_rate" validate:"min=0,max=100"`                  //Optional, defaults to the tenor's configured rate
+		InterestType InterestType `json:"interest_type" validate:"omitempty,oneof=flat effective"` //Defaults to flat
+		BillingDay   int          `json:"billing_day" validate:"omitempty,min=1,max=28"`
+	}
+
+	// FinancingRejection is one failed eligibility rule. Code is the error code
+	// the same failure carries on the transaction endpoints.
+	FinancingRejection struct {
+		Rule    FinancingRule `json:"rule"`
+		Code    string        `json:"code"`
+		Message string        `json:"message"`
+	}
+
+	// EligibilityRuleResult is the outcome of one eligibility rule. Code and
+	// Message are only set when the rule failed.
+	EligibilityRuleResult struct {
+		Rule    FinancingRule `json:"rule"`
+		Passed  bool          `json:"passed"`
+		Code    string        `json:"code,omitempty"`
+		Message string        `json:"message,omitempty"`
+	}
+
+	// FinancingCheckResponse previews a financing application without creating
+	// anything: the amounts the transaction would be created with and the
+	// outcome of every eligibility rule.
+	FinancingCheckResponse struct {
+		Eligible          bool                    `json:"eligible"`
+		OTRAmount         float64                 `json:"otr_amount"`
+		AdminFee          float64                 `json:"admin_fee"`
+		InterestAmount    float64                 `json:"interest_amount"`
+		TotalAmount       float64                 `json:"total_amount"`
+		InstallmentAmount float64                 `json:"installment_amount"`
+		Rules             []EligibilityRuleResult `json:"rules"`
+	}
+
+	FinancingApplicationResponse struct {
+		Approved    bool                 `json:"approved"`
+		Transaction *TransactionResponse `json:"transaction,omitempty"`
+		Rejections  []FinancingRejection `json:"rejections,omitempty"`
+	}
+)
+
+var (
+	ErrFinancingAmountBelowPrice = &TransactionError{Code: "FINANCING_AMOUNT_BELOW_PRICE", Message: "financing amount does not cover the asset price"}
+	ErrFinancingRejected         = &TransactionError{Code: "FINANCING_REJECTED", Message: "customer is not eligible for the requested financing"}
+)
+
+func NewFinancingAmountBelowPriceError(amount, price float64) error {
+	return &TransactionError{
+		Code:    ErrFinancingAmountBelowPrice.Code,
+		Message: fmt.Sprintf("financing amount %.2f does not cover the asset price %.2f", amount, price),
+	}
+}
+
+func (r FinancingApplicationRequest) Validate() []string {
+	errors := r.TransactionRequest().Validate()
+
+	if r.Amount <= 0 {
+		errors = append(errors, "amount must be greater than 0")
+	}
+
+	return errors
+}
+
+// TransactionRequest returns the transaction created when the application is
+// approved.
+func (r FinancingApplicationRequest) TransactionRequest() CreateTransactionRequest {
+	return CreateTransactionRequest{
+		CustomerID:   r.CustomerID,
+		AssetID:      r.AssetID,
+		TenorMonth:   r.TenorMonth,
+		AdminFee:     r.AdminFee,
+		InterestRate: r.InterestRate,
+		InterestType: r.InterestType,
+		BillingDay:   r.BillingDay,
+	}
+}
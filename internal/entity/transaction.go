@@ -2,32 +2,41 @@ package entity
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/google/uuid"
 	"html"
+	"io"
+	"math"
+	"strings"
 	"time"
 )
 
 type (
 	TransactionStatus       string
 	TransactionDetailStatus string
+	InterestType            string
 
 	Transaction struct {
-		ID                uuid.UUID          `gorm:"type:char(36);primary_key"`
-		CustomerID        uuid.UUID          `gorm:"type:char(36);index;not null"`
-		AssetID           uuid.UUID          `gorm:"type:char(36);index;not null"`
-		ContractNumber    string             `gorm:"type:varchar(50);unique_index;not null"`
-		OTRAmount         float64            `gorm:"type:decimal(15,2);not null"`
-		AdminFee          float64            `gorm:"type:decimal(15,2);not null"`
-		InterestAmount    float64            `gorm:"type:decimal(15,2);not null"`
-		TenorMonth        int                `gorm:"type:int;not null"`
-		InstallmentAmount float64            `gorm:"type:decimal(15,2);not null"`
-		Status            TransactionStatus  `gorm:"type:varchar(20);not null;check:status in ('pending', 'active', 'completed')"`
-		CreatedAt         time.Time          `gorm:"type:timestamp;not null"`
-		UpdatedAt         time.Time          `gorm:"type:timestamp;not null"`
-		Customer          *Customer          `gorm:"foreignKey:CustomerID"`
-		Asset             *Asset             `gorm:"foreignKey:AssetID"`
-		TransactionDetail *TransactionDetail `gorm:"foreignKey:TransactionID"`
+		ID                 uuid.UUID           `gorm:"type:char(36);primary_key"`
+		CustomerID         uuid.UUID           `gorm:"type:char(36);index;not null"`
+		AssetID            uuid.UUID           `gorm:"type:char(36);index;not null"`
+		ContractNumber     string              `gorm:"type:varchar(50);uniqueIndex;not null"`
+		OTRAmount          float64             `gorm:"type:decimal(15,2);not null"`
+		AdminFee           float64             `gorm:"type:decimal(15,2);not null"`
+		InterestAmount     float64             `gorm:"type:decimal(15,2);not null"`
+		TenorMonth         int                 `gorm:"type:int;not null"`
+		InstallmentAmount  float64             `gorm:"type:decimal(15,2);not null"` //For effective interest this is the first (largest) installment
+		InterestType       InterestType        `gorm:"type:varchar(20);not null;default:'flat';check:interest_type in ('flat', 'effective')"`
+		BillingDay         int                 `gorm:"type:tinyint;not null;default:0"` //Day of month installments fall due, 0 counts whole months from creation
+		Status             TransactionStatus   `gorm:"type:varchar(20);not null;check:status in ('pending', 'active', 'completed', 'cancelled')"`
+		CreatedAt          time.Time           `gorm:"type:timestamp;not null"`
+		UpdatedAt          time.Time           `gorm:"type:timestamp;not null"`
+		Customer           *Customer           `gorm:"foreignKey:CustomerID"`
+		Asset              *Asset              `gorm:"foreignKey:AssetID"`
+		TransactionDetails []TransactionDetail `gorm:"foreignKey:TransactionID"`
 	}
 
 	TransactionDetail struct {
@@ -35,6 +44,8 @@ type (
 		TransactionID     uuid.UUID               `gorm:"type:char(36);index;not null"`
 		InstallmentNumber int                     `gorm:"type:int;not null"`
 		Amount            float64                 `gorm:"type:decimal(15,2);not null"`
+		PaidAmount        float64                 `gorm:"type:decimal(15,2);not null;default:0"` //Accumulated partial payments, equals Amount plus LateFee once paid
+		LateFee           float64                 `gorm:"type:decimal(15,2);not null;default:0"`
 		DueDate           time.Time               `gorm:"type:date;not null"`
 		Status            TransactionDetailStatus `gorm:"type:varchar(20);not null;check:status in ('pending', 'paid', 'overdue')"`
 		CreatedAt         time.Time               `gorm:"type:timestamp;not null"`
@@ -45,37 +56,107 @@ type (
 		Create(ctx context.Context, req CreateTransactionRequest) (*TransactionResponse, error)
 		GetByID(ctx context.Context, id uuid.UUID) (*TransactionResponse, error)
 		GetByContractNumber(ctx context.Context, contractNumber string) (*TransactionResponse, error)
-		GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, filter TransactionFilterRequest) ([]TransactionResponse, int64, error)
+		GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, filter TransactionFilterRequest) (transactions []TransactionResponse, count int64, nextCursor string, err error)
+		ExportByCustomerID(ctx context.Context, customerID uuid.UUID, filter TransactionFilterRequest, w io.Writer) (int, error)
 		UpdateStatus(ctx context.Context, id uuid.UUID, status TransactionStatus) error
+		BatchUpdateStatus(ctx context.Context, req BatchUpdateStatusRequest) (*BatchUpdateStatusResponse, error)
+		PayInstallment(ctx context.Context, transactionID uuid.UUID, installmentNumber int, amount float64, allowOverpay bool) (*InstallmentResponse, error)
+		RunOverdueSweep(ctx context.Context, lateFeeRate float64) (int, error)
+		SendDueReminders(ctx context.Context, daysAhead int) (int, error)
+		Cancel(ctx context.Context, id uuid.UUID) error
+		ClaimIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) (*TransactionResponse, error)
+		GetInstallments(ctx context.Context, transactionID uuid.UUID, page, perPage int) ([]InstallmentResponse, int64, error)
+		GetCustomerSummary(ctx context.Context, customerID uuid.UUID) (*CustomerTransactionSummary, error)
+		GetPayoff(ctx context.Context, id uuid.UUID) (*PayoffResponse, error)
+		Settle(ctx context.Context, id uuid.UUID) (*PayoffResponse, error)
+		Preview(ctx context.Context, req TransactionPreviewRequest) (*TransactionPreviewResponse, error)
 	}
 
 	TransactionRepository interface {
-		Create(ctx context.Context, transaction *Transaction) error
+		Create(ctx context.Context, transaction *Transaction, schedule []float64) error
 		GetByID(ctx context.Context, id uuid.UUID) (*Transaction, error)
 		GetByContractNumber(ctx context.Context, contractNumber string) (*Transaction, error)
 		GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, filter TransactionFilterRepository) ([]Transaction, int64, error)
+		StreamByCustomerID(ctx context.Context, customerID uuid.UUID, filter TransactionFilterRepository, fn func(transaction *Transaction) error) (int, error)
 		UpdateStatus(ctx context.Context, id uuid.UUID, status TransactionStatus) error
+		BatchUpdateStatus(ctx context.Context, ids []uuid.UUID, status TransactionStatus) ([]BatchUpdateStatusResult, bool, error)
+		PayInstallment(ctx context.Context, transactionID uuid.UUID, installmentNumber int, amount float64, allowOverpay bool) (*TransactionDetail, TransactionStatus, error)
+		MarkOverdueInstallments(ctx context.Context, lateFeeRate float64) (int, error)
+		GetInstallmentsDueBetween(ctx context.Context, from, to time.Time) ([]TransactionDetail, error)
+		MarkReminderSent(ctx context.Context, installmentID uuid.UUID, day time.Time) (bool, error)
+		ClearReminderMarker(ctx context.Context, installmentID uuid.UUID, day time.Time) error
+		Cancel(ctx context.Context, id uuid.UUID) error
+		ReserveIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) (bool, error)
+		GetIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) (uuid.UUID, error)
+		SetIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string, transactionID uuid.UUID) error
+		ReleaseIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) error
+		LockCreditLimit(ctx context.Context, customerID uuid.UUID, tenorMonth int) (release func(), acquired bool, err error)
+		GetInstallments(ctx context.Context, transactionID uuid.UUID, limit, offset int) ([]TransactionDetail, int64, error)
+		GetCustomerSummary(ctx context.Context, customerID uuid.UUID) (*CustomerTransactionSummary, error)
+		SumOpenInstallmentAmounts(ctx context.Context, customerID uuid.UUID) (float64, error)
+		Settle(ctx context.Context, id uuid.UUID, quote PayoffQuoteFunc) (*PayoffResponse, error)
 	}
 
+	// ReminderNotifier delivers installment due reminders to customers.
+	ReminderNotifier interface {
+		NotifyInstallmentDue(ctx context.Context, reminder InstallmentReminder) error
+	}
+
+	InstallmentReminder struct {
+		TransactionID     uuid.UUID `json:"transaction_id"`
+		ContractNumber    string    `json:"contract_number"`
+		CustomerID        uuid.UUID `json:"customer_id"`
+		FullName          string    `json:"full_name"`
+		Email             string    `json:"email"`
+		PhoneNumber       string    `json:"phone_number"`
+		InstallmentNumber int       `json:"installment_number"`
+		AmountDue         float64   `json:"amount_due"`
+		DueDate           string    `json:"due_date"`
+	}
+
+	// PayoffQuoteFunc computes the early-settlement quote for the unpaid installments
+	// of a transaction. It is evaluated while the transaction row is locked.
+	PayoffQuoteFunc func(transaction *Transaction, unpaid []TransactionDetail) *PayoffResponse
+
 	TransactionFilterRepository struct {
-		Status TransactionStatus
-		Limit  int
-		Offset int
+		Status      TransactionStatus
+		CreatedFrom *time.Time //Inclusive lower bound on created_at
+		CreatedTo   *time.Time //Exclusive upper bound on created_at
+		SortBy      string
+		SortDir     string
+		Limit       int
+		Offset      int
+		Cursor      *TransactionCursor //When set, seeks past the cursor instead of using Offset
+	}
+
+	// TransactionCursor identifies the last transaction of a page for keyset
+	// pagination on (created_at, id).
+	TransactionCursor struct {
+		CreatedAt time.Time
+		ID        uuid.UUID
 	}
 
 	CreateTransactionRequest struct {
-		CustomerID     uuid.UUID `json:"customer_id" validate:"required"`
-		AssetID        uuid.UUID `json:"asset_id" validate:"required"`
-		TenorMonth     int       `json:"tenor_month" validate:"required,oneof=1 2 3 6"`
-		AdminFee       float64   `json:"admin_fee" validate:"required,min=0"`
-		InterestRate   float64   `json:"interest_rate" validate:"required,min=0,max=100"`
-		ContractNumber string    `json:"contract_number" validate:"required"`
+		CustomerID     uuid.UUID    `json:"customer_id" validate:"required"`
+		AssetID        uuid.UUID    `json:"asset_id" validate:"required"`
+		TenorMonth     int          `json:"tenor_month" validate:"required,tenor"`
+		AdminFee       float64      `json:"admin_fee" validate:"min=0"`                              //Optional, defaults to the asset category's configured fee
+		InterestRate   float64      `json:"interest_rate" validate:"min=0,max=100"`                  //Optional, defaults to the tenor's configured rate
+		InterestType   InterestType `json:"interest_type" validate:"omitempty,oneof=flat effective"` //Defaults to flat
+		ContractNumber string       `json:"contract_number" validate:"omitempty,max=50"`             //Optional, generated as KP-YYYYMMDD-<8 hex chars> when empty
+		BillingDay     int          `json:"billing_day" validate:"omitempty,min=1,max=28"`           //Optional, anchors every due date to this day of month
+		IdempotencyKey string       `json:"-"`                                                       //Populated from the Idempotency-Key header
 	}
 
 	TransactionFilterRequest struct {
-		Status  TransactionStatus `json:"status"`
-		Page    int               `json:"page" validate:"min=1"`
-		PerPage int               `json:"per_page" validate:"min=1,max=100"`
+		Status    TransactionStatus `json:"status"`
+		StartDate string            `json:"start_date"` //Format: YYYY-MM-DD, inclusive
+		EndDate   string            `json:"end_date"`   //Format: YYYY-MM-DD, inclusive
+		SortBy    string            `json:"sort_by" validate:"omitempty,oneof=created_at installment_amount tenor_month"`
+		SortDir   string            `json:"sort_dir" validate:"omitempty,oneof=asc desc"`
+		Page      int               `json:"page" validate:"min=1"`
+		PerPage   int               `json:"per_page" validate:"min=1,max=100"`
+		Cursor    string            `json:"cursor"` //Opaque next_cursor of a previous page; enables cursor mode
 	}
 
 	TransactionResponse struct {
@@ -88,6 +169,8 @@ type (
 		InterestAmount    float64               `json:"interest_amount"`
 		TenorMonth        int                   `json:"tenor_month"`
 		InstallmentAmount float64               `json:"installment_amount"`
+		InterestType      InterestType          `json:"interest_type"`
+		BillingDay        int                   `json:"billing_day,omitempty"`
 		Status            TransactionStatus     `json:"status"`
 		Asset             AssetResponse         `json:"asset,omitempty"`
 		Customer          CustomerResponse      `json:"customer,omitempty"`
@@ -101,22 +184,135 @@ type (
 		TransactionID     uuid.UUID               `json:"transaction_id"`
 		InstallmentNumber int                     `json:"installment_number"`
 		Amount            float64                 `json:"amount"`
+		PaidAmount        float64                 `json:"paid_amount"`
+		RemainingAmount   float64                 `json:"remaining_amount"`
+		LateFee           float64                 `json:"late_fee"`
 		DueDate           string                  `json:"due_date"`
 		Status            TransactionDetailStatus `json:"status"`
+		TransactionStatus TransactionStatus       `json:"transaction_status,omitempty"`
 		CreatedAt         string                  `json:"created_at"`
 		UpdatedAt         string                  `json:"updated_at"`
 	}
 
+	CustomerTransactionSummary struct {
+		CustomerID            uuid.UUID `json:"customer_id"`
+		TotalFinanced         float64   `json:"total_financed"`
+		TotalPaid             float64   `json:"total_paid"`
+		TotalOutstanding      float64   `json:"total_outstanding"`
+		OverdueInstallments   int64     `json:"overdue_installments"`
+		ActiveTransactions    int64     `json:"active_transactions"`
+		CompletedTransactions int64     `json:"completed_transactions"`
+	}
+
+	PayoffResponse struct {
+		TransactionID         uuid.UUID `json:"transaction_id"`
+		RemainingInstallments int       `json:"remaining_installments"`
+		RemainingPrincipal    float64   `json:"remaining_principal"`
+		AccruedInterest       float64   `json:"accrued_interest"`
+		LateFees              float64   `json:"late_fees"`
+		WaivedInterest        float64   `json:"waived_interest"`
+		SettlementAmount      float64   `json:"settlement_amount"`
+	}
+
+	// TransactionPreviewRequest describes a financing whose installment schedule
+	// should be computed without creating a transaction.
+	TransactionPreviewRequest struct {
+		OTRAmount    float64      `json:"otr_amount" validate:"gt=0"`
+		TenorMonth   int          `json:"tenor_month" validate:"required,tenor"`
+		AdminFee     float64      `json:"admin_fee" validate:"min=0"`
+		InterestRate float64      `json:"interest_rate" validate:"min=0,max=100"` //Optional, defaults to the tenor's configured rate
+		InterestType InterestType `json:"interest_type" validate:"omitempty,oneof=flat effective"`
+		BillingDay   int          `json:"billing_day" validate:"omitempty,min=1,max=28"`
+	}
+
+	TransactionPreviewResponse struct {
+		OTRAmount      float64              `json:"otr_amount"`
+		AdminFee       float64              `json:"admin_fee"`
+		InterestRate   float64              `json:"interest_rate"`
+		InterestType   InterestType         `json:"interest_type"`
+		InterestAmount float64              `json:"interest_amount"`
+		TotalAmount    float64              `json:"total_amount"`
+		TenorMonth     int                  `json:"tenor_month"`
+		Installments   []InstallmentPreview `json:"installments"`
+	}
+
+	// InstallmentPreview is one installment of a previewed schedule.
+	// RemainingBalance is what is still owed once it has been paid.
+	InstallmentPreview struct {
+		InstallmentNumber int     `json:"installment_number"`
+		Amount            float64 `json:"amount"`
+		DueDate           string  `json:"due_date"`
+		RemainingBalance  float64 `json:"remaining_balance"`
+	}
+
+	BatchUpdateStatusRequest struct {
+		IDs    []uuid.UUID       `json:"ids" validate:"required,min=1,max=100"`
+		Status TransactionStatus `json:"status" validate:"required"`
+	}
+
+	// BatchUpdateStatusResult reports the outcome of one ID of a batch status
+	// update, in request order.
+	BatchUpdateStatusResult struct {
+		TransactionID  uuid.UUID         `json:"transaction_id"`
+		Success        bool              `json:"success"`
+		PreviousStatus TransactionStatus `json:"previous_status,omitempty"`
+		Error          string            `json:"error,omitempty"`
+		ErrorCode      string            `json:"error_code,omitempty"`
+	}
+
+	// BatchUpdateStatusResponse is all-or-nothing: Applied is false, and no
+	// transaction was changed, whenever any result failed.
+	BatchUpdateStatusResponse struct {
+		Status    TransactionStatus         `json:"status"`
+		Applied   bool                      `json:"applied"`
+		Total     int                       `json:"total"`
+		Succeeded int                       `json:"succeeded"`
+		Failed    int                       `json:"failed"`
+		Results   []BatchUpdateStatusResult `json:"results"`
+	}
+
 	TransactionError struct {
 		Code    string
 		Message string
 	}
+
+	// TransactionStatusChangedEvent is published after a status transition commits.
+	TransactionStatusChangedEvent struct {
+		TransactionID uuid.UUID         `json:"transaction_id"`
+		OldStatus     TransactionStatus `json:"old_status"`
+		NewStatus     TransactionStatus `json:"new_status"`
+		Timestamp     time.Time         `json:"timestamp"`
+	}
 )
 
+const EventTransactionStatusChanged = "transaction.status_changed"
+
 const (
 	TransactionStatusPending   TransactionStatus = "pending"
 	TransactionStatusActive    TransactionStatus = "active"
 	TransactionStatusCompleted TransactionStatus = "completed"
+	TransactionStatusCancelled TransactionStatus = "cancelled"
+)
+
+const (
+	DefaultTransactionSortBy  = "created_at"
+	DefaultTransactionSortDir = "desc"
+	MaxTransactionExportRows  = 10000
+	MaxBatchStatusUpdate      = 100
+	MaxBillingDay             = 28    //Every month has this day, so anchored due dates never skip a month
+	MaxInterestRate           = 100.0 //Percent; the bound for asset categories without a configured cap
+)
+
+// transactionSortColumns is the allowlist of columns a transaction listing may be ordered by.
+var transactionSortColumns = map[string]bool{
+	"created_at":         true,
+	"installment_amount": true,
+	"tenor_month":        true,
+}
+
+const (
+	InterestTypeFlat      InterestType = "flat"
+	InterestTypeEffective InterestType = "effective"
 )
 
 const (
@@ -129,12 +325,76 @@ func (s TransactionStatus) IsValid() bool {
 	switch s {
 	case TransactionStatusPending,
 		TransactionStatusActive,
-		TransactionStatusCompleted:
+		TransactionStatusCompleted,
+		TransactionStatusCancelled:
 		return true
 	}
 	return false
 }
 
+// NewContractNumber mints a contract number in the format KP-YYYYMMDD-<8 hex chars>,
+// e.g. KP-20241203-9f86d081.
+func NewContractNumber(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate contract number: %w", err)
+	}
+	return fmt.Sprintf("KP-%s-%s", now.Format("20060102"), hex.EncodeToString(suffix)), nil
+}
+
+// TotalAmount is the amount financed: OTR plus admin fee plus interest.
+func (t *Transaction) TotalAmount() float64 {
+	return t.OTRAmount + t.AdminFee + t.InterestAmount
+}
+
+func (t InterestType) IsValid() bool {
+	switch t {
+	case InterestTypeFlat,
+		InterestTypeEffective:
+		return true
+	}
+	return false
+}
+
+// transactionStatusTransitions lists the statuses each status may move to.
+var transactionStatusTransitions = map[TransactionStatus][]TransactionStatus{
+	TransactionStatusPending: {TransactionStatusActive, TransactionStatusCancelled},
+	TransactionStatusActive:  {TransactionStatusCompleted},
+}
+
+func (s TransactionStatus) CanTransitionTo(next TransactionStatus) bool {
+	for _, allowed := range transactionStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether the transaction still has a running schedule, i.e.
+// it accepts payments and its installments can fall overdue.
+func (s TransactionStatus) IsOpen() bool {
+	return s == TransactionStatusPending || s == TransactionStatusActive
+}
+
+// AfterInstallmentPaid derives the transaction status once an installment is paid,
+// given the number of installments still pending or overdue.
+func (s TransactionStatus) AfterInstallmentPaid(remaining int64) TransactionStatus {
+	if remaining == 0 {
+		return TransactionStatusCompleted
+	}
+	if s == TransactionStatusPending {
+		return TransactionStatusActive
+	}
+	return s
+}
+
+// RemainingAmount is what is still owed on the installment, including its
+// late fee.
+func (d TransactionDetail) RemainingAmount() float64 {
+	return math.Max(math.Round((d.Amount+d.LateFee-d.PaidAmount)*100)/100, 0)
+}
+
 func (s TransactionDetailStatus) IsValid() bool {
 	switch s {
 	case TransactionDetailStatusPending,
@@ -145,39 +405,85 @@ func (s TransactionDetailStatus) IsValid() bool {
 	return false
 }
 
+// Sanitize HTML-escapes the client supplied contract number so it is stored
+// safe to render. It runs before Validate so the length limit applies to the
+// stored value.
+func (r *CreateTransactionRequest) Sanitize() {
+	r.ContractNumber = html.EscapeString(r.ContractNumber)
+}
+
 func (r CreateTransactionRequest) Validate() []string {
 	var errors []string
 
-	// XSS Protection
-	html.EscapeString(r.ContractNumber)
-
-	isValidTenor := func(tenor int) bool {
-		validTenors := map[int]bool{1: true, 2: true, 3: true, 6: true}
-		return validTenors[tenor]
-	}
-
 	if r.CustomerID == uuid.Nil {
 		errors = append(errors, "customer_id is required")
 	}
 	if r.AssetID == uuid.Nil {
 		errors = append(errors, "asset_id is required")
 	}
-	if !isValidTenor(r.TenorMonth) {
-		errors = append(errors, "tenor_month must be 1, 2, 3, or 6")
+	if !IsAllowedTenor(r.TenorMonth) {
+		errors = append(errors, InvalidTenorMessage())
+	}
+	if r.AdminFee < 0 {
+		errors = append(errors, "admin_fee must not be negative")
+	}
+	if r.InterestRate < 0 || r.InterestRate > MaxInterestRate {
+		errors = append(errors, "interest_rate must be between 0 and 100")
+	}
+	if r.InterestType != "" && !r.InterestType.IsValid() {
+		errors = append(errors, "interest_type must be either 'flat' or 'effective'")
+	}
+	if len(r.ContractNumber) > 50 {
+		errors = append(errors, "contract_number must not exceed 50 characters")
+	}
+	if r.BillingDay < 0 || r.BillingDay > MaxBillingDay {
+		errors = append(errors, fmt.Sprintf("billing_day must be between 1 and %d", MaxBillingDay))
+	}
+
+	return errors
+}
+
+func (r TransactionPreviewRequest) Validate() []string {
+	var errors []string
+
+	if r.OTRAmount <= 0 {
+		errors = append(errors, "otr_amount must be greater than 0")
+	}
+	if !IsAllowedTenor(r.TenorMonth) {
+		errors = append(errors, InvalidTenorMessage())
 	}
 	if r.AdminFee < 0 {
 		errors = append(errors, "admin_fee must not be negative")
 	}
-	if r.InterestRate < 0 || r.InterestRate > 100 {
+	if r.InterestRate < 0 || r.InterestRate > MaxInterestRate {
 		errors = append(errors, "interest_rate must be between 0 and 100")
 	}
-	if r.ContractNumber == "" {
-		errors = append(errors, "contract_number is required")
+	if r.InterestType != "" && !r.InterestType.IsValid() {
+		errors = append(errors, "interest_type must be either 'flat' or 'effective'")
+	}
+	if r.BillingDay < 0 || r.BillingDay > MaxBillingDay {
+		errors = append(errors, fmt.Sprintf("billing_day must be between 1 and %d", MaxBillingDay))
 	}
 
 	return errors
 }
 
+// InstallmentDueDate is the due date of installment number n (1-based) for a
+// transaction created at start. Without a billing day it is n months after
+// start; with one it is that day of the nth month after start's month, moved
+// to the month's last day when the month is shorter.
+func InstallmentDueDate(start time.Time, n, billingDay int) time.Time {
+	start = start.UTC()
+	if billingDay <= 0 {
+		return start.AddDate(0, n, 0)
+	}
+
+	firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+
+	return firstOfMonth.AddDate(0, 0, min(billingDay, lastDay)-1)
+}
+
 func (r TransactionFilterRequest) Validate() []string {
 	var errors []string
 
@@ -190,27 +496,210 @@ func (r TransactionFilterRequest) Validate() []string {
 	if r.PerPage > 100 {
 		errors = append(errors, "per_page must not exceed 100")
 	}
+	errors = append(errors, r.validateFilters()...)
+	if r.SortBy != "" && !transactionSortColumns[r.SortBy] {
+		errors = append(errors, "sort_by must be one of: created_at, installment_amount, tenor_month")
+	}
+	if r.SortDir != "" && r.SortDir != "asc" && r.SortDir != "desc" {
+		errors = append(errors, "sort_dir must be either 'asc' or 'desc'")
+	}
+	if r.Cursor != "" {
+		if _, err := DecodeTransactionCursor(r.Cursor); err != nil {
+			errors = append(errors, "invalid cursor")
+		}
+		if r.SortBy != "" && r.SortBy != DefaultTransactionSortBy {
+			errors = append(errors, "cursor pagination only supports sort_by=created_at")
+		}
+	}
+
+	return errors
+}
+
+// ValidateExport checks only the filters an export honors; paging and sorting
+// do not apply to exports.
+func (r TransactionFilterRequest) ValidateExport() []string {
+	return r.validateFilters()
+}
+
+func (r TransactionFilterRequest) validateFilters() []string {
+	var errors []string
+
 	if r.Status != "" && !r.Status.IsValid() {
 		errors = append(errors, "invalid status")
 	}
 
+	start, startErr := parseFilterDate(r.StartDate)
+	if startErr != nil {
+		errors = append(errors, "start_date must be in YYYY-MM-DD format")
+	}
+	end, endErr := parseFilterDate(r.EndDate)
+	if endErr != nil {
+		errors = append(errors, "end_date must be in YYYY-MM-DD format")
+	}
+	if start != nil && end != nil && start.After(*end) {
+		errors = append(errors, "start_date must not be after end_date")
+	}
+
 	return errors
 }
 
+// parseFilterDate parses an optional YYYY-MM-DD filter value, returning nil when empty.
+func parseFilterDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &date, nil
+}
+
 func (r TransactionFilterRequest) ToTransactionFilterRepo() TransactionFilterRepository {
-	return TransactionFilterRepository{
-		Status: r.Status,
-		Limit:  r.PerPage,
-		Offset: (r.Page - 1) * r.PerPage,
+	sortBy := r.SortBy
+	if !transactionSortColumns[sortBy] {
+		sortBy = DefaultTransactionSortBy
+	}
+	sortDir := r.SortDir
+	if sortDir != "asc" {
+		sortDir = DefaultTransactionSortDir
+	}
+
+	filter := TransactionFilterRepository{
+		Status:  r.Status,
+		SortBy:  sortBy,
+		SortDir: sortDir,
+		Limit:   r.PerPage,
+		Offset:  (r.Page - 1) * r.PerPage,
 	}
+	if start, err := parseFilterDate(r.StartDate); err == nil && start != nil {
+		filter.CreatedFrom = start
+	}
+	if end, err := parseFilterDate(r.EndDate); err == nil && end != nil {
+		nextDay := end.AddDate(0, 0, 1)
+		filter.CreatedTo = &nextDay
+	}
+	if cursor, err := DecodeTransactionCursor(r.Cursor); err == nil && sortBy == DefaultTransactionSortBy {
+		filter.Cursor = cursor
+		filter.Offset = 0
+	}
+
+	return filter
+}
+
+// Encode renders the cursor as the opaque token handed out as next_cursor.
+func (c TransactionCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeTransactionCursor(token string) (*TransactionCursor, error) {
+	if token == "" {
+		return nil, fmt.Errorf("empty cursor")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor format")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor id: %w", err)
+	}
+
+	return &TransactionCursor{CreatedAt: createdAt, ID: id}, nil
 }
 
 var (
 	ErrTransactionNotFound = &TransactionError{Code: "TRANSACTION_NOT_FOUND", Message: "transaction not found"}
 	ErrDuplicateContract   = &TransactionError{Code: "DUPLICATE_CONTRACT", Message: "contract number already exists"}
 	ErrInvalidStatus       = &TransactionError{Code: "INVALID_STATUS", Message: "invalid transaction status"}
+
+	ErrBatchStatusUpdateTooLarge = &TransactionError{Code: "BATCH_STATUS_UPDATE_TOO_LARGE", Message: "batch status update exceeds the maximum batch size"}
+	ErrBatchStatusUpdateEmpty    = &TransactionError{Code: "BATCH_STATUS_UPDATE_EMPTY", Message: "batch status update contains no transactions"}
+	ErrBatchCancelNotSupported   = &TransactionError{Code: "BATCH_CANCEL_NOT_SUPPORTED", Message: "transactions must be cancelled one at a time"}
+	ErrBatchStatusUpdateRejected = &TransactionError{Code: "BATCH_STATUS_UPDATE_REJECTED", Message: "one or more transactions cannot be updated, no changes were applied"}
+
+	ErrInstallmentNotFound    = &TransactionError{Code: "INSTALLMENT_NOT_FOUND", Message: "installment not found"}
+	ErrInstallmentAlreadyPaid = &TransactionError{Code: "INSTALLMENT_ALREADY_PAID", Message: "installment is already paid"}
+	ErrInvalidPaymentAmount   = &TransactionError{Code: "INVALID_PAYMENT_AMOUNT", Message: "payment amount must be greater than zero"}
+	ErrInstallmentOverpayment = &TransactionError{Code: "INSTALLMENT_OVERPAYMENT", Message: "payment exceeds the remaining amount due"}
+	ErrTransactionNotPayable  = &TransactionError{Code: "TRANSACTION_NOT_PAYABLE", Message: "transaction is not accepting payments"}
+
+	ErrTransactionNotCancellable = &TransactionError{Code: "TRANSACTION_NOT_CANCELLABLE", Message: "transaction can no longer be cancelled"}
+	ErrInvalidStatusTransition   = &TransactionError{Code: "INVALID_STATUS_TRANSITION", Message: "invalid transaction status transition"}
+	ErrTransactionNotSettleable  = &TransactionError{Code: "TRANSACTION_NOT_SETTLEABLE", Message: "transaction has no outstanding balance to settle"}
+	ErrTransactionHasPayments    = &TransactionError{Code: "TRANSACTION_HAS_PAYMENTS", Message: "transaction has paid installments"}
+
+	ErrAssetOutOfStock        = &TransactionError{Code: "ASSET_OUT_OF_STOCK", Message: "asset is out of stock"}
+	ErrInterestRateExceedsCap = &TransactionError{Code: "INTEREST_RATE_EXCEEDS_CAP", Message: "interest rate exceeds the cap for the asset category"}
+	ErrConcurrentModification = &TransactionError{Code: "CONCURRENT_MODIFICATION", Message: "another transaction for this credit limit is in progress"}
+	ErrExceedsDebtToIncome    = &TransactionError{Code: "EXCEEDS_DEBT_TO_INCOME", Message: "monthly installments would exceed the allowed share of salary"}
+	ErrKYCIncomplete          = &TransactionError{Code: "KYC_INCOMPLETE", Message: "customer has not uploaded all required documents"}
+	ErrAdminFeeExceedsMax     = &TransactionError{Code: "ADMIN_FEE_EXCEEDS_MAX", Message: "admin fee exceeds the maximum allowed fee"}
+	ErrNoDefaultInterestRate  = &TransactionError{Code: "NO_DEFAULT_INTEREST_RATE", Message: "interest rate is required because no default rate is configured for the tenor"}
+
+	ErrIdempotencyKeyInProgress = &TransactionError{Code: "IDEMPOTENCY_KEY_IN_PROGRESS", Message: "a request with this idempotency key is still being processed"}
 )
 
+func NewInvalidStatusTransitionError(from, to TransactionStatus) error {
+	return &TransactionError{
+		Code:    ErrInvalidStatusTransition.Code,
+		Message: fmt.Sprintf("cannot change transaction status from %s to %s", from, to),
+	}
+}
+
+func NewInterestRateExceedsCapError(category string, rate, maxRate float64) error {
+	return &TransactionError{
+		Code:    ErrInterestRateExceedsCap.Code,
+		Message: fmt.Sprintf("interest rate %.2f%% exceeds the %.2f%% cap for %s assets", rate, maxRate, category),
+	}
+}
+
+func NewAdminFeeExceedsMaxError(fee, maxFee float64) error {
+	return &TransactionError{
+		Code:    ErrAdminFeeExceedsMax.Code,
+		Message: fmt.Sprintf("admin fee %.2f exceeds the maximum of %.2f", fee, maxFee),
+	}
+}
+
+func NewNoDefaultInterestRateError(tenorMonth int) error {
+	return &TransactionError{
+		Code:    ErrNoDefaultInterestRate.Code,
+		Message: fmt.Sprintf("interest rate is required because no default rate is configured for a %d month tenor", tenorMonth),
+	}
+}
+
+func NewExceedsDebtToIncomeError(installment, existing, maxInstallments float64) error {
+	return &TransactionError{
+		Code:    ErrExceedsDebtToIncome.Code,
+		Message: fmt.Sprintf("installment %.2f on top of existing installments %.2f exceeds the monthly maximum of %.2f", installment, existing, maxInstallments),
+	}
+}
+
 func (e *TransactionError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Code, e.Message)
 }
+
+func (e *TransactionError) ErrorCode() string {
+	return e.Code
+}
+
+// Is matches transaction errors by code so errors carrying extra context still
+// compare equal to their sentinel.
+func (e *TransactionError) Is(target error) bool {
+	t, ok := target.(*TransactionError)
+	return ok && t.Code == e.Code
+}
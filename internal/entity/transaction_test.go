@@ -0,0 +1,95 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransactionStatusCanTransitionTo(t *testing.T) {
+	statuses := []TransactionStatus{
+		TransactionStatusPending,
+		TransactionStatusActive,
+		TransactionStatusCompleted,
+		TransactionStatusCancelled,
+	}
+	legal := map[[2]TransactionStatus]bool{
+		{TransactionStatusPending, TransactionStatusActive}:    true,
+		{TransactionStatusPending, TransactionStatusCancelled}: true,
+		{TransactionStatusActive, TransactionStatusCompleted}:  true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := legal[[2]TransactionStatus{from, to}]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: CanTransitionTo = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestInvalidStatusTransitionErrorMatchesSentinel(t *testing.T) {
+	err := NewInvalidStatusTransitionError(TransactionStatusCompleted, TransactionStatusPending)
+
+	if !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("errors.Is(%v, ErrInvalidStatusTransition) = false", err)
+	}
+	if want := "INVALID_STATUS_TRANSITION: cannot change transaction status from completed to pending"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestCreateTransactionRequestSanitizeKeepsEscapedValue(t *testing.T) {
+	req := CreateTransactionRequest{ContractNumber: `KP-<img src=x onerror="alert(1)">`}
+
+	req.Sanitize()
+
+	want := "KP-&lt;img src=x onerror=&#34;alert(1)&#34;&gt;"
+	if req.ContractNumber != want {
+		t.Errorf("ContractNumber = %q, want %q", req.ContractNumber, want)
+	}
+}
+
+func TestCreateTransactionRequestLengthLimitAppliesToEscapedValue(t *testing.T) {
+	//44 characters raw, 52 once the quotes are escaped
+	req := CreateTransactionRequest{ContractNumber: `KP-"20240315"-000000000000000000000000000000`}
+	if len(req.ContractNumber) > 50 {
+		t.Fatalf("raw contract number is already %d characters", len(req.ContractNumber))
+	}
+
+	req.Sanitize()
+
+	found := false
+	for _, problem := range req.Validate() {
+		if problem == "contract_number must not exceed 50 characters" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("escaped contract number of %d characters passed validation", len(req.ContractNumber))
+	}
+}
+
+func TestInstallmentDueDateClampsToMonthEnd(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		start      time.Time
+		n          int
+		billingDay int
+		want       time.Time
+	}{
+		{"leap february", time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC), 1, 31, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"february", time.Date(2023, time.January, 31, 10, 0, 0, 0, time.UTC), 1, 30, time.Date(2023, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{"thirty day month", time.Date(2024, time.March, 31, 10, 0, 0, 0, time.UTC), 1, 31, time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC)},
+		{"back on the 31st after february", time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC), 2, 31, time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"into next year", time.Date(2024, time.December, 31, 10, 0, 0, 0, time.UTC), 2, 31, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{"day that every month has", time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC), 1, MaxBillingDay, time.Date(2024, time.February, 28, 0, 0, 0, 0, time.UTC)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := InstallmentDueDate(tc.start, tc.n, tc.billingDay); !got.Equal(tc.want) {
+				t.Errorf("InstallmentDueDate = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package entity
 
 import (
+	"errors"
+	"fmt"
 	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
 	"sync"
 )
 
@@ -10,8 +14,82 @@ var (
 	initOnce sync.Once
 )
 
+// FieldError is a failed validation rule on one request field, named by its
+// json tag.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
 func init() {
 	initOnce.Do(func() {
 		validate = validator.New()
+		//Report fields by their json name; StructField() still has the Go name
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		//Tenor months come from the credit policy, so they cannot be a oneof tag
+		validate.RegisterValidation("tenor", func(fl validator.FieldLevel) bool {
+			return IsAllowedTenor(int(fl.Field().Int()))
+		})
 	})
 }
+
+// ValidateStruct runs the validate tags of v and returns one FieldError per
+// failed rule, or nil when v is valid.
+func ValidateStruct(v interface{}) []FieldError {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []FieldError{{Rule: "invalid", Message: err.Error()}}
+	}
+
+	fieldErrors := make([]FieldError, len(validationErrors))
+	for i, fe := range validationErrors {
+		fieldErrors[i] = FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(fe),
+		}
+	}
+	return fieldErrors
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s characters", fe.Field(), fe.Param())
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), fe.Param())
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must not exceed %s characters", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must not exceed %s", fe.Field(), fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", fe.Field(), fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", fe.Field(), strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "tenor":
+		return fmt.Sprintf("%s must be one of: %s", fe.Field(), allowedTenorList())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", fe.Field())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", fe.Field())
+	}
+	return fmt.Sprintf("%s failed on %s", fe.Field(), fe.Tag())
+}
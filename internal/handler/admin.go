@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/utils/response_formatter"
+)
+
+// AdminHandler exposes operational controls. Its routes live under /admin,
+// which requires an authenticated caller and is refused while auth is disabled.
+type AdminHandler struct {
+	logLevel zap.AtomicLevel
+	logger   *zap.Logger
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func NewAdminHandler(logLevel zap.AtomicLevel, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		logLevel: logLevel,
+		logger:   logger,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(app *fiber.App) {
+	admin := app.Group("/admin")
+	admin.Get("/log-level", h.GetLogLevel)
+	admin.Put("/log-level", h.SetLogLevel)
+}
+
+func (h *AdminHandler) GetLogLevel(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		LogLevelResponse{Level: h.logLevel.Level().String()},
+		"Log level retrieved successfully",
+	))
+}
+
+// SetLogLevel changes the level of every logger derived from the root logger,
+// taking effect immediately without a restart.
+func (h *AdminHandler) SetLogLevel(c *fiber.Ctx) error {
+	var req LogLevelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	level, err := zapcore.ParseLevel(req.Level)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid log level",
+			[]string{err.Error()},
+		))
+	}
+
+	previous := h.logLevel.Level()
+	h.logLevel.SetLevel(level)
+
+	loggerPkg.FromContext(c.Context()).Warn("log level changed",
+		zap.String("previous_level", previous.String()),
+		zap.String("level", level.String()),
+	)
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		LogLevelResponse{Level: level.String()},
+		"Log level updated successfully",
+	))
+}
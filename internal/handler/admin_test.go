@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"kredit-plus/internal/middleware"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAdminTestApp mounts the admin routes behind the same middleware chain
+// cmd/main.go uses.
+func newAdminTestApp(cfg middleware.AuthConfig, logLevel zap.AtomicLevel) *fiber.App {
+	app := fiber.New()
+	app.Use("/admin", middleware.JWTAuth(cfg), middleware.RequireActor())
+	NewAdminHandler(logLevel, zap.NewNop()).RegisterRoutes(app)
+	return app
+}
+
+func TestAdminLogLevelForbiddenWhenAuthDisabled(t *testing.T) {
+	logLevel := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	app := newAdminTestApp(middleware.AuthConfig{Enabled: false}, logLevel)
+
+	for _, method := range []string{fiber.MethodGet, fiber.MethodPut} {
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s request failed: %v", method, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("%s status = %d, want 403", method, resp.StatusCode)
+		}
+	}
+
+	//The refused PUT must not have reached the handler
+	if level := logLevel.Level(); level != zapcore.InfoLevel {
+		t.Errorf("log level = %s, want it left at info", level)
+	}
+}
@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"kredit-plus/internal/entity"
+	"kredit-plus/internal/middleware"
 	"kredit-plus/utils/response_formatter"
 	"strconv"
+	"strings"
 )
 
 type AssetHandler struct {
@@ -23,10 +26,12 @@ func NewAssetHandler(service entity.AssetService, logger *zap.Logger) *AssetHand
 
 func (h *AssetHandler) RegisterRoutes(app *fiber.App) {
 	assets := app.Group("/api/v1/assets")
-	assets.Post("", h.Create)
+	assets.Post("", middleware.ValidateBody[entity.CreateAssetRequest](), h.Create)
 	assets.Get("", h.List)
+	assets.Post("/batch", middleware.ValidateBody[entity.BatchGetAssetsRequest](), h.GetByIDs)
 	assets.Get("/:id", h.GetByID)
-	assets.Put("/:id", h.Update)
+	assets.Get("/:id/price-history", h.GetPriceHistory)
+	assets.Put("/:id", middleware.ValidateBody[entity.UpdateAssetRequest](), h.Update)
 	assets.Delete("/:id", h.Delete)
 }
 
@@ -58,6 +63,7 @@ func (h *AssetHandler) List(c *fiber.Ctx) error {
 	page, perPage = response_formatter.ValidatePagination(page, perPage)
 
 	filter := entity.AssetFilterRequest{
+		Name:     strings.TrimSpace(c.Query("name")),
 		Category: c.Query("category"),
 		MinPrice: func() float64 {
 			v, _ := strconv.ParseFloat(c.Query("min_price"), 64)
@@ -67,8 +73,10 @@ func (h *AssetHandler) List(c *fiber.Ctx) error {
 			v, _ := strconv.ParseFloat(c.Query("max_price"), 64)
 			return v
 		}(),
-		Limit:  perPage,
-		Offset: response_formatter.CalculateOffset(page, perPage),
+		SortBy:  c.Query("sort_by"),
+		SortDir: strings.ToLower(c.Query("sort_dir")),
+		Limit:   perPage,
+		Offset:  response_formatter.CalculateOffset(page, perPage),
 	}
 
 	assets, total, err := h.service.GetAll(c.Context(), filter)
@@ -101,9 +109,17 @@ func (h *AssetHandler) GetByID(c *fiber.Ctx) error {
 
 	asset, err := h.service.GetByID(c.Context(), id)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
-			fiber.StatusNotFound,
-			"Asset not found",
+		if errors.Is(err, entity.ErrAssetNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get asset",
 			[]string{err.Error()},
 		))
 	}
@@ -111,6 +127,28 @@ func (h *AssetHandler) GetByID(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(asset, "Asset retrieved successfully"))
 }
 
+func (h *AssetHandler) GetByIDs(c *fiber.Ctx) error {
+	var req entity.BatchGetAssetsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	assets, err := h.service.GetByIDs(c.Context(), req.IDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get assets",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(assets, "Assets retrieved successfully"))
+}
+
 func (h *AssetHandler) Update(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -132,6 +170,22 @@ func (h *AssetHandler) Update(c *fiber.Ctx) error {
 
 	asset, err := h.service.Update(c.Context(), id, req)
 	if err != nil {
+		if errors.Is(err, entity.ErrStaleWrite) {
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Asset was modified by another request",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrAssetNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		}
+
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to update asset",
@@ -153,6 +207,14 @@ func (h *AssetHandler) Delete(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.Delete(c.Context(), id); err != nil {
+		if errors.Is(err, entity.ErrAssetNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		}
+
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to delete asset",
@@ -162,3 +224,43 @@ func (h *AssetHandler) Delete(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(nil, "Asset deleted successfully"))
 }
+
+func (h *AssetHandler) GetPriceHistory(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid asset ID",
+			[]string{err.Error()},
+		))
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "10"))
+	page, perPage = response_formatter.ValidatePagination(page, perPage)
+
+	entries, total, err := h.service.GetPriceHistory(c.Context(), id, page, perPage)
+	if err != nil {
+		if errors.Is(err, entity.ErrAssetNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get asset price history",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
+		entries,
+		"Asset price history retrieved successfully",
+		page,
+		perPage,
+		total,
+	))
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"kredit-plus/utils/response_formatter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubAssetService fails every call with err, or succeeds when err is nil.
+// Methods the tests do not route to panic through the embedded interface.
+type stubAssetService struct {
+	entity.AssetService
+
+	err error
+}
+
+func (s stubAssetService) GetByID(_ context.Context, id uuid.UUID) (*entity.AssetResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &entity.AssetResponse{ID: id}, nil
+}
+
+func (s stubAssetService) Delete(context.Context, uuid.UUID) error {
+	return s.err
+}
+
+func TestAssetHandlerSeparatesNotFoundFromFailures(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		for _, tc := range []struct {
+			name          string
+			err           error
+			wantStatus    int
+			wantErrorCode string
+		}{
+			{"found", nil, fiber.StatusOK, ""},
+			{"not found", entity.ErrAssetNotFound, fiber.StatusNotFound, "ASSET_NOT_FOUND"},
+			{"wrapped not found", fmt.Errorf("failed to delete asset: %w", entity.ErrAssetNotFound), fiber.StatusNotFound, "ASSET_NOT_FOUND"},
+			{"database down", errors.New("dial tcp: connection refused"), fiber.StatusInternalServerError, ""},
+		} {
+			t.Run(method+" "+tc.name, func(t *testing.T) {
+				app := fiber.New()
+				NewAssetHandler(stubAssetService{err: tc.err}, zap.NewNop()).RegisterRoutes(app)
+
+				resp, err := app.Test(httptest.NewRequest(method, "/api/v1/assets/"+uuid.NewString(), nil))
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+				defer resp.Body.Close()
+
+				if resp.StatusCode != tc.wantStatus {
+					t.Fatalf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+				}
+				var body response_formatter.Response
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if body.ErrorCode != tc.wantErrorCode {
+					t.Errorf("error_code = %q, want %q", body.ErrorCode, tc.wantErrorCode)
+				}
+			})
+		}
+	}
+}
+
+func TestAssetHandlerRejectsMalformedID(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		app := fiber.New()
+		NewAssetHandler(stubAssetService{}, zap.NewNop()).RegisterRoutes(app)
+
+		resp, err := app.Test(httptest.NewRequest(method, "/api/v1/assets/not-a-uuid", nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s status = %d, want %d", method, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
+	"kredit-plus/internal/middleware"
 	"kredit-plus/utils/response_formatter"
 	"strconv"
 )
@@ -23,11 +25,15 @@ func NewCreditLimitHandler(service entity.CreditLimitService, logger *zap.Logger
 
 func (h *CreditLimitHandler) RegisterRoutes(app *fiber.App) {
 	creditLimits := app.Group("/api/v1/credit-limits")
-	creditLimits.Post("", h.Create)
+	creditLimits.Post("", middleware.ValidateBody[entity.CreateCreditLimitRequest](), h.Create)
+	creditLimits.Get("", h.GetAll)
 	creditLimits.Get("/:id", h.GetByID)
+	creditLimits.Get("/:id/ledger", h.GetLedger)
 	creditLimits.Get("/customer/:customer_id", h.GetAllByCustomerID)
 	creditLimits.Get("/customer/:customer_id/tenor/:tenor_month", h.GetByCustomerIDAndTenor)
 	creditLimits.Put("/:id/used-amount", h.UpdateUsedAmount)
+	creditLimits.Put("/:id/limit-amount", h.UpdateLimitAmount)
+	creditLimits.Post("/:id/recalculate", h.RecalculateUsedAmount)
 	creditLimits.Delete("/:id", h.Delete)
 }
 
@@ -41,6 +47,21 @@ func (h *CreditLimitHandler) Create(c *fiber.Ctx) error {
 		))
 	}
 
+	//Upsert mode lets provisioning scripts re-run without failing on existing limits
+	if upsert := c.Query("upsert"); upsert != "" {
+		isUpsert, err := strconv.ParseBool(upsert)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+				fiber.StatusBadRequest,
+				"Invalid upsert value",
+				[]string{err.Error()},
+			))
+		}
+		if isUpsert {
+			return h.createIfAbsent(c, req)
+		}
+	}
+
 	creditLimit, err := h.service.Create(c.Context(), req)
 	if err != nil {
 		if err == entity.ErrDuplicateCreditLimit {
@@ -51,7 +72,7 @@ func (h *CreditLimitHandler) Create(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to create credit limit", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to create credit limit", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to create credit limit",
@@ -65,6 +86,83 @@ func (h *CreditLimitHandler) Create(c *fiber.Ctx) error {
 	))
 }
 
+func (h *CreditLimitHandler) createIfAbsent(c *fiber.Ctx, req entity.CreateCreditLimitRequest) error {
+	creditLimit, created, err := h.service.CreateIfAbsent(c.Context(), req)
+	if err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to create credit limit", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to create credit limit",
+			[]string{err.Error()},
+		))
+	}
+
+	if !created {
+		return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+			creditLimit,
+			"Credit limit already exists",
+		))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response_formatter.Created(
+		creditLimit,
+		"Credit limit created successfully",
+	))
+}
+
+func (h *CreditLimitHandler) GetAll(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "10"))
+	page, perPage = response_formatter.ValidatePagination(page, perPage)
+
+	filter := entity.CreditLimitFilterRequest{
+		Page:    page,
+		PerPage: perPage,
+	}
+
+	if tenor := c.Query("tenor_month"); tenor != "" {
+		tenorMonth, err := strconv.Atoi(tenor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+				fiber.StatusBadRequest,
+				"Invalid tenor month",
+				[]string{err.Error()},
+			))
+		}
+		filter.TenorMonth = tenorMonth
+	}
+
+	if utilization := c.Query("min_utilization"); utilization != "" {
+		minUtilization, err := strconv.ParseFloat(utilization, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+				fiber.StatusBadRequest,
+				"Invalid minimum utilization",
+				[]string{err.Error()},
+			))
+		}
+		filter.MinUtilization = minUtilization
+	}
+
+	creditLimits, total, err := h.service.GetAll(c.Context(), filter)
+	if err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to list credit limits", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get credit limits",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
+		creditLimits,
+		"Credit limits retrieved successfully",
+		page,
+		perPage,
+		total,
+	))
+}
+
 func (h *CreditLimitHandler) GetByID(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -85,7 +183,7 @@ func (h *CreditLimitHandler) GetByID(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to get credit limit", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to get credit limit", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to get credit limit",
@@ -99,6 +197,47 @@ func (h *CreditLimitHandler) GetByID(c *fiber.Ctx) error {
 	))
 }
 
+func (h *CreditLimitHandler) GetLedger(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid credit limit ID",
+			[]string{err.Error()},
+		))
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "10"))
+	page, perPage = response_formatter.ValidatePagination(page, perPage)
+
+	entries, total, err := h.service.GetLedger(c.Context(), id, page, perPage)
+	if err != nil {
+		if err == entity.ErrCreditLimitNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+				fiber.StatusNotFound,
+				"Credit limit not found",
+				[]string{err.Error()},
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to get credit limit ledger", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get credit limit ledger",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
+		entries,
+		"Credit limit ledger retrieved successfully",
+		page,
+		perPage,
+		total,
+	))
+}
+
 func (h *CreditLimitHandler) GetAllByCustomerID(c *fiber.Ctx) error {
 	customerID, err := uuid.Parse(c.Params("customer_id"))
 	if err != nil {
@@ -109,9 +248,13 @@ func (h *CreditLimitHandler) GetAllByCustomerID(c *fiber.Ctx) error {
 		))
 	}
 
-	creditLimits, err := h.service.GetAllByCustomerID(c.Context(), customerID)
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "10"))
+	page, perPage = response_formatter.ValidatePagination(page, perPage)
+
+	creditLimits, total, err := h.service.GetAllByCustomerID(c.Context(), customerID, page, perPage)
 	if err != nil {
-		h.logger.Error("failed to get customer credit limits", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to get customer credit limits", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to get credit limits",
@@ -119,9 +262,12 @@ func (h *CreditLimitHandler) GetAllByCustomerID(c *fiber.Ctx) error {
 		))
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
 		creditLimits,
 		"Credit limits retrieved successfully",
+		page,
+		perPage,
+		total,
 	))
 }
 
@@ -154,7 +300,7 @@ func (h *CreditLimitHandler) GetByCustomerIDAndTenor(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to get credit limit", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to get credit limit", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to get credit limit",
@@ -170,6 +316,7 @@ func (h *CreditLimitHandler) GetByCustomerIDAndTenor(c *fiber.Ctx) error {
 
 type UpdateUsedAmountRequest struct {
 	Amount float64 `json:"amount" validate:"required"`
+	Reason string  `json:"reason"`
 }
 
 func (h *CreditLimitHandler) UpdateUsedAmount(c *fiber.Ctx) error {
@@ -191,7 +338,7 @@ func (h *CreditLimitHandler) UpdateUsedAmount(c *fiber.Ctx) error {
 		))
 	}
 
-	if err := h.service.UpdateUsedAmount(c.Context(), id, req.Amount); err != nil {
+	if err := h.service.UpdateUsedAmount(c.Context(), id, req.Amount, req.Reason); err != nil {
 		if err == entity.ErrCreditLimitNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
 				fiber.StatusNotFound,
@@ -208,7 +355,7 @@ func (h *CreditLimitHandler) UpdateUsedAmount(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to update credit limit used amount", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to update credit limit used amount", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to update credit limit used amount",
@@ -222,6 +369,100 @@ func (h *CreditLimitHandler) UpdateUsedAmount(c *fiber.Ctx) error {
 	))
 }
 
+type UpdateLimitAmountRequest struct {
+	LimitAmount float64 `json:"limit_amount" validate:"required,gt=0"`
+}
+
+func (h *CreditLimitHandler) UpdateLimitAmount(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid credit limit ID",
+			[]string{err.Error()},
+		))
+	}
+
+	var req UpdateLimitAmountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	creditLimit, err := h.service.UpdateLimitAmount(c.Context(), id, req.LimitAmount)
+	if err != nil {
+		switch err {
+		case entity.ErrCreditLimitNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Credit limit not found",
+				err,
+			))
+		case entity.ErrInvalidLimitAmount:
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Invalid limit amount",
+				err,
+			))
+		case entity.ErrLimitBelowUsedAmount:
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Limit amount is below the used amount",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to update credit limit amount", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to update credit limit amount",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		creditLimit,
+		"Credit limit amount updated successfully",
+	))
+}
+
+func (h *CreditLimitHandler) RecalculateUsedAmount(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid credit limit ID",
+			[]string{err.Error()},
+		))
+	}
+
+	recalculation, err := h.service.RecalculateUsedAmount(c.Context(), id)
+	if err != nil {
+		if err == entity.ErrCreditLimitNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+				fiber.StatusNotFound,
+				"Credit limit not found",
+				[]string{err.Error()},
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to recalculate credit limit used amount", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to recalculate credit limit used amount",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		recalculation,
+		"Credit limit used amount recalculated successfully",
+	))
+}
+
 func (h *CreditLimitHandler) Delete(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -249,7 +490,7 @@ func (h *CreditLimitHandler) Delete(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to delete credit limit", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to delete credit limit", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to delete credit limit",
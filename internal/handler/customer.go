@@ -1,12 +1,19 @@
 package handler
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
+	"kredit-plus/internal/middleware"
 	"kredit-plus/utils/response_formatter"
 	"strconv"
+	"strings"
 )
 
 type CustomerHandler struct {
@@ -25,21 +32,27 @@ func (h *CustomerHandler) RegisterRoutes(app *fiber.App) {
 	customers := app.Group("/api/v1/customers")
 
 	//Customer management
-	customers.Post("", h.Create)
+	customers.Post("", middleware.ValidateBody[entity.CreateCustomerRequest](), h.Create)
+	customers.Post("/bulk", h.BulkCreate)
+	customers.Get("", h.List)
+	customers.Get("/export", h.Export)
 	customers.Get("/:id", h.GetByID)
 	customers.Get("/nik/:nik", h.GetByNIK)
-	customers.Put("/:id", h.Update)
+	customers.Put("/:id", middleware.ValidateBody[entity.UpdateCustomerRequest](), h.Update)
 	customers.Delete("/:id", h.Delete)
+	customers.Post("/:id/activate", h.Reactivate)
 
 	//Document management
-	customers.Post("/:id/documents", h.UploadDocument)
+	customers.Post("/:id/documents", middleware.ValidateBody[entity.UploadDocumentRequest](), h.UploadDocument)
+	customers.Put("/:id/documents", middleware.ValidateBody[entity.UploadDocumentRequest](), h.UpsertDocument)
 	customers.Get("/:id/documents", h.GetDocuments)
+	customers.Delete("/:id/documents/:doc_id", h.DeleteDocument)
 }
 
 func (h *CustomerHandler) Create(c *fiber.Ctx) error {
 	var req entity.CreateCustomerRequest
 	if err := c.BodyParser(&req); err != nil {
-		h.logger.Error("failed to parse create customer request", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to parse create customer request", zap.Error(err))
 		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
 			fiber.StatusBadRequest,
 			"Invalid request body",
@@ -49,15 +62,15 @@ func (h *CustomerHandler) Create(c *fiber.Ctx) error {
 
 	customer, err := h.service.Create(c.Context(), req)
 	if err != nil {
-		if err.Error() == "customer with NIK already exists" {
-			return c.Status(fiber.StatusConflict).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrDuplicateNIK) {
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
 				fiber.StatusConflict,
 				"Customer already exists",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to create customer", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to create customer", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to create customer",
@@ -71,6 +84,133 @@ func (h *CustomerHandler) Create(c *fiber.Ctx) error {
 	))
 }
 
+func (h *CustomerHandler) BulkCreate(c *fiber.Ctx) error {
+	var reqs []entity.CreateCustomerRequest
+	if err := c.BodyParser(&reqs); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse bulk create customer request", zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	result, err := h.service.BulkCreate(c.Context(), reqs)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrBulkImportTooLarge):
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(response_formatter.CodedError(
+				fiber.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Bulk import is limited to %d customers", entity.MaxBulkCustomerImport),
+				err,
+			))
+		case errors.Is(err, entity.ErrBulkImportEmpty):
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"No customers to import",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to bulk create customers", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to import customers",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		result,
+		"Bulk import processed",
+	))
+}
+
+func (h *CustomerHandler) List(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "10"))
+	page, perPage = response_formatter.ValidatePagination(page, perPage)
+
+	filter := entity.CustomerFilterRequest{
+		FullName: c.Query("full_name"),
+		Page:     page,
+		PerPage:  perPage,
+	}
+
+	if active := c.Query("is_active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+				fiber.StatusBadRequest,
+				"Invalid is_active value",
+				[]string{err.Error()},
+			))
+		}
+		filter.IsActive = &isActive
+	}
+
+	customers, total, err := h.service.List(c.Context(), filter)
+	if err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to list customers", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get customers",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
+		customers,
+		"Customers retrieved successfully",
+		page,
+		perPage,
+		total,
+	))
+}
+
+func (h *CustomerHandler) Export(c *fiber.Ctx) error {
+	filter := entity.CustomerFilterRequest{
+		FullName: c.Query("full_name"),
+	}
+
+	if active := c.Query("is_active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+				fiber.StatusBadRequest,
+				"Invalid is_active value",
+				[]string{err.Error()},
+			))
+		}
+		filter.IsActive = &isActive
+	}
+
+	if errs := filter.ValidateExport(); len(errs) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid export filter",
+			errs,
+		))
+	}
+
+	// The body is written after the handler returns, when the request context
+	// has been recycled, so the stream runs on its own context.
+	ctx := loggerPkg.NewContext(context.Background(), loggerPkg.FromContext(c.Context()))
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="customers.csv"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if _, err := h.service.Export(ctx, filter, w); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to stream customer export", zap.Error(err))
+		}
+		if err := w.Flush(); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to flush customer export", zap.Error(err))
+		}
+	})
+
+	return nil
+}
+
 func (h *CustomerHandler) GetByID(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -81,17 +221,24 @@ func (h *CustomerHandler) GetByID(c *fiber.Ctx) error {
 		))
 	}
 
-	customer, err := h.service.GetByID(c.Context(), id)
+	includeStats := false
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		if strings.TrimSpace(include) == "stats" {
+			includeStats = true
+		}
+	}
+
+	customer, err := h.service.GetByID(c.Context(), id, includeStats)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Customer not found",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to get customer", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to get customer", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to get customer",
@@ -117,15 +264,15 @@ func (h *CustomerHandler) GetByNIK(c *fiber.Ctx) error {
 
 	customer, err := h.service.GetByNIK(c.Context(), nik)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Customer not found",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to get customer by NIK", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to get customer by NIK", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to get customer",
@@ -151,7 +298,7 @@ func (h *CustomerHandler) Update(c *fiber.Ctx) error {
 
 	var req entity.UpdateCustomerRequest
 	if err := c.BodyParser(&req); err != nil {
-		h.logger.Error("failed to parse update customer request", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to parse update customer request", zap.Error(err))
 		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
 			fiber.StatusBadRequest,
 			"Invalid request body",
@@ -161,15 +308,31 @@ func (h *CustomerHandler) Update(c *fiber.Ctx) error {
 
 	customer, err := h.service.Update(c.Context(), id, req)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Customer not found",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to update customer", zap.Error(err))
+		if errors.Is(err, entity.ErrCustomerInactive) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Customer is inactive",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrStaleWrite) {
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Customer was modified by another request",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to update customer", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to update customer",
@@ -194,15 +357,23 @@ func (h *CustomerHandler) Delete(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.Delete(c.Context(), id); err != nil {
-		if err.Error() == "customer not found" {
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Customer not found",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to delete customer", zap.Error(err))
+		if errors.Is(err, entity.ErrCustomerInactive) {
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Customer is already inactive",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to delete customer", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to delete customer",
@@ -216,6 +387,47 @@ func (h *CustomerHandler) Delete(c *fiber.Ctx) error {
 	))
 }
 
+func (h *CustomerHandler) Reactivate(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid customer ID",
+			[]string{err.Error()},
+		))
+	}
+
+	customer, err := h.service.Reactivate(c.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrCustomerNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Customer not found",
+				err,
+			))
+		case errors.Is(err, entity.ErrCustomerAlreadyActive):
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Customer is already active",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to reactivate customer", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to reactivate customer",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		customer,
+		"Customer reactivated successfully",
+	))
+}
+
 func (h *CustomerHandler) UploadDocument(c *fiber.Ctx) error {
 	customerID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -228,7 +440,7 @@ func (h *CustomerHandler) UploadDocument(c *fiber.Ctx) error {
 
 	var req entity.UploadDocumentRequest
 	if err := c.BodyParser(&req); err != nil {
-		h.logger.Error("failed to parse upload document request", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to parse upload document request", zap.Error(err))
 		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
 			fiber.StatusBadRequest,
 			"Invalid request body",
@@ -238,23 +450,31 @@ func (h *CustomerHandler) UploadDocument(c *fiber.Ctx) error {
 
 	doc, err := h.service.UploadDocument(c.Context(), customerID, req)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Customer not found",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		if err.Error() == "document type already exists for customer" {
-			return c.Status(fiber.StatusConflict).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerInactive) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Customer is inactive",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrDuplicateDocument) {
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
 				fiber.StatusConflict,
 				"Document already exists",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to upload document", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to upload document", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to upload document",
@@ -268,6 +488,113 @@ func (h *CustomerHandler) UploadDocument(c *fiber.Ctx) error {
 	))
 }
 
+func (h *CustomerHandler) UpsertDocument(c *fiber.Ctx) error {
+	customerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid customer ID",
+			[]string{err.Error()},
+		))
+	}
+
+	var req entity.UploadDocumentRequest
+	if err := c.BodyParser(&req); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse upsert document request", zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	doc, created, err := h.service.UpsertDocument(c.Context(), customerID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrCustomerNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Customer not found",
+				err,
+			))
+		case errors.Is(err, entity.ErrCustomerInactive):
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Customer is inactive",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to upsert document", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to save document",
+			[]string{err.Error()},
+		))
+	}
+
+	if created {
+		return c.Status(fiber.StatusCreated).JSON(response_formatter.Created(
+			doc,
+			"Document uploaded successfully",
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		doc,
+		"Document replaced successfully",
+	))
+}
+
+func (h *CustomerHandler) DeleteDocument(c *fiber.Ctx) error {
+	customerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid customer ID",
+			[]string{err.Error()},
+		))
+	}
+
+	documentID, err := uuid.Parse(c.Params("doc_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid document ID",
+			[]string{err.Error()},
+		))
+	}
+
+	if err := h.service.DeleteDocument(c.Context(), customerID, documentID); err != nil {
+		switch {
+		case errors.Is(err, entity.ErrCustomerNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Customer not found",
+				err,
+			))
+		case errors.Is(err, entity.ErrDocumentNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Document not found",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to delete document", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to delete document",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		nil,
+		"Document deleted successfully",
+	))
+}
+
 func (h *CustomerHandler) GetDocuments(c *fiber.Ctx) error {
 	customerID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -289,7 +616,7 @@ func (h *CustomerHandler) GetDocuments(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
 				fiber.StatusBadRequest,
 				"Invalid document type",
-				[]string{"document_type must be either 'ktp' or 'selfie'"},
+				[]string{entity.DocumentTypesMessage()},
 			))
 		}
 		docType = &t
@@ -303,15 +630,15 @@ func (h *CustomerHandler) GetDocuments(c *fiber.Ctx) error {
 
 	documents, total, err := h.service.GetDocuments(c.Context(), customerID, filter)
 	if err != nil {
-		if err.Error() == "customer not found" {
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+		if errors.Is(err, entity.ErrCustomerNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Customer not found",
-				[]string{err.Error()},
+				err,
 			))
 		}
 
-		h.logger.Error("failed to get documents", zap.Error(err))
+		loggerPkg.FromContext(c.Context()).Error("failed to get documents", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
 			fiber.StatusInternalServerError,
 			"Failed to get documents",
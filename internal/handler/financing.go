@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/internal/entity"
+	"kredit-plus/internal/middleware"
+	"kredit-plus/utils/response_formatter"
+)
+
+type FinancingHandler struct {
+	service entity.FinancingService
+	logger  *zap.Logger
+}
+
+func NewFinancingHandler(service entity.FinancingService, logger *zap.Logger) *FinancingHandler {
+	return &FinancingHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *FinancingHandler) RegisterRoutes(app *fiber.App) {
+	financing := app.Group("/api/v1/financing")
+	financing.Post("/apply", middleware.ValidateBody[entity.FinancingApplicationRequest](), h.Apply)
+	financing.Post("/check", middleware.ValidateBody[entity.FinancingApplicationRequest](), h.Check)
+}
+
+func (h *FinancingHandler) Apply(c *fiber.Ctx) error {
+	var req entity.FinancingApplicationRequest
+	if err := c.BodyParser(&req); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse financing application request",
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	result, err := h.service.Apply(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoDefaultInterestRate) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Interest rate is required",
+				err,
+			))
+		}
+
+		switch err {
+		case entity.ErrCustomerNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Customer not found",
+				err,
+			))
+		case entity.ErrAssetNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		case entity.ErrDuplicateContract:
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Contract number already exists",
+				err,
+			))
+		case entity.ErrConcurrentModification:
+			return c.Status(fiber.StatusTooManyRequests).JSON(response_formatter.CodedError(
+				fiber.StatusTooManyRequests,
+				"Credit limit is busy, please retry",
+				err,
+			))
+		default:
+			loggerPkg.FromContext(c.Context()).Error("failed to apply for financing",
+				zap.Error(err),
+				zap.Any("request", req),
+			)
+			return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+				fiber.StatusInternalServerError,
+				"Failed to apply for financing",
+				[]string{err.Error()},
+			))
+		}
+	}
+
+	//Nothing was created; the rejections list every rule the application failed
+	if !result.Approved {
+		response := response_formatter.CodedError(
+			fiber.StatusUnprocessableEntity,
+			"Financing application rejected",
+			entity.ErrFinancingRejected,
+		)
+		response.Data = result
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response_formatter.Created(
+		result,
+		"Financing application approved",
+	))
+}
+
+func (h *FinancingHandler) Check(c *fiber.Ctx) error {
+	var req entity.FinancingApplicationRequest
+	if err := c.BodyParser(&req); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse financing check request",
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	result, err := h.service.Check(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoDefaultInterestRate) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Interest rate is required",
+				err,
+			))
+		}
+
+		switch err {
+		case entity.ErrCustomerNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Customer not found",
+				err,
+			))
+		case entity.ErrAssetNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		default:
+			loggerPkg.FromContext(c.Context()).Error("failed to check financing eligibility",
+				zap.Error(err),
+				zap.Any("request", req),
+			)
+			return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+				fiber.StatusInternalServerError,
+				"Failed to check financing eligibility",
+				[]string{err.Error()},
+			))
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		result,
+		"Financing eligibility checked",
+	))
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/infra/mysql"
+	"kredit-plus/infra/redis"
+	"kredit-plus/utils/response_formatter"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db     *mysql.Client
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+type DependencyHealth struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Circuit   string `json:"circuit,omitempty"`
+}
+
+func NewHealthHandler(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		db:     db,
+		redis:  redisClient,
+		logger: logger,
+	}
+}
+
+func (h *HealthHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/health", h.Readiness)
+	app.Get("/health/live", h.Liveness)
+}
+
+// Liveness only reports that the process is serving requests.
+func (h *HealthHandler) Liveness(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(nil, "Service is alive"))
+}
+
+// Readiness reports 200 only when every dependency answers within the timeout.
+func (h *HealthHandler) Readiness(c *fiber.Ctx) error {
+	redisHealth := h.check(c.Context(), h.redis.Health)
+	redisHealth.Circuit = string(h.redis.BreakerState())
+
+	dependencies := map[string]DependencyHealth{
+		"mysql": h.check(c.Context(), h.db.Health),
+		"redis": redisHealth,
+	}
+
+	for name, dependency := range dependencies {
+		if dependency.Status != "up" {
+			loggerPkg.FromContext(c.Context()).Warn("health check failed",
+				zap.String("dependency", name),
+				zap.String("error", dependency.Error),
+			)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(response_formatter.Response{
+				Code:    fiber.StatusServiceUnavailable,
+				Message: "Service is unhealthy",
+				Data:    dependencies,
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(dependencies, "Service is healthy"))
+}
+
+func (h *HealthHandler) check(ctx context.Context, ping func(ctx context.Context) error) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	health := DependencyHealth{
+		Status:    "up",
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		health.Status = "down"
+		health.Error = err.Error()
+	}
+	return health
+}
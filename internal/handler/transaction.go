@@ -1,14 +1,23 @@
 package handler
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
+	"kredit-plus/internal/middleware"
 	"kredit-plus/utils/response_formatter"
 	"strconv"
+	"strings"
 )
 
+const idempotencyKeyHeader = "Idempotency-Key"
+
 type TransactionHandler struct {
 	service entity.TransactionService
 	logger  *zap.Logger
@@ -23,17 +32,26 @@ func NewTransactionHandler(service entity.TransactionService, logger *zap.Logger
 
 func (h *TransactionHandler) RegisterRoutes(app *fiber.App) {
 	transactions := app.Group("/api/v1/transactions")
-	transactions.Post("", h.Create)
+	transactions.Post("", middleware.ValidateBody[entity.CreateTransactionRequest](), h.Create)
+	transactions.Post("/preview", middleware.ValidateBody[entity.TransactionPreviewRequest](), h.Preview)
 	transactions.Get("/:id", h.GetByID)
+	transactions.Get("/:id/installments", h.GetInstallments)
 	transactions.Get("/contract/:contract_number", h.GetByContractNumber)
 	transactions.Get("/customer/:customer_id", h.GetAllByCustomerID)
+	transactions.Get("/customer/:customer_id/summary", h.GetCustomerSummary)
+	transactions.Get("/customer/:customer_id/export", h.ExportByCustomerID)
+	transactions.Post("/status/batch", middleware.ValidateBody[entity.BatchUpdateStatusRequest](), h.BatchUpdateStatus)
 	transactions.Put("/:id/status", h.UpdateStatus)
+	transactions.Post("/:id/installments/:number/pay", h.PayInstallment)
+	transactions.Post("/:id/cancel", h.Cancel)
+	transactions.Get("/:id/payoff", h.GetPayoff)
+	transactions.Post("/:id/settle", h.Settle)
 }
 
 func (h *TransactionHandler) Create(c *fiber.Ctx) error {
 	var req entity.CreateTransactionRequest
 	if err := c.BodyParser(&req); err != nil {
-		h.logger.Error("failed to parse create transaction request",
+		loggerPkg.FromContext(c.Context()).Error("failed to parse create transaction request",
 			zap.Error(err),
 		)
 		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
@@ -43,23 +61,125 @@ func (h *TransactionHandler) Create(c *fiber.Ctx) error {
 		))
 	}
 
+	if req.IdempotencyKey = c.Get(idempotencyKeyHeader); req.IdempotencyKey != "" {
+		existing, err := h.service.ClaimIdempotencyKey(c.Context(), req.CustomerID, req.IdempotencyKey)
+		if err != nil {
+			if err == entity.ErrIdempotencyKeyInProgress {
+				return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+					fiber.StatusConflict,
+					"Request is still being processed",
+					err,
+				))
+			}
+			loggerPkg.FromContext(c.Context()).Error("failed to resolve idempotency key",
+				zap.Error(err),
+				zap.String("customer_id", req.CustomerID.String()),
+			)
+			return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+				fiber.StatusInternalServerError,
+				"Failed to create transaction",
+				[]string{err.Error()},
+			))
+		}
+		if existing != nil {
+			return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+				existing,
+				"Transaction already created",
+			))
+		}
+	}
+
 	transaction, err := h.service.Create(c.Context(), req)
 	if err != nil {
+		if errors.Is(err, entity.ErrInterestRateExceedsCap) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Interest rate too high",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrAdminFeeExceedsMax) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Admin fee too high",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrNoDefaultInterestRate) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Interest rate is required",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrExceedsDebtToIncome) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Installment exceeds debt to income limit",
+				err,
+			))
+		}
+
 		switch err {
 		case entity.ErrDuplicateContract:
-			return c.Status(fiber.StatusConflict).JSON(response_formatter.Error(
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
 				fiber.StatusConflict,
 				"Contract number already exists",
-				[]string{err.Error()},
+				err,
 			))
 		case entity.ErrInsufficientCreditLimit:
-			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
 				fiber.StatusBadRequest,
 				"Insufficient credit limit",
-				[]string{err.Error()},
+				err,
+			))
+		case entity.ErrAssetOutOfStock:
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Asset is out of stock",
+				err,
+			))
+		case entity.ErrCustomerNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Customer not found",
+				err,
+			))
+		case entity.ErrAssetNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Asset not found",
+				err,
+			))
+		case entity.ErrKYCIncomplete:
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(response_formatter.CodedError(
+				fiber.StatusUnprocessableEntity,
+				"Customer documents are incomplete",
+				err,
+			))
+		case entity.ErrCustomerInactive:
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(response_formatter.CodedError(
+				fiber.StatusUnprocessableEntity,
+				"Customer is inactive",
+				err,
+			))
+		case entity.ErrCreditLimitNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Credit limit not found",
+				err,
+			))
+		case entity.ErrConcurrentModification:
+			return c.Status(fiber.StatusTooManyRequests).JSON(response_formatter.CodedError(
+				fiber.StatusTooManyRequests,
+				"Credit limit is busy, please retry",
+				err,
 			))
 		default:
-			h.logger.Error("failed to create transaction",
+			loggerPkg.FromContext(c.Context()).Error("failed to create transaction",
 				zap.Error(err),
 				zap.Any("request", req),
 			)
@@ -77,6 +197,54 @@ func (h *TransactionHandler) Create(c *fiber.Ctx) error {
 	))
 }
 
+func (h *TransactionHandler) Preview(c *fiber.Ctx) error {
+	var req entity.TransactionPreviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse transaction preview request",
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	preview, err := h.service.Preview(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, entity.ErrAdminFeeExceedsMax) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Admin fee too high",
+				err,
+			))
+		}
+
+		if errors.Is(err, entity.ErrNoDefaultInterestRate) {
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Interest rate is required",
+				err,
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to preview transaction",
+			zap.Error(err),
+			zap.Any("request", req),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to preview transaction",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		preview,
+		"Transaction schedule previewed successfully",
+	))
+}
+
 func (h *TransactionHandler) GetByID(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -97,7 +265,7 @@ func (h *TransactionHandler) GetByID(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to get transaction",
+		loggerPkg.FromContext(c.Context()).Error("failed to get transaction",
 			zap.Error(err),
 			zap.String("transaction_id", id.String()),
 		)
@@ -114,6 +282,50 @@ func (h *TransactionHandler) GetByID(c *fiber.Ctx) error {
 	))
 }
 
+func (h *TransactionHandler) GetInstallments(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid transaction ID",
+			[]string{err.Error()},
+		))
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "10"))
+	page, perPage = response_formatter.ValidatePagination(page, perPage)
+
+	installments, total, err := h.service.GetInstallments(c.Context(), id, page, perPage)
+	if err != nil {
+		if err == entity.ErrTransactionNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+				fiber.StatusNotFound,
+				"Transaction not found",
+				[]string{err.Error()},
+			))
+		}
+
+		loggerPkg.FromContext(c.Context()).Error("failed to get transaction installments",
+			zap.Error(err),
+			zap.String("transaction_id", id.String()),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get installments",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
+		installments,
+		"Installments retrieved successfully",
+		page,
+		perPage,
+		total,
+	))
+}
+
 func (h *TransactionHandler) GetByContractNumber(c *fiber.Ctx) error {
 	contractNumber := c.Params("contract_number")
 	if contractNumber == "" {
@@ -134,7 +346,7 @@ func (h *TransactionHandler) GetByContractNumber(c *fiber.Ctx) error {
 			))
 		}
 
-		h.logger.Error("failed to get transaction by contract number",
+		loggerPkg.FromContext(c.Context()).Error("failed to get transaction by contract number",
 			zap.Error(err),
 			zap.String("contract_number", contractNumber),
 		)
@@ -166,14 +378,19 @@ func (h *TransactionHandler) GetAllByCustomerID(c *fiber.Ctx) error {
 	page, perPage = response_formatter.ValidatePagination(page, perPage)
 
 	filter := entity.TransactionFilterRequest{
-		Status:  entity.TransactionStatus(c.Query("status")),
-		Page:    page,
-		PerPage: perPage,
+		Status:    entity.TransactionStatus(c.Query("status")),
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+		SortBy:    c.Query("sort_by"),
+		SortDir:   strings.ToLower(c.Query("sort_dir")),
+		Page:      page,
+		PerPage:   perPage,
+		Cursor:    c.Query("cursor"),
 	}
 
-	transactions, total, err := h.service.GetAllByCustomerID(c.Context(), customerID, filter)
+	transactions, total, nextCursor, err := h.service.GetAllByCustomerID(c.Context(), customerID, filter)
 	if err != nil {
-		h.logger.Error("failed to get customer transactions",
+		loggerPkg.FromContext(c.Context()).Error("failed to get customer transactions",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
@@ -184,12 +401,86 @@ func (h *TransactionHandler) GetAllByCustomerID(c *fiber.Ctx) error {
 		))
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response_formatter.WithPagination(
+	return c.Status(fiber.StatusOK).JSON(response_formatter.WithCursorPagination(
 		transactions,
 		"Transactions retrieved successfully",
 		page,
 		perPage,
 		total,
+		nextCursor,
+	))
+}
+
+func (h *TransactionHandler) ExportByCustomerID(c *fiber.Ctx) error {
+	customerID, err := uuid.Parse(c.Params("customer_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid customer ID",
+			[]string{err.Error()},
+		))
+	}
+
+	filter := entity.TransactionFilterRequest{
+		Status:    entity.TransactionStatus(c.Query("status")),
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+	}
+	if errs := filter.ValidateExport(); len(errs) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid export filter",
+			errs,
+		))
+	}
+
+	// The body is written after the handler returns, when the request context
+	// has been recycled, so the stream runs on its own context.
+	ctx := loggerPkg.NewContext(context.Background(), loggerPkg.FromContext(c.Context()))
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="transactions-%s.csv"`, customerID))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if _, err := h.service.ExportByCustomerID(ctx, customerID, filter, w); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to stream transaction export",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+			)
+		}
+		if err := w.Flush(); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to flush transaction export", zap.Error(err))
+		}
+	})
+
+	return nil
+}
+
+func (h *TransactionHandler) GetCustomerSummary(c *fiber.Ctx) error {
+	customerID, err := uuid.Parse(c.Params("customer_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid customer ID",
+			[]string{err.Error()},
+		))
+	}
+
+	summary, err := h.service.GetCustomerSummary(c.Context(), customerID)
+	if err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to get customer transaction summary",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			"Failed to get customer summary",
+			[]string{err.Error()},
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		summary,
+		"Customer summary retrieved successfully",
 	))
 }
 
@@ -209,7 +500,7 @@ func (h *TransactionHandler) UpdateStatus(c *fiber.Ctx) error {
 
 	var req UpdateTransactionStatusRequest
 	if err := c.BodyParser(&req); err != nil {
-		h.logger.Error("failed to parse update status request",
+		loggerPkg.FromContext(c.Context()).Error("failed to parse update status request",
 			zap.Error(err),
 		)
 		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
@@ -220,21 +511,35 @@ func (h *TransactionHandler) UpdateStatus(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.UpdateStatus(c.Context(), id, req.Status); err != nil {
+		if errors.Is(err, entity.ErrInvalidStatusTransition) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(response_formatter.Error(
+				fiber.StatusUnprocessableEntity,
+				"Invalid status transition",
+				[]string{err.Error()},
+			))
+		}
+
 		switch err {
 		case entity.ErrTransactionNotFound:
-			return c.Status(fiber.StatusNotFound).JSON(response_formatter.Error(
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
 				fiber.StatusNotFound,
 				"Transaction not found",
-				[]string{err.Error()},
+				err,
 			))
 		case entity.ErrInvalidStatus:
-			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
 				fiber.StatusBadRequest,
 				"Invalid status",
-				[]string{err.Error()},
+				err,
+			))
+		case entity.ErrTransactionNotCancellable, entity.ErrTransactionHasPayments:
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Transaction cannot be cancelled",
+				err,
 			))
 		default:
-			h.logger.Error("failed to update transaction status",
+			loggerPkg.FromContext(c.Context()).Error("failed to update transaction status",
 				zap.Error(err),
 				zap.String("transaction_id", id.String()),
 			)
@@ -251,3 +556,255 @@ func (h *TransactionHandler) UpdateStatus(c *fiber.Ctx) error {
 		"Transaction status updated successfully",
 	))
 }
+
+type PayInstallmentRequest struct {
+	Amount       float64 `json:"amount" validate:"required,gt=0"`
+	AllowOverpay bool    `json:"allow_overpay"` //Carry any excess to the following installments instead of rejecting it
+}
+
+func (h *TransactionHandler) BatchUpdateStatus(c *fiber.Ctx) error {
+	var req entity.BatchUpdateStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse batch update status request",
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	result, err := h.service.BatchUpdateStatus(c.Context(), req)
+	if err != nil {
+		switch err {
+		case entity.ErrBatchStatusUpdateTooLarge:
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(response_formatter.CodedError(
+				fiber.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Batch status update is limited to %d transactions", entity.MaxBatchStatusUpdate),
+				err,
+			))
+		case entity.ErrBatchStatusUpdateEmpty, entity.ErrInvalidStatus, entity.ErrBatchCancelNotSupported:
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Invalid batch status update",
+				err,
+			))
+		default:
+			loggerPkg.FromContext(c.Context()).Error("failed to batch update transaction status",
+				zap.Error(err),
+			)
+			return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+				fiber.StatusInternalServerError,
+				"Failed to update transaction status",
+				[]string{err.Error()},
+			))
+		}
+	}
+
+	//Nothing was written; the per-ID results say which transactions blocked the batch
+	if !result.Applied {
+		response := response_formatter.CodedError(
+			fiber.StatusUnprocessableEntity,
+			"Batch status update rejected",
+			entity.ErrBatchStatusUpdateRejected,
+		)
+		response.Data = result
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		result,
+		"Transaction statuses updated successfully",
+	))
+}
+
+func (h *TransactionHandler) PayInstallment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid transaction ID",
+			[]string{err.Error()},
+		))
+	}
+
+	installmentNumber, err := strconv.Atoi(c.Params("number"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid installment number",
+			[]string{err.Error()},
+		))
+	}
+
+	var req PayInstallmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		loggerPkg.FromContext(c.Context()).Error("failed to parse pay installment request",
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid request body",
+			[]string{err.Error()},
+		))
+	}
+
+	installment, err := h.service.PayInstallment(c.Context(), id, installmentNumber, req.Amount, req.AllowOverpay)
+	if err != nil {
+		switch err {
+		case entity.ErrTransactionNotFound, entity.ErrInstallmentNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Installment not found",
+				err,
+			))
+		case entity.ErrInstallmentAlreadyPaid:
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Installment already paid",
+				err,
+			))
+		case entity.ErrTransactionNotPayable:
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Transaction is not accepting payments",
+				err,
+			))
+		case entity.ErrInvalidPaymentAmount, entity.ErrInstallmentOverpayment:
+			return c.Status(fiber.StatusBadRequest).JSON(response_formatter.CodedError(
+				fiber.StatusBadRequest,
+				"Invalid payment amount",
+				err,
+			))
+		default:
+			loggerPkg.FromContext(c.Context()).Error("failed to pay installment",
+				zap.Error(err),
+				zap.String("transaction_id", id.String()),
+				zap.Int("installment_number", installmentNumber),
+			)
+			return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+				fiber.StatusInternalServerError,
+				"Failed to pay installment",
+				[]string{err.Error()},
+			))
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		installment,
+		"Installment payment recorded successfully",
+	))
+}
+
+func (h *TransactionHandler) Cancel(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid transaction ID",
+			[]string{err.Error()},
+		))
+	}
+
+	if err := h.service.Cancel(c.Context(), id); err != nil {
+		switch err {
+		case entity.ErrTransactionNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+				fiber.StatusNotFound,
+				"Transaction not found",
+				err,
+			))
+		case entity.ErrTransactionNotCancellable, entity.ErrTransactionHasPayments:
+			return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+				fiber.StatusConflict,
+				"Transaction cannot be cancelled",
+				err,
+			))
+		default:
+			loggerPkg.FromContext(c.Context()).Error("failed to cancel transaction",
+				zap.Error(err),
+				zap.String("transaction_id", id.String()),
+			)
+			return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+				fiber.StatusInternalServerError,
+				"Failed to cancel transaction",
+				[]string{err.Error()},
+			))
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		nil,
+		"Transaction cancelled successfully",
+	))
+}
+
+func (h *TransactionHandler) GetPayoff(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid transaction ID",
+			[]string{err.Error()},
+		))
+	}
+
+	payoff, err := h.service.GetPayoff(c.Context(), id)
+	if err != nil {
+		return h.settlementError(c, id, err, "Failed to calculate payoff")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		payoff,
+		"Payoff calculated successfully",
+	))
+}
+
+func (h *TransactionHandler) Settle(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response_formatter.Error(
+			fiber.StatusBadRequest,
+			"Invalid transaction ID",
+			[]string{err.Error()},
+		))
+	}
+
+	payoff, err := h.service.Settle(c.Context(), id)
+	if err != nil {
+		return h.settlementError(c, id, err, "Failed to settle transaction")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response_formatter.Success(
+		payoff,
+		"Transaction settled successfully",
+	))
+}
+
+func (h *TransactionHandler) settlementError(c *fiber.Ctx, id uuid.UUID, err error, message string) error {
+	switch err {
+	case entity.ErrTransactionNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(response_formatter.CodedError(
+			fiber.StatusNotFound,
+			"Transaction not found",
+			err,
+		))
+	case entity.ErrTransactionNotSettleable:
+		return c.Status(fiber.StatusConflict).JSON(response_formatter.CodedError(
+			fiber.StatusConflict,
+			"Transaction cannot be settled",
+			err,
+		))
+	default:
+		loggerPkg.FromContext(c.Context()).Error("failed to process early settlement",
+			zap.Error(err),
+			zap.String("transaction_id", id.String()),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response_formatter.Error(
+			fiber.StatusInternalServerError,
+			message,
+			[]string{err.Error()},
+		))
+	}
+}
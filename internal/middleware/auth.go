@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/utils/response_formatter"
+	"strings"
+)
+
+const ActorIDKey = "actor_id"
+
+type AuthConfig struct {
+	Enabled     bool
+	Secret      string
+	Issuer      string
+	PublicPaths []string //Exact paths, or prefixes when ending in "/*"
+}
+
+// JWTAuth requires an HS256 bearer token on every request except public
+// paths, and stores the token subject as the actor ID.
+func JWTAuth(cfg AuthConfig) fiber.Handler {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(cfg.Issuer))
+	}
+	parser := jwt.NewParser(parserOptions...)
+	secret := []byte(cfg.Secret)
+
+	return func(c *fiber.Ctx) error {
+		if !cfg.Enabled || isPublicPath(cfg.PublicPaths, c.Path()) {
+			return c.Next()
+		}
+
+		header := c.Get(fiber.HeaderAuthorization)
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			return unauthorized(c, "missing bearer token")
+		}
+
+		var claims jwt.RegisteredClaims
+		if _, err := parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+			return secret, nil
+		}); err != nil {
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				return unauthorized(c, "token has expired")
+			}
+			return unauthorized(c, "invalid token")
+		}
+
+		if claims.Subject == "" {
+			return unauthorized(c, "token has no subject")
+		}
+
+		c.Locals(ActorIDKey, claims.Subject)
+		if logger, ok := c.Locals(loggerPkg.ContextKey).(*zap.Logger); ok {
+			c.Locals(loggerPkg.ContextKey, logger.With(zap.String(ActorIDKey, claims.Subject)))
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireActor rejects requests that JWTAuth did not authenticate, which
+// includes every request while authentication is disabled. Use it after
+// JWTAuth on routes that must never be open.
+func RequireActor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if GetActorID(c) == "" {
+			return c.Status(fiber.StatusForbidden).JSON(response_formatter.Error(
+				fiber.StatusForbidden,
+				"Forbidden",
+				[]string{"authentication must be enabled to use this route"},
+			))
+		}
+		return c.Next()
+	}
+}
+
+// GetActorID returns the authenticated subject, or an empty string on public
+// routes and when authentication is disabled.
+func GetActorID(c *fiber.Ctx) string {
+	actorID, _ := c.Locals(ActorIDKey).(string)
+	return actorID
+}
+
+func isPublicPath(publicPaths []string, path string) bool {
+	for _, publicPath := range publicPaths {
+		if prefix, ok := strings.CutSuffix(publicPath, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if path == publicPath {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(c *fiber.Ctx, reason string) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(response_formatter.Error(
+		fiber.StatusUnauthorized,
+		"Unauthorized",
+		[]string{reason},
+	))
+}
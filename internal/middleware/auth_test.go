@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"kredit-plus/utils/response_formatter"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "test-secret"
+	testIssuer = "kredit-plus"
+)
+
+func newAuthTestApp(cfg AuthConfig) *fiber.App {
+	app := fiber.New()
+	app.Use(JWTAuth(cfg))
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/api/v1/customers", func(c *fiber.Ctx) error {
+		return c.SendString(GetActorID(c))
+	})
+	app.Get("/admin/log-level", RequireActor(), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app
+}
+
+func signToken(t *testing.T, secret string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "actor-1",
+		Issuer:    testIssuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestJWTAuthRejectsBadTokens(t *testing.T) {
+	app := newAuthTestApp(AuthConfig{Enabled: true, Secret: testSecret, Issuer: testIssuer})
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	noSubject := validClaims()
+	noSubject.Subject = ""
+
+	for _, tc := range []struct {
+		name       string
+		header     string
+		wantReason string
+	}{
+		{"missing header", "", "missing bearer token"},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", "missing bearer token"},
+		{"empty bearer token", "Bearer ", "missing bearer token"},
+		{"malformed token", "Bearer not-a-jwt", "invalid token"},
+		{"wrong secret", "Bearer " + signToken(t, "other-secret", validClaims()), "invalid token"},
+		{"wrong issuer", "Bearer " + signToken(t, testSecret, wrongIssuer), "invalid token"},
+		{"no expiry", "Bearer " + signToken(t, testSecret, noExpiry), "invalid token"},
+		{"expired token", "Bearer " + signToken(t, testSecret, expired), "token has expired"},
+		{"no subject", "Bearer " + signToken(t, testSecret, noSubject), "token has no subject"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "/api/v1/customers", nil)
+			if tc.header != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tc.header)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != fiber.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401", resp.StatusCode)
+			}
+			var body response_formatter.Response
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Code != fiber.StatusUnauthorized || len(body.Errors) != 1 || body.Errors[0] != tc.wantReason {
+				t.Errorf("body = %+v, want reason %q", body, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestJWTAuthStoresActorID(t *testing.T) {
+	app := newAuthTestApp(AuthConfig{Enabled: true, Secret: testSecret, Issuer: testIssuer})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/v1/customers", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+signToken(t, testSecret, validClaims()))
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	actorID, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if string(actorID) != "actor-1" {
+		t.Errorf("actor ID = %q, want actor-1", actorID)
+	}
+}
+
+func TestJWTAuthSkipsPublicPaths(t *testing.T) {
+	app := newAuthTestApp(AuthConfig{Enabled: true, Secret: testSecret, PublicPaths: []string{"/health"}})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/health", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRequireActorRefusesWhenAuthDisabled(t *testing.T) {
+	app := newAuthTestApp(AuthConfig{Enabled: false})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/log-level", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+}
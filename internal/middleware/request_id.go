@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	RequestIDKey    = "request_id"
+
+	maxRequestIDLength = 64
+)
+
+// RequestID reuses the caller's X-Request-ID or generates one, echoes it back,
+// and stores both the ID and a logger tagged with it on the request so that
+// loggerPkg.FromContext(c.Context()) picks it up further down the stack.
+func RequestID(logger *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		c.Locals(RequestIDKey, requestID)
+		c.Locals(loggerPkg.ContextKey, logger.With(zap.String(RequestIDKey, requestID)))
+		c.Set(RequestIDHeader, requestID)
+
+		return c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned by RequestID, or an empty string.
+func GetRequestID(c *fiber.Ctx) string {
+	requestID, _ := c.Locals(RequestIDKey).(string)
+	return requestID
+}
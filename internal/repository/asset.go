@@ -2,23 +2,31 @@ package repository
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"kredit-plus/cacher"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/infra/mysql"
 	"kredit-plus/infra/redis"
 	"kredit-plus/internal/entity"
+	"strings"
+	"time"
 )
 
 type assetRepository struct {
-	db     *mysql.Client
-	redis  *redis.Client
-	logger *zap.Logger
+	db        *mysql.Client
+	redis     *redis.Client
+	logger    *zap.Logger
+	loadGroup singleflight.Group
 }
 
 func NewAssetRepository(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) entity.AssetRepository {
@@ -40,9 +48,9 @@ func (r *assetRepository) Create(ctx context.Context, asset *entity.Asset) error
 		attribute.String("asset.category", asset.Category),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		if err := tx.Create(asset).Error; err != nil {
-			r.logger.Error("failed to create asset",
+			loggerPkg.FromContext(ctx).Error("failed to create asset",
 				zap.Error(err),
 				zap.String("asset_id", asset.ID.String()),
 			)
@@ -50,6 +58,12 @@ func (r *assetRepository) Create(ctx context.Context, asset *entity.Asset) error
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	bumpAssetListVersion(ctx, r.redis, loggerPkg.FromContext(ctx))
+	return nil
 }
 
 func (r *assetRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Asset, error) {
@@ -60,36 +74,97 @@ func (r *assetRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.As
 	span.SetAttributes(attribute.String("asset.id", id.String()))
 
 	cacheKey := cacher.GetAssetCacheKey(id)
-	var asset entity.Asset
 
-	cachedData, err := r.redis.Get(ctx, cacheKey)
-	if err == nil {
-		if err := json.Unmarshal([]byte(cachedData), &asset); err == nil {
+	//Only one caller per key loads and backfills, the rest share its result
+	shared, err := loadShared(ctx, &r.loadGroup, cacheKey, func(ctx context.Context) (*entity.Asset, error) {
+		return cacher.GetOrLoad(ctx, r.redis, cacheKey, entity.DefaultCacheTTL, func() (*entity.Asset, error) {
+			var asset entity.Asset
+			//Unscoped so retired assets still resolve for their transactions
+			if err := r.db.WithContext(ctx).Unscoped().First(&asset, "id = ?", id).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil, nil
+				}
+				loggerPkg.FromContext(ctx).Error("failed to get asset by id",
+					zap.Error(err),
+					zap.String("asset_id", id.String()),
+				)
+				return nil, fmt.Errorf("failed to get asset: %w", err)
+			}
 			return &asset, nil
-		}
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if shared == nil {
+		return nil, nil
 	}
+	asset := *shared
+
+	return &asset, nil
+}
+
+// GetByIDs returns the assets found among ids, keyed by ID. Cached assets are
+// read with a single MGET and the rest with a single IN query, which are then
+// cached. IDs that do not exist are absent from the result.
+func (r *assetRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entity.Asset, error) {
+	tr := otel.Tracer("repository.asset")
+	ctx, span := tr.Start(ctx, "GetByIDs")
+	defer span.End()
 
-	if err := r.db.WithContext(ctx).First(&asset, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, nil
+	span.SetAttributes(attribute.Int("asset.count", len(ids)))
+
+	assets := make(map[uuid.UUID]*entity.Asset, len(ids))
+	if len(ids) == 0 {
+		return assets, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = cacher.GetAssetCacheKey(id)
+	}
+
+	//A failed MGET just sends every ID to the database
+	cached, _ := r.redis.MGet(ctx, keys...)
+	var missing []uuid.UUID
+	for i, id := range ids {
+		if _, seen := assets[id]; seen {
+			continue
+		}
+		if i < len(cached) && cached[i] != "" {
+			var asset entity.Asset
+			if err := json.Unmarshal([]byte(cached[i]), &asset); err == nil {
+				assets[id] = &asset
+				continue
+			}
 		}
-		r.logger.Error("failed to get asset by id",
+		missing = append(missing, id)
+	}
+
+	span.SetAttributes(attribute.Int("cache.misses", len(missing)))
+	if len(missing) == 0 {
+		return assets, nil
+	}
+
+	var loaded []entity.Asset
+	if err := r.db.WithContext(ctx).Unscoped().Where("id IN ?", missing).Find(&loaded).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get assets by ids",
 			zap.Error(err),
-			zap.String("asset_id", id.String()),
+			zap.Int("count", len(missing)),
 		)
-		return nil, fmt.Errorf("failed to get asset: %w", err)
+		return nil, fmt.Errorf("failed to get assets: %w", err)
 	}
 
-	if assetJSON, err := json.Marshal(asset); err == nil {
-		if err := r.redis.Set(ctx, cacheKey, string(assetJSON), entity.DefaultCacheTTL); err != nil {
-			r.logger.Warn("failed to cache asset",
-				zap.Error(err),
-				zap.String("asset_id", id.String()),
-			)
+	for i := range loaded {
+		asset := &loaded[i]
+		assets[asset.ID] = asset
+		if data, err := json.Marshal(asset); err == nil {
+			_ = r.redis.SetWithJitter(ctx, cacher.GetAssetCacheKey(asset.ID), string(data), entity.DefaultCacheTTL)
 		}
 	}
 
-	return &asset, nil
+	return assets, nil
 }
 
 func (r *assetRepository) GetAllWithFilter(ctx context.Context, filter entity.AssetFilterRepository) (assets []entity.Asset, count int64, err error) {
@@ -98,9 +173,12 @@ func (r *assetRepository) GetAllWithFilter(ctx context.Context, filter entity.As
 	defer span.End()
 
 	span.SetAttributes(
+		attribute.String("filter.name", filter.Name),
 		attribute.String("filter.category", filter.Category),
 		attribute.Float64("filter.min_price", filter.MinPrice),
 		attribute.Float64("filter.max_price", filter.MaxPrice),
+		attribute.String("filter.sort_by", filter.SortBy),
+		attribute.String("filter.sort_dir", filter.SortDir),
 		attribute.Int("filter.limit", filter.Limit),
 		attribute.Int("filter.offset", filter.Offset),
 	)
@@ -109,7 +187,18 @@ func (r *assetRepository) GetAllWithFilter(ctx context.Context, filter entity.As
 		return nil, 0, fmt.Errorf("invalid pagination parameters: limit and offset must be non-negative")
 	}
 
+	cacheKey := r.assetListCacheKey(ctx, filter)
+	if cachedData, err := r.redis.Get(ctx, cacheKey); err == nil {
+		var cached cachedAssetList
+		if err := json.Unmarshal([]byte(cachedData), &cached); err == nil {
+			return cached.Assets, cached.Count, nil
+		}
+	}
+
 	query := r.db.WithContext(ctx).Model(&entity.Asset{})
+	if filter.Name != "" {
+		query = query.Where("LOWER(name) LIKE ?", "%"+escapeLike(strings.ToLower(filter.Name))+"%")
+	}
 	if filter.Category != "" {
 		query = query.Where("category = ?", filter.Category)
 	}
@@ -121,7 +210,7 @@ func (r *assetRepository) GetAllWithFilter(ctx context.Context, filter entity.As
 	}
 
 	if err = query.Count(&count).Error; err != nil {
-		r.logger.Error("failed to count assets",
+		loggerPkg.FromContext(ctx).Error("failed to count assets",
 			zap.Error(err),
 			zap.Any("filter", filter),
 		)
@@ -135,19 +224,34 @@ func (r *assetRepository) GetAllWithFilter(ctx context.Context, filter entity.As
 	if err = query.
 		Limit(filter.Limit).
 		Offset(filter.Offset).
-		Order("created_at DESC").
+		Order(clause.OrderByColumn{
+			Column: clause.Column{Name: filter.SortBy},
+			Desc:   filter.SortDir == "desc",
+		}).
 		Find(&assets).Error; err != nil {
-		r.logger.Error("failed to list assets",
+		loggerPkg.FromContext(ctx).Error("failed to list assets",
 			zap.Error(err),
 			zap.Any("filter", filter),
 		)
 		return nil, 0, fmt.Errorf("failed to list assets: %w", err)
 	}
 
+	if listJSON, err := json.Marshal(cachedAssetList{Assets: assets, Count: count}); err == nil {
+		if err := r.redis.SetWithJitter(ctx, cacheKey, string(listJSON), entity.DefaultCacheTTL); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to cache asset list",
+				zap.Error(err),
+				zap.String("cache_key", cacheKey),
+			)
+		}
+	}
+
 	return assets, count, nil
 }
 
-func (r *assetRepository) Update(ctx context.Context, asset *entity.Asset) error {
+// Update saves the asset. Transactions reserve and restore stock concurrently,
+// so the stock column is only written when updateStock is set; otherwise the
+// stored level is kept rather than overwritten with the one asset was read with.
+func (r *assetRepository) Update(ctx context.Context, asset *entity.Asset, updateStock bool) error {
 	tr := otel.Tracer("repository.asset")
 	ctx, span := tr.Start(ctx, "Update")
 	defer span.End()
@@ -157,25 +261,54 @@ func (r *assetRepository) Update(ctx context.Context, asset *entity.Asset) error
 		attribute.String("asset.name", asset.Name),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		if err := tx.Save(asset).Error; err != nil {
-			r.logger.Error("failed to update asset",
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		var current entity.Asset
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("price", "version").
+			First(&current, "id = ?", asset.ID).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to get asset for update",
 				zap.Error(err),
 				zap.String("asset_id", asset.ID.String()),
 			)
-			return fmt.Errorf("failed to update asset: %w", err)
+			return fmt.Errorf("failed to get asset for update: %w", err)
 		}
 
-		cacheKey := cacher.GetAssetCacheKey(asset.ID)
-		if err := r.redis.Del(ctx, cacheKey); err != nil {
-			r.logger.Warn("failed to invalidate asset cache",
+		//Someone else saved since asset was read
+		if current.Version != asset.Version {
+			return entity.ErrStaleWrite
+		}
+		asset.Version++
+
+		save := tx
+		if !updateStock {
+			save = tx.Omit("stock")
+		}
+		if err := save.Save(asset).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to update asset",
 				zap.Error(err),
-				zap.String("cache_key", cacheKey),
+				zap.String("asset_id", asset.ID.String()),
 			)
+			return fmt.Errorf("failed to update asset: %w", err)
+		}
+
+		if current.Price != asset.Price {
+			if err := recordAssetPriceChange(tx, asset.ID, current.Price, asset.Price, asset.UpdatedAt); err != nil {
+				loggerPkg.FromContext(ctx).Error("failed to record asset price change",
+					zap.Error(err),
+					zap.String("asset_id", asset.ID.String()),
+				)
+				return err
+			}
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	invalidateAssetCache(ctx, r.redis, loggerPkg.FromContext(ctx), asset.ID)
+	return nil
 }
 
 func (r *assetRepository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -185,13 +318,13 @@ func (r *assetRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	span.SetAttributes(attribute.String("asset.id", id.String()))
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		var asset entity.Asset
 		if err := tx.First(&asset, "id = ?", id).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				return fmt.Errorf("asset not found")
+				return entity.ErrAssetNotFound
 			}
-			r.logger.Error("failed to get asset for deletion",
+			loggerPkg.FromContext(ctx).Error("failed to get asset for deletion",
 				zap.Error(err),
 				zap.String("asset_id", id.String()),
 			)
@@ -200,33 +333,137 @@ func (r *assetRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 		var transactionCount int64
 		if err := tx.Model(&entity.Transaction{}).Where("asset_id = ?", id).Count(&transactionCount).Error; err != nil {
-			r.logger.Error("failed to check asset transactions",
+			loggerPkg.FromContext(ctx).Error("failed to check asset transactions",
 				zap.Error(err),
 				zap.String("asset_id", id.String()),
 			)
 			return fmt.Errorf("failed to check asset transactions: %w", err)
 		}
 
-		if transactionCount > 0 {
-			return fmt.Errorf("cannot delete asset: asset is used in %d transactions", transactionCount)
+		//Assets referenced by transactions are retired (soft deleted) so the
+		//transactions can still resolve them; unreferenced ones are removed
+		query := tx
+		if transactionCount == 0 {
+			query = tx.Unscoped()
 		}
 
-		if err := tx.Delete(&asset).Error; err != nil {
-			r.logger.Error("failed to delete asset",
+		if err := query.Delete(&asset).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to delete asset",
 				zap.Error(err),
 				zap.String("asset_id", id.String()),
 			)
 			return fmt.Errorf("failed to delete asset: %w", err)
 		}
 
-		cacheKey := cacher.GetAssetCacheKey(id)
-		if err := r.redis.Del(ctx, cacheKey); err != nil {
-			r.logger.Warn("failed to invalidate asset cache",
-				zap.Error(err),
-				zap.String("asset_id", id.String()),
-			)
-		}
+		loggerPkg.FromContext(ctx).Info("asset deleted",
+			zap.String("asset_id", id.String()),
+			zap.Bool("retired", transactionCount > 0),
+			zap.Int64("transaction_count", transactionCount),
+		)
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	invalidateAssetCache(ctx, r.redis, loggerPkg.FromContext(ctx), id)
+	return nil
+}
+
+func (r *assetRepository) GetPriceHistory(ctx context.Context, assetID uuid.UUID, limit, offset int) ([]entity.AssetPriceHistory, int64, error) {
+	tr := otel.Tracer("repository.asset")
+	ctx, span := tr.Start(ctx, "GetPriceHistory")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("asset.id", assetID.String()),
+		attribute.Int("limit", limit),
+		attribute.Int("offset", offset),
+	)
+
+	query := r.db.WithContext(ctx).
+		Model(&entity.AssetPriceHistory{}).
+		Where("asset_id = ?", assetID)
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to count asset price history",
+			zap.Error(err),
+			zap.String("asset_id", assetID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to count price history: %w", err)
+	}
+
+	var entries []entity.AssetPriceHistory
+	if err := query.
+		Order("changed_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get asset price history",
+			zap.Error(err),
+			zap.String("asset_id", assetID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get price history: %w", err)
+	}
+
+	return entries, count, nil
+}
+
+// recordAssetPriceChange appends a price history entry for a price change that
+// is being saved on tx. It must run on the same tx as that change.
+func recordAssetPriceChange(tx *gorm.DB, assetID uuid.UUID, oldPrice, newPrice float64, changedAt time.Time) error {
+	entry := &entity.AssetPriceHistory{
+		ID:        uuid.New(),
+		AssetID:   assetID,
+		OldPrice:  oldPrice,
+		NewPrice:  newPrice,
+		ChangedAt: changedAt,
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record asset price history: %w", err)
+	}
+
+	return nil
+}
+
+type cachedAssetList struct {
+	Assets []entity.Asset `json:"assets"`
+	Count  int64          `json:"count"`
+}
+
+// assetListCacheKey builds the list cache key for the filter under the current
+// assets version. A missing version key is treated as version 0.
+func (r *assetRepository) assetListCacheKey(ctx context.Context, filter entity.AssetFilterRepository) string {
+	version, err := r.redis.Get(ctx, cacher.GetAssetListVersionCacheKey())
+	if err != nil {
+		version = "0"
+	}
+
+	filterJSON, _ := json.Marshal(filter)
+	hash := sha1.Sum(filterJSON)
+	return cacher.GetAssetListCacheKey(version, hex.EncodeToString(hash[:]))
+}
+
+func bumpAssetListVersion(ctx context.Context, redisClient *redis.Client, logger *zap.Logger) {
+	if _, err := redisClient.Incr(ctx, cacher.GetAssetListVersionCacheKey()); err != nil {
+		logger.Warn("failed to bump asset list cache version", zap.Error(err))
+	}
+}
+
+// invalidateAssetCache drops the cached asset and every cached list page once
+// a change is committed. It is also used by writers outside assetRepository
+// that change an asset, such as stock movements from transactions.
+func invalidateAssetCache(ctx context.Context, redisClient *redis.Client, logger *zap.Logger, assetID uuid.UUID) {
+	cacheKey := cacher.GetAssetCacheKey(assetID)
+	if err := redisClient.Del(ctx, cacheKey); err != nil {
+		logger.Warn("failed to invalidate asset cache",
+			zap.Error(err),
+			zap.String("asset_id", assetID.String()),
+		)
+	}
+
+	bumpAssetListVersion(ctx, redisClient, logger)
 }
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/cacher"
+	"kredit-plus/internal/entity"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestAssetRepository(t *testing.T) (*assetRepository, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	db, mock := newMockDB(t)
+	redisClient, server := newTestRedis(t)
+
+	repo := NewAssetRepository(db, redisClient, zap.NewNop())
+	return repo.(*assetRepository), mock, server
+}
+
+func assetRows(assets ...entity.Asset) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "category", "price", "stock"})
+	for _, asset := range assets {
+		rows.AddRow(asset.ID.String(), asset.Name, asset.Category, asset.Price, asset.Stock)
+	}
+	return rows
+}
+
+func TestAssetRepositoryNameFilterMatchesMidWord(t *testing.T) {
+	repo, mock, _ := newTestAssetRepository(t)
+	smartphone := entity.Asset{ID: uuid.New(), Name: "Smartphone X", Category: "white_goods", Price: 300, Stock: 1}
+
+	//LIKE '%phone%' matches "Smartphone X" in the middle of the word
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `assets` WHERE LOWER\\(name\\) LIKE \\?").
+		WithArgs("%phone%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `assets` WHERE LOWER\\(name\\) LIKE \\?").
+		WithArgs("%phone%", 10).
+		WillReturnRows(assetRows(smartphone))
+
+	assets, count, err := repo.GetAllWithFilter(context.Background(), entity.AssetFilterRequest{
+		Name:  "PHONE",
+		Limit: 10,
+	}.ToAssetFilterRepo())
+	if err != nil {
+		t.Fatalf("GetAllWithFilter returned error: %v", err)
+	}
+	if count != 1 || len(assets) != 1 || assets[0].Name != smartphone.Name {
+		t.Errorf("got %d of %d assets %+v, want %q", len(assets), count, assets, smartphone.Name)
+	}
+}
+
+func TestAssetRepositoryNameFilterEscapesWildcards(t *testing.T) {
+	repo, mock, _ := newTestAssetRepository(t)
+
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `assets` WHERE LOWER\\(name\\) LIKE \\?").
+		WithArgs(`%50\%\_off%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT \\* FROM `assets` WHERE LOWER\\(name\\) LIKE \\?").
+		WithArgs(`%50\%\_off%`, 10).
+		WillReturnRows(assetRows())
+
+	if _, _, err := repo.GetAllWithFilter(context.Background(), entity.AssetFilterRequest{
+		Name:  "50%_OFF",
+		Limit: 10,
+	}.ToAssetFilterRepo()); err != nil {
+		t.Fatalf("GetAllWithFilter returned error: %v", err)
+	}
+}
+
+func TestAssetRepositorySortsByPriceAscending(t *testing.T) {
+	repo, mock, _ := newTestAssetRepository(t)
+	cheap := entity.Asset{ID: uuid.New(), Name: "Kipas Angin", Category: "white_goods", Price: 150, Stock: 3}
+	pricey := entity.Asset{ID: uuid.New(), Name: "Kulkas", Category: "white_goods", Price: 4500, Stock: 2}
+
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `assets`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("SELECT \\* FROM `assets` WHERE `assets`.`deleted_at` IS NULL ORDER BY `price` LIMIT \\?$").
+		WillReturnRows(assetRows(cheap, pricey))
+
+	assets, _, err := repo.GetAllWithFilter(context.Background(), entity.AssetFilterRequest{
+		SortBy:  "price",
+		SortDir: "asc",
+		Limit:   10,
+	}.ToAssetFilterRepo())
+	if err != nil {
+		t.Fatalf("GetAllWithFilter returned error: %v", err)
+	}
+	if len(assets) != 2 || assets[0].ID != cheap.ID {
+		t.Errorf("first asset = %+v, want the cheapest %q", assets, cheap.Name)
+	}
+}
+
+func TestAssetRepositoryIgnoresUnknownSortColumn(t *testing.T) {
+	repo, mock, _ := newTestAssetRepository(t)
+
+	//An unlisted column falls back to the default column instead of reaching SQL
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `assets`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `assets` WHERE `assets`.`deleted_at` IS NULL ORDER BY `created_at` LIMIT \\?$").
+		WillReturnRows(assetRows(entity.Asset{ID: uuid.New()}))
+
+	if _, _, err := repo.GetAllWithFilter(context.Background(), entity.AssetFilterRequest{
+		SortBy:  "price; DROP TABLE assets",
+		SortDir: "asc",
+		Limit:   10,
+	}.ToAssetFilterRepo()); err != nil {
+		t.Fatalf("GetAllWithFilter returned error: %v", err)
+	}
+}
+
+func TestAssetRepositoryCreateBumpsListVersion(t *testing.T) {
+	repo, mock, server := newTestAssetRepository(t)
+	filter := entity.AssetFilterRequest{Limit: 10}.ToAssetFilterRepo()
+	existing := entity.Asset{ID: uuid.New(), Name: "Kulkas", Category: "white_goods", Price: 4500, Stock: 2}
+	created := entity.Asset{ID: uuid.New(), Name: "Kipas Angin", Category: "white_goods", Price: 150, Stock: 3}
+
+	expectList := func(assets ...entity.Asset) {
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM `assets`").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(assets)))
+		mock.ExpectQuery("SELECT \\* FROM `assets`").
+			WillReturnRows(assetRows(assets...))
+	}
+
+	//The second read is served from the page cached under version 0
+	expectList(existing)
+	for i := 0; i < 2; i++ {
+		if _, _, err := repo.GetAllWithFilter(context.Background(), filter); err != nil {
+			t.Fatalf("GetAllWithFilter returned error: %v", err)
+		}
+	}
+	staleKey := repo.assetListCacheKey(context.Background(), filter)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `assets`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := repo.Create(context.Background(), &created); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	if version, err := server.Get(cacher.GetAssetListVersionCacheKey()); err != nil || version != "1" {
+		t.Errorf("list version = %q (%v), want 1", version, err)
+	}
+	if key := repo.assetListCacheKey(context.Background(), filter); key == staleKey {
+		t.Fatalf("list cache key %s did not change after create", key)
+	}
+
+	expectList(created, existing)
+	assets, count, err := repo.GetAllWithFilter(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetAllWithFilter after create returned error: %v", err)
+	}
+	if count != 2 || len(assets) != 2 {
+		t.Errorf("got %d of %d assets after create, want the new page of 2", len(assets), count)
+	}
+}
+
+func TestAssetRepositoryUpdateKeepsReservedStock(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		updateStock bool
+		wantSQL     string
+	}{
+		{
+			//A transaction reserved a unit after the asset was read with stock 2, so
+			//writing the stale level back would hand the unit out twice
+			name:    "edit without stock",
+			wantSQL: "UPDATE `assets` SET `name`=\\?,`category`=\\?,`description`=\\?,`price`=\\?,`version`=\\?,`created_at`=\\?,`updated_at`=\\?,`deleted_at`=\\? WHERE",
+		},
+		{
+			name:        "restock",
+			updateStock: true,
+			wantSQL:     "UPDATE `assets` SET `name`=\\?,`category`=\\?,`description`=\\?,`price`=\\?,`stock`=\\?,`version`=\\?,`created_at`=\\?,`updated_at`=\\?,`deleted_at`=\\? WHERE",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock, _ := newTestAssetRepository(t)
+			asset := entity.Asset{ID: uuid.New(), Name: "Kulkas", Category: "white_goods", Price: 4750, Stock: 2}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT `price`,`version` FROM `assets` WHERE id = \\? .* FOR UPDATE").
+				WillReturnRows(sqlmock.NewRows([]string{"price", "version"}).AddRow(asset.Price, asset.Version))
+			mock.ExpectExec(tc.wantSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if err := repo.Update(context.Background(), &asset, tc.updateStock); err != nil {
+				t.Fatalf("Update returned error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAssetRepositoryGetByIDLoadsColdKeyOnce(t *testing.T) {
+	repo, mock, _ := newTestAssetRepository(t)
+	asset := entity.Asset{ID: uuid.New(), Name: "Kulkas", Category: "white_goods", Price: 4500, Stock: 2}
+	//Expected once; a second load would fail as an unexpected query
+	mock.ExpectQuery("SELECT \\* FROM `assets` WHERE id = \\?").
+		WithArgs(asset.ID, 1).
+		WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(assetRows(asset))
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			got, err := repo.GetByID(context.Background(), asset.ID)
+			if err == nil && (got == nil || got.ID != asset.ID) {
+				err = errors.New("GetByID returned the wrong asset")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetByID returned error: %v", err)
+		}
+	}
+}
+
+func TestAssetRepositoryUpdateRejectsSecondOfTwoConcurrentEdits(t *testing.T) {
+	repo, mock, _ := newTestAssetRepository(t)
+	id := uuid.New()
+	//Both requests read the asset at version 1 before either saves
+	first := &entity.Asset{ID: id, Name: "Phone", Price: 1000, Version: 1}
+	second := &entity.Asset{ID: id, Name: "Phone X", Price: 1000, Version: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `price`,`version` FROM `assets` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"price", "version"}).AddRow(1000.0, 1))
+	mock.ExpectExec("UPDATE `assets`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `price`,`version` FROM `assets` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"price", "version"}).AddRow(1000.0, 2))
+	mock.ExpectRollback()
+
+	if err := repo.Update(context.Background(), first, false); err != nil {
+		t.Fatalf("first Update returned error: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("first Update left version %d, want 2", first.Version)
+	}
+	if err := repo.Update(context.Background(), second, false); err != entity.ErrStaleWrite {
+		t.Fatalf("second Update returned %v, want ErrStaleWrite", err)
+	}
+}
+
+func TestAssetRepositoryDeleteSeparatesNotFoundFromFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		queryErr     error
+		wantNotFound bool
+	}{
+		{"missing asset", nil, true},
+		{"database down", errors.New("dial tcp: connection refused"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock, _ := newTestAssetRepository(t)
+			id := uuid.New()
+
+			mock.ExpectBegin()
+			query := mock.ExpectQuery("SELECT \\* FROM `assets` WHERE id = \\?")
+			if tc.queryErr != nil {
+				query.WillReturnError(tc.queryErr)
+			} else {
+				query.WillReturnRows(assetRows())
+			}
+			mock.ExpectRollback()
+
+			err := repo.Delete(context.Background(), id)
+			if err == nil {
+				t.Fatal("Delete returned no error")
+			}
+			if errors.Is(err, entity.ErrAssetNotFound) != tc.wantNotFound {
+				t.Errorf("Delete returned %v, want not found %v", err, tc.wantNotFound)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
@@ -9,18 +10,27 @@ import (
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"kredit-plus/cacher"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/infra/mysql"
+	"kredit-plus/infra/redis"
 	"kredit-plus/internal/entity"
+	"math"
+	"time"
 )
 
 type creditLimitRepository struct {
 	db     *mysql.Client
+	redis  *redis.Client
+	clock  entity.Clock
 	logger *zap.Logger
 }
 
-func NewCreditLimitRepository(db *mysql.Client, logger *zap.Logger) entity.CreditLimitRepository {
+func NewCreditLimitRepository(db *mysql.Client, redisClient *redis.Client, clock entity.Clock, logger *zap.Logger) entity.CreditLimitRepository {
 	return &creditLimitRepository{
 		db:     db,
+		redis:  redisClient,
+		clock:  clock,
 		logger: logger,
 	}
 }
@@ -37,9 +47,12 @@ func (r *creditLimitRepository) Create(ctx context.Context, limit *entity.Credit
 		attribute.Float64("limit_amount", limit.LimitAmount),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		if err := tx.Create(limit).Error; err != nil {
-			r.logger.Error("failed to create credit limit",
+			if mysql.IsDuplicateKeyError(err) {
+				return entity.ErrDuplicateCreditLimit
+			}
+			loggerPkg.FromContext(ctx).Error("failed to create credit limit",
 				zap.Error(err),
 				zap.String("customer_id", limit.CustomerID.String()),
 				zap.Int("tenor_month", limit.TenorMonth),
@@ -48,6 +61,13 @@ func (r *creditLimitRepository) Create(ctx context.Context, limit *entity.Credit
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), limit)
+
+	return nil
 }
 
 func (r *creditLimitRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.CreditLimit, error) {
@@ -57,18 +77,36 @@ func (r *creditLimitRepository) GetByID(ctx context.Context, id uuid.UUID) (*ent
 
 	span.SetAttributes(attribute.String("credit_limit.id", id.String()))
 
+	cacheKey := cacher.GetCreditLimitCacheKey(id)
 	var limit entity.CreditLimit
+
+	cachedData, err := r.redis.Get(ctx, cacheKey)
+	if err == nil {
+		if err := json.Unmarshal([]byte(cachedData), &limit); err == nil {
+			return &limit, nil
+		}
+	}
+
 	if err := r.db.WithContext(ctx).First(&limit, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
-		r.logger.Error("failed to get credit limit by id",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit by id",
 			zap.Error(err),
 			zap.String("credit_limit_id", id.String()),
 		)
 		return nil, fmt.Errorf("failed to get credit limit: %w", err)
 	}
 
+	if limitJSON, err := json.Marshal(limit); err == nil {
+		if err := r.redis.SetWithJitter(ctx, cacheKey, string(limitJSON), entity.DefaultCacheTTL); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to cache credit limit",
+				zap.Error(err),
+				zap.String("credit_limit_id", id.String()),
+			)
+		}
+	}
+
 	return &limit, nil
 }
 
@@ -89,7 +127,7 @@ func (r *creditLimitRepository) GetByCustomerIDAndTenor(ctx context.Context, cus
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
-		r.logger.Error("failed to get credit limit by customer id and tenor",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit by customer id and tenor",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 			zap.Int("tenor_month", tenorMonth),
@@ -107,22 +145,91 @@ func (r *creditLimitRepository) GetAllByCustomerID(ctx context.Context, customer
 
 	span.SetAttributes(attribute.String("customer.id", customerID.String()))
 
+	cacheKey := cacher.GetCustomerCreditLimitsCacheKey(customerID)
 	var limits []entity.CreditLimit
+
+	cachedData, err := r.redis.Get(ctx, cacheKey)
+	if err == nil {
+		if err := json.Unmarshal([]byte(cachedData), &limits); err == nil {
+			return limits, nil
+		}
+	}
+
 	if err := r.db.WithContext(ctx).
 		Where("customer_id = ?", customerID).
 		Order("tenor_month ASC").
 		Find(&limits).Error; err != nil {
-		r.logger.Error("failed to get credit limits by customer id",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limits by customer id",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
 		return nil, fmt.Errorf("failed to get credit limits: %w", err)
 	}
 
+	if limitsJSON, err := json.Marshal(limits); err == nil {
+		if err := r.redis.SetWithJitter(ctx, cacheKey, string(limitsJSON), entity.DefaultCacheTTL); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to cache customer credit limits",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+			)
+		}
+	}
+
 	return limits, nil
 }
 
-func (r *creditLimitRepository) UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64) error {
+func (r *creditLimitRepository) GetAll(ctx context.Context, filter entity.CreditLimitFilterRepository) (limits []entity.CreditLimit, count int64, err error) {
+	tr := otel.Tracer("repository.credit_limit")
+	ctx, span := tr.Start(ctx, "GetAll")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("filter.tenor_month", filter.TenorMonth),
+		attribute.Float64("filter.min_utilization", filter.MinUtilization),
+		attribute.Int("filter.limit", filter.Limit),
+		attribute.Int("filter.offset", filter.Offset),
+	)
+
+	if filter.Limit <= 0 || filter.Limit > 100 || filter.Offset < 0 {
+		return nil, 0, fmt.Errorf("invalid pagination parameters: limit must be between 1 and 100 and offset non-negative")
+	}
+
+	query := r.db.WithReplica(ctx).Model(&entity.CreditLimit{})
+	if filter.TenorMonth > 0 {
+		query = query.Where("tenor_month = ?", filter.TenorMonth)
+	}
+	if filter.MinUtilization > 0 {
+		query = query.Where("limit_amount > 0 AND used_amount * 100 >= limit_amount * ?", filter.MinUtilization)
+	}
+
+	if err = query.Count(&count).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to count credit limits",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return nil, 0, fmt.Errorf("failed to count credit limits: %w", err)
+	}
+
+	if count > 0 && filter.Offset >= int(count) {
+		return []entity.CreditLimit{}, count, nil
+	}
+
+	if err = query.
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Order("created_at DESC").
+		Find(&limits).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to list credit limits",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return nil, 0, fmt.Errorf("failed to list credit limits: %w", err)
+	}
+
+	return limits, count, nil
+}
+
+func (r *creditLimitRepository) UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64, reason string) error {
 	tr := otel.Tracer("repository.credit_limit")
 	ctx, span := tr.Start(ctx, "UpdateUsedAmount")
 	defer span.End()
@@ -132,11 +239,11 @@ func (r *creditLimitRepository) UpdateUsedAmount(ctx context.Context, id uuid.UU
 		attribute.Float64("amount", amount),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		var limit entity.CreditLimit
+	var limit entity.CreditLimit
+	err := r.db.TransactionWithRetry(ctx, func(tx *gorm.DB) error {
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 			First(&limit, "id = ?", id).Error; err != nil {
-			r.logger.Error("failed to get credit limit for update",
+			loggerPkg.FromContext(ctx).Error("failed to get credit limit for update",
 				zap.Error(err),
 				zap.String("credit_limit_id", id.String()),
 			)
@@ -150,15 +257,79 @@ func (r *creditLimitRepository) UpdateUsedAmount(ctx context.Context, id uuid.UU
 
 		limit.UsedAmount += amount
 		if err := tx.Save(&limit).Error; err != nil {
-			r.logger.Error("failed to update credit limit used amount",
+			loggerPkg.FromContext(ctx).Error("failed to update credit limit used amount",
 				zap.Error(err),
 				zap.String("credit_limit_id", id.String()),
 			)
 			return fmt.Errorf("failed to update credit limit used amount: %w", err)
 		}
 
+		if err := recordCreditLimitChange(tx, &limit, amount, reason, r.clock.Now()); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to record credit limit ledger entry",
+				zap.Error(err),
+				zap.String("credit_limit_id", id.String()),
+			)
+			return err
+		}
+
+		return nil
+	}, mysql.DefaultMaxRetries)
+	if err != nil {
+		return err
+	}
+
+	invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &limit)
+
+	return nil
+}
+
+func (r *creditLimitRepository) UpdateLimitAmount(ctx context.Context, id uuid.UUID, newLimit float64) (*entity.CreditLimit, error) {
+	tr := otel.Tracer("repository.credit_limit")
+	ctx, span := tr.Start(ctx, "UpdateLimitAmount")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("credit_limit.id", id.String()),
+		attribute.Float64("limit_amount", newLimit),
+	)
+
+	var limit entity.CreditLimit
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&limit, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrCreditLimitNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get credit limit for limit update",
+				zap.Error(err),
+				zap.String("credit_limit_id", id.String()),
+			)
+			return fmt.Errorf("failed to get credit limit for update: %w", err)
+		}
+
+		if newLimit < limit.UsedAmount {
+			return entity.ErrLimitBelowUsedAmount
+		}
+
+		limit.LimitAmount = newLimit
+		limit.UpdatedAt = r.clock.Now()
+		if err := tx.Save(&limit).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to update credit limit amount",
+				zap.Error(err),
+				zap.String("credit_limit_id", id.String()),
+			)
+			return fmt.Errorf("failed to update credit limit amount: %w", err)
+		}
+
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &limit)
+
+	return &limit, nil
 }
 
 func (r *creditLimitRepository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -168,13 +339,13 @@ func (r *creditLimitRepository) Delete(ctx context.Context, id uuid.UUID) error
 
 	span.SetAttributes(attribute.String("credit_limit.id", id.String()))
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		var limit entity.CreditLimit
+	var limit entity.CreditLimit
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		if err := tx.First(&limit, "id = ?", id).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return fmt.Errorf("credit limit not found")
 			}
-			r.logger.Error("failed to get credit limit for deletion",
+			loggerPkg.FromContext(ctx).Error("failed to get credit limit for deletion",
 				zap.Error(err),
 				zap.String("credit_limit_id", id.String()),
 			)
@@ -186,7 +357,7 @@ func (r *creditLimitRepository) Delete(ctx context.Context, id uuid.UUID) error
 		}
 
 		if err := tx.Delete(&limit).Error; err != nil {
-			r.logger.Error("failed to delete credit limit",
+			loggerPkg.FromContext(ctx).Error("failed to delete credit limit",
 				zap.Error(err),
 				zap.String("credit_limit_id", id.String()),
 			)
@@ -195,4 +366,182 @@ func (r *creditLimitRepository) Delete(ctx context.Context, id uuid.UUID) error
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &limit)
+
+	return nil
+}
+
+func (r *creditLimitRepository) GetLedger(ctx context.Context, creditLimitID uuid.UUID, limit, offset int) ([]entity.CreditLimitLedger, int64, error) {
+	tr := otel.Tracer("repository.credit_limit")
+	ctx, span := tr.Start(ctx, "GetLedger")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("credit_limit.id", creditLimitID.String()),
+		attribute.Int("limit", limit),
+		attribute.Int("offset", offset),
+	)
+
+	query := r.db.WithContext(ctx).
+		Model(&entity.CreditLimitLedger{}).
+		Where("credit_limit_id = ?", creditLimitID)
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to count credit limit ledger entries",
+			zap.Error(err),
+			zap.String("credit_limit_id", creditLimitID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to count ledger entries: %w", err)
+	}
+
+	var entries []entity.CreditLimitLedger
+	if err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit ledger entries",
+			zap.Error(err),
+			zap.String("credit_limit_id", creditLimitID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get ledger entries: %w", err)
+	}
+
+	return entries, count, nil
+}
+
+// RecalculateUsedAmount rebuilds the used amount from the totals of the customer's
+// non-cancelled transactions for the tenor, minus the interest waived by early
+// settlements (taken from the ledger), and records any correction in the ledger.
+func (r *creditLimitRepository) RecalculateUsedAmount(ctx context.Context, creditLimitID uuid.UUID) (*entity.CreditLimitRecalculation, error) {
+	tr := otel.Tracer("repository.credit_limit")
+	ctx, span := tr.Start(ctx, "RecalculateUsedAmount")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("credit_limit.id", creditLimitID.String()))
+
+	var recalculation *entity.CreditLimitRecalculation
+	var limit entity.CreditLimit
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&limit, "id = ?", creditLimitID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrCreditLimitNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get credit limit for recalculation",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimitID.String()),
+			)
+			return fmt.Errorf("failed to get credit limit for recalculation: %w", err)
+		}
+
+		var financed struct{ Total float64 }
+		if err := tx.Model(&entity.Transaction{}).
+			Select("COALESCE(SUM(otr_amount + admin_fee + interest_amount), 0) AS total").
+			Where("customer_id = ? AND tenor_month = ? AND status <> ?",
+				limit.CustomerID, limit.TenorMonth, entity.TransactionStatusCancelled).
+			Scan(&financed).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to sum transactions for recalculation",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimitID.String()),
+			)
+			return fmt.Errorf("failed to sum transactions: %w", err)
+		}
+
+		var waived struct{ Total float64 }
+		if err := tx.Model(&entity.CreditLimitLedger{}).
+			Select("COALESCE(SUM(delta), 0) AS total").
+			Where("credit_limit_id = ? AND reason LIKE ?", creditLimitID, entity.LedgerReasonSettlementPrefix+"%").
+			Scan(&waived).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to sum settlement releases for recalculation",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimitID.String()),
+			)
+			return fmt.Errorf("failed to sum settlement releases: %w", err)
+		}
+
+		previousUsed := limit.UsedAmount
+		usedAmount := math.Max(math.Round((financed.Total+waived.Total)*100)/100, 0)
+		recalculation = &entity.CreditLimitRecalculation{
+			CreditLimitID:      limit.ID,
+			PreviousUsedAmount: previousUsed,
+			UsedAmount:         usedAmount,
+			Difference:         math.Round((usedAmount-previousUsed)*100) / 100,
+		}
+
+		if recalculation.Difference == 0 {
+			return nil
+		}
+
+		limit.UsedAmount = usedAmount
+		limit.UpdatedAt = r.clock.Now()
+		if err := tx.Save(&limit).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to save recalculated used amount",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimitID.String()),
+			)
+			return fmt.Errorf("failed to save recalculated used amount: %w", err)
+		}
+
+		if err := recordCreditLimitChange(tx, &limit, recalculation.Difference, entity.LedgerReasonRecalculation, r.clock.Now()); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to record credit limit ledger entry",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimitID.String()),
+			)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if recalculation.Difference != 0 {
+		invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &limit)
+	}
+
+	return recalculation, nil
+}
+
+// recordCreditLimitChange appends a ledger entry for a used amount change that has
+// already been applied to limit. It must run on the same tx as that change.
+func recordCreditLimitChange(tx *gorm.DB, limit *entity.CreditLimit, delta float64, reason string, now time.Time) error {
+	entry := &entity.CreditLimitLedger{
+		ID:            uuid.New(),
+		CreditLimitID: limit.ID,
+		Delta:         delta,
+		Reason:        reason,
+		BalanceAfter:  limit.UsedAmount,
+		CreatedAt:     now,
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record credit limit ledger entry: %w", err)
+	}
+
+	return nil
+}
+
+// invalidateCreditLimitCache drops both the per-id and the per-customer entries of a
+// credit limit. It is shared with the transaction repository, which adjusts used
+// amounts directly inside its own database transactions.
+func invalidateCreditLimitCache(ctx context.Context, redisClient *redis.Client, logger *zap.Logger, limit *entity.CreditLimit) {
+	cacheKeys := []string{
+		cacher.GetCreditLimitCacheKey(limit.ID),
+		cacher.GetCustomerCreditLimitsCacheKey(limit.CustomerID),
+	}
+
+	if err := redisClient.Del(ctx, cacheKeys...); err != nil {
+		logger.Warn("failed to invalidate credit limit cache",
+			zap.Error(err),
+			zap.String("credit_limit_id", limit.ID.String()),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
 }
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/cacher"
+	"kredit-plus/internal/entity"
+	"testing"
+)
+
+func newTestCreditLimitRepository(t *testing.T) (*creditLimitRepository, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	db, mock := newMockDB(t)
+	redisClient, server := newTestRedis(t)
+
+	repo := NewCreditLimitRepository(db, redisClient, fixedClock{now: testNow}, zap.NewNop())
+	return repo.(*creditLimitRepository), mock, server
+}
+
+func creditLimitRows(limit entity.CreditLimit) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "customer_id", "tenor_month", "limit_amount", "used_amount"}).
+		AddRow(limit.ID.String(), limit.CustomerID.String(), limit.TenorMonth, limit.LimitAmount, limit.UsedAmount)
+}
+
+// seedCreditLimitCache warms both keys a credit limit is cached under.
+func seedCreditLimitCache(t *testing.T, server *miniredis.Miniredis, limit entity.CreditLimit) {
+	t.Helper()
+
+	limitJSON, _ := json.Marshal(limit)
+	limitsJSON, _ := json.Marshal([]entity.CreditLimit{limit})
+	server.Set(cacher.GetCreditLimitCacheKey(limit.ID), string(limitJSON))
+	server.Set(cacher.GetCustomerCreditLimitsCacheKey(limit.CustomerID), string(limitsJSON))
+}
+
+func assertCreditLimitCacheCleared(t *testing.T, server *miniredis.Miniredis, limit entity.CreditLimit) {
+	t.Helper()
+
+	for _, key := range []string{
+		cacher.GetCreditLimitCacheKey(limit.ID),
+		cacher.GetCustomerCreditLimitsCacheKey(limit.CustomerID),
+	} {
+		if server.Exists(key) {
+			t.Errorf("cache key %s survived the write", key)
+		}
+	}
+}
+
+func TestCreditLimitRepositoryGetByIDReadsThroughCache(t *testing.T) {
+	repo, mock, server := newTestCreditLimitRepository(t)
+	limit := entity.CreditLimit{ID: uuid.New(), CustomerID: uuid.New(), TenorMonth: 3, LimitAmount: 1000, UsedAmount: 200}
+
+	mock.ExpectQuery("SELECT \\* FROM `credit_limits` WHERE id = \\?").
+		WithArgs(limit.ID, 1).
+		WillReturnRows(creditLimitRows(limit))
+
+	for i := 0; i < 2; i++ {
+		got, err := repo.GetByID(context.Background(), limit.ID)
+		if err != nil {
+			t.Fatalf("GetByID returned error: %v", err)
+		}
+		if got.UsedAmount != limit.UsedAmount {
+			t.Errorf("UsedAmount = %v, want %v", got.UsedAmount, limit.UsedAmount)
+		}
+	}
+	if !server.Exists(cacher.GetCreditLimitCacheKey(limit.ID)) {
+		t.Error("credit limit was not cached")
+	}
+}
+
+func TestCreditLimitRepositoryCreateInvalidatesCache(t *testing.T) {
+	repo, mock, server := newTestCreditLimitRepository(t)
+	limit := entity.CreditLimit{ID: uuid.New(), CustomerID: uuid.New(), TenorMonth: 3, LimitAmount: 1000}
+	seedCreditLimitCache(t, server, limit)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `credit_limits`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Create(context.Background(), &limit); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	assertCreditLimitCacheCleared(t, server, limit)
+}
+
+func TestCreditLimitRepositoryUpdateUsedAmountInvalidatesCache(t *testing.T) {
+	repo, mock, server := newTestCreditLimitRepository(t)
+	limit := entity.CreditLimit{ID: uuid.New(), CustomerID: uuid.New(), TenorMonth: 3, LimitAmount: 1000}
+	seedCreditLimitCache(t, server, limit)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `credit_limits` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(creditLimitRows(limit))
+	mock.ExpectExec("UPDATE `credit_limits`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `credit_limit_ledgers`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.UpdateUsedAmount(context.Background(), limit.ID, 300, "test"); err != nil {
+		t.Fatalf("UpdateUsedAmount returned error: %v", err)
+	}
+	assertCreditLimitCacheCleared(t, server, limit)
+}
+
+func TestCreditLimitRepositoryDeleteInvalidatesCache(t *testing.T) {
+	repo, mock, server := newTestCreditLimitRepository(t)
+	limit := entity.CreditLimit{ID: uuid.New(), CustomerID: uuid.New(), TenorMonth: 3, LimitAmount: 1000}
+	seedCreditLimitCache(t, server, limit)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `credit_limits` WHERE id = \\?").
+		WillReturnRows(creditLimitRows(limit))
+	mock.ExpectExec("DELETE FROM `credit_limits`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Delete(context.Background(), limit.ID); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	assertCreditLimitCacheCleared(t, server, limit)
+}
+
+func TestCreditLimitRepositoryFailedWriteKeepsCache(t *testing.T) {
+	repo, mock, server := newTestCreditLimitRepository(t)
+	limit := entity.CreditLimit{ID: uuid.New(), CustomerID: uuid.New(), TenorMonth: 3, LimitAmount: 1000, UsedAmount: 900}
+	seedCreditLimitCache(t, server, limit)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `credit_limits` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(creditLimitRows(limit))
+	mock.ExpectRollback()
+
+	if err := repo.UpdateUsedAmount(context.Background(), limit.ID, 300, "test"); err == nil {
+		t.Fatal("UpdateUsedAmount beyond the limit returned no error")
+	}
+	if !server.Exists(cacher.GetCreditLimitCacheKey(limit.ID)) {
+		t.Error("a rolled back write invalidated the cache")
+	}
+}
+
+func TestCreditLimitRepositoryCreateTranslatesDuplicateTenor(t *testing.T) {
+	repo, mock, _ := newTestCreditLimitRepository(t)
+	limit := entity.CreditLimit{ID: uuid.New(), CustomerID: uuid.New(), TenorMonth: 3, LimitAmount: 1000}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `credit_limits`").
+		WillReturnError(&mysqlDriver.MySQLError{Number: 1062, Message: "Duplicate entry for key 'idx_customer_tenor'"})
+	mock.ExpectRollback()
+
+	if err := repo.Create(context.Background(), &limit); err != entity.ErrDuplicateCreditLimit {
+		t.Fatalf("Create returned %v, want ErrDuplicateCreditLimit", err)
+	}
+}
@@ -2,29 +2,36 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"kredit-plus/cacher"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/infra/mysql"
 	"kredit-plus/infra/redis"
 	"kredit-plus/internal/entity"
+	"strings"
+	"time"
 )
 
 type customerRepository struct {
-	db     *mysql.Client
-	redis  *redis.Client
-	logger *zap.Logger
+	db        *mysql.Client
+	redis     *redis.Client
+	clock     entity.Clock
+	logger    *zap.Logger
+	loadGroup singleflight.Group
 }
 
-func NewCustomerRepository(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) entity.CustomerRepository {
+func NewCustomerRepository(db *mysql.Client, redisClient *redis.Client, clock entity.Clock, logger *zap.Logger) entity.CustomerRepository {
 	return &customerRepository{
 		db:     db,
 		redis:  redisClient,
+		clock:  clock,
 		logger: logger,
 	}
 }
@@ -39,9 +46,9 @@ func (r *customerRepository) Create(ctx context.Context, customer *entity.Custom
 		attribute.String("customer.nik", customer.NIK),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		if err := tx.Create(customer).Error; err != nil {
-			r.logger.Error("failed to create customer",
+			loggerPkg.FromContext(ctx).Error("failed to create customer",
 				zap.Error(err),
 				zap.String("customer_id", customer.ID.String()),
 			)
@@ -49,9 +56,18 @@ func (r *customerRepository) Create(ctx context.Context, customer *entity.Custom
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	r.cacheNIKIndex(ctx, customer.NIK, customer.ID)
+
+	return nil
 }
 
-func (r *customerRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Customer, error) {
+// GetByID returns the customer, or nil when it does not exist. Deactivated
+// customers are only returned when includeInactive is set.
+func (r *customerRepository) GetByID(ctx context.Context, id uuid.UUID, includeInactive bool) (*entity.Customer, error) {
 	tr := otel.Tracer("repository.customer")
 	ctx, span := tr.Start(ctx, "GetByID")
 	defer span.End()
@@ -59,78 +75,82 @@ func (r *customerRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity
 	span.SetAttributes(attribute.String("customer.id", id.String()))
 
 	cacheKey := cacher.GetCustomerCacheKeyByID(id)
-	var customer entity.Customer
-	cachedData, err := r.redis.Get(ctx, cacheKey)
-	if err == nil {
-		if err := json.Unmarshal([]byte(cachedData), &customer); err == nil {
-			return &customer, nil
-		}
-	}
 
-	if err := r.db.WithContext(ctx).
-		Preload("Documents").
-		First(&customer, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, nil
-		}
-		r.logger.Error("failed to get customer by id",
-			zap.Error(err),
-			zap.String("customer_id", id.String()),
-		)
-		return nil, fmt.Errorf("failed to get customer: %w", err)
+	//Only one caller per key loads and backfills, the rest share its result
+	shared, err := loadShared(ctx, &r.loadGroup, cacheKey, func(ctx context.Context) (*entity.Customer, error) {
+		return cacher.GetOrLoad(ctx, r.redis, cacheKey, entity.DefaultCacheTTL, func() (*entity.Customer, error) {
+			var customer entity.Customer
+			if err := r.db.WithContext(ctx).
+				Preload("Documents").
+				First(&customer, "id = ?", id).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil, nil
+				}
+				loggerPkg.FromContext(ctx).Error("failed to get customer by id",
+					zap.Error(err),
+					zap.String("customer_id", id.String()),
+				)
+				return nil, fmt.Errorf("failed to get customer: %w", err)
+			}
+			return &customer, nil
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if customerJSON, err := json.Marshal(customer); err == nil {
-		if err := r.redis.Set(ctx, cacheKey, string(customerJSON), entity.DefaultCacheTTL); err != nil {
-			r.logger.Warn("failed to cache customer",
-				zap.Error(err),
-				zap.String("customer_id", id.String()),
-			)
-		}
+	if shared == nil || (!includeInactive && !shared.IsActive) {
+		return nil, nil
 	}
+	customer := *shared
 
 	return &customer, nil
 }
 
-func (r *customerRepository) GetByNIK(ctx context.Context, nik string) (*entity.Customer, error) {
+// GetByNIK resolves the NIK to a customer ID through a cached index and loads
+// the customer with GetByID, so the customer is only ever cached once.
+func (r *customerRepository) GetByNIK(ctx context.Context, nik string, includeInactive bool) (*entity.Customer, error) {
 	tr := otel.Tracer("repository.customer")
 	ctx, span := tr.Start(ctx, "GetByNIK")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("customer.nik", nik))
 
-	cacheKey := cacher.GetCustomerCacheKeyByNIK(nik)
-	var customer entity.Customer
-	cachedData, err := r.redis.Get(ctx, cacheKey)
-	if err == nil {
-		if err := json.Unmarshal([]byte(cachedData), &customer); err == nil {
-			return &customer, nil
+	cacheKey := cacher.GetCustomerIDCacheKeyByNIK(nik)
+	if cachedID, err := r.redis.Get(ctx, cacheKey); err == nil {
+		if id, err := uuid.Parse(cachedID); err == nil {
+			return r.GetByID(ctx, id, includeInactive)
 		}
 	}
 
+	var customer entity.Customer
 	if err := r.db.WithContext(ctx).
-		Preload("Documents").
+		Select("id").
 		First(&customer, "nik = ?", nik).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
-		r.logger.Error("failed to get customer by nik",
+		loggerPkg.FromContext(ctx).Error("failed to get customer by nik",
 			zap.Error(err),
 			zap.String("nik", nik),
 		)
 		return nil, fmt.Errorf("failed to get customer by nik: %w", err)
 	}
 
-	if customerJSON, err := json.Marshal(customer); err == nil {
-		if err := r.redis.Set(ctx, cacheKey, string(customerJSON), entity.DefaultCacheTTL); err != nil {
-			r.logger.Warn("failed to cache customer",
-				zap.Error(err),
-				zap.String("nik", nik),
-			)
-		}
-	}
+	r.cacheNIKIndex(ctx, nik, customer.ID)
 
-	return &customer, nil
+	return r.GetByID(ctx, customer.ID, includeInactive)
+}
+
+// cacheNIKIndex records which customer a NIK belongs to. NIKs never change, so
+// the entry only has to be removed when the customer is deleted.
+func (r *customerRepository) cacheNIKIndex(ctx context.Context, nik string, id uuid.UUID) {
+	if err := r.redis.SetWithJitter(ctx, cacher.GetCustomerIDCacheKeyByNIK(nik), id.String(), entity.DefaultCacheTTL); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to cache customer nik index",
+			zap.Error(err),
+			zap.String("customer_id", id.String()),
+		)
+	}
 }
 
 func (r *customerRepository) Update(ctx context.Context, customer *entity.Customer) error {
@@ -143,32 +163,53 @@ func (r *customerRepository) Update(ctx context.Context, customer *entity.Custom
 		attribute.String("customer.nik", customer.NIK),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		if err := tx.Save(customer).Error; err != nil {
-			r.logger.Error("failed to update customer",
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		var current entity.Customer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("version").
+			First(&current, "id = ?", customer.ID).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to get customer for update",
 				zap.Error(err),
 				zap.String("customer_id", customer.ID.String()),
 			)
-			return fmt.Errorf("failed to update customer: %w", err)
+			return fmt.Errorf("failed to get customer for update: %w", err)
 		}
 
-		cacheKeys := []string{
-			cacher.GetCustomerCacheKeyByID(customer.ID),
-			cacher.GetCustomerCacheKeyByNIK(customer.NIK),
-			cacher.GetCustomerDocumentsCacheKey(customer.ID),
+		//Someone else saved since customer was read
+		if current.Version != customer.Version {
+			return entity.ErrStaleWrite
 		}
+		customer.Version++
 
-		for _, key := range cacheKeys {
-			if err := r.redis.Del(ctx, key); err != nil {
-				r.logger.Warn("failed to invalidate customer cache",
-					zap.Error(err),
-					zap.String("cache_key", key),
-				)
-			}
+		if err := tx.Save(customer).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to update customer",
+				zap.Error(err),
+				zap.String("customer_id", customer.ID.String()),
+			)
+			return fmt.Errorf("failed to update customer: %w", err)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	//Dropped after commit so a concurrent read cannot re-cache the old row
+	cacheKeys := []string{
+		cacher.GetCustomerCacheKeyByID(customer.ID),
+		cacher.GetCustomerDocumentsCacheKey(customer.ID),
+	}
+
+	if err := r.redis.Del(ctx, cacheKeys...); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate customer cache",
+			zap.Error(err),
+			zap.String("customer_id", customer.ID.String()),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
+
+	return nil
 }
 
 func (r *customerRepository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -178,10 +219,10 @@ func (r *customerRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	span.SetAttributes(attribute.String("customer.id", id.String()))
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		var customer entity.Customer
+	var customer entity.Customer
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		if err := tx.First(&customer, "id = ?", id).Error; err != nil {
-			r.logger.Error("failed to get customer for deletion",
+			loggerPkg.FromContext(ctx).Error("failed to get customer for deletion",
 				zap.Error(err),
 				zap.String("customer_id", id.String()),
 			)
@@ -191,36 +232,89 @@ func (r *customerRepository) Delete(ctx context.Context, id uuid.UUID) error {
 		if err := tx.Model(&entity.Customer{}).
 			Where("id = ?", id).
 			Update("is_active", false).Error; err != nil {
-			r.logger.Error("failed to delete customer",
+			loggerPkg.FromContext(ctx).Error("failed to delete customer",
 				zap.Error(err),
 				zap.String("customer_id", id.String()),
 			)
 			return fmt.Errorf("failed to delete customer: %w", err)
 		}
 
-		cacheKeys := []string{
-			cacher.GetCustomerCacheKeyByID(id),
-			cacher.GetCustomerCacheKeyByNIK(customer.NIK),
-			cacher.GetCustomerDocumentsCacheKey(id),
-			cacher.GetCustomerCreditLimitsCacheKey(id),
-			cacher.GetCustomerTransactionsCacheKey(id),
-		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	//After commit, so a concurrent read cannot re-cache the still active row
+	cacheKeys := []string{
+		cacher.GetCustomerCacheKeyByID(id),
+		cacher.GetCustomerIDCacheKeyByNIK(customer.NIK),
+		cacher.GetCustomerDocumentsCacheKey(id),
+		cacher.GetCustomerCreditLimitsCacheKey(id),
+		cacher.GetCustomerTransactionsCacheKey(id),
+	}
+
+	if err := r.redis.Del(ctx, cacheKeys...); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate customer related caches",
+			zap.Error(err),
+			zap.String("customer_id", id.String()),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
+
+	return nil
+}
 
-		if err := r.redis.Del(ctx, cacheKeys...); err != nil {
-			r.logger.Warn("failed to invalidate customer related caches",
+func (r *customerRepository) CreateDocument(ctx context.Context, doc *entity.CustomerDocument) error {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "CreateDocument")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("customer.id", doc.CustomerID.String()),
+		attribute.String("document.type", string(doc.DocumentType)),
+	)
+
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Create(doc).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to create customer document",
 				zap.Error(err),
-				zap.String("customer_id", id.String()),
-				zap.Strings("cache_keys", cacheKeys),
+				zap.String("customer_id", doc.CustomerID.String()),
+				zap.String("document_type", string(doc.DocumentType)),
 			)
+			return fmt.Errorf("failed to create customer document: %w", err)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	cacheKeys := []string{
+		cacher.GetCustomerCacheKeyByID(doc.CustomerID),
+		cacher.GetCustomerDocumentsCacheKey(doc.CustomerID),
+		cacher.GetCustomerDocumentCacheKey(doc.ID),
+	}
+
+	if err := r.redis.Del(ctx, cacheKeys...); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate customer document related caches",
+			zap.Error(err),
+			zap.String("customer_id", doc.CustomerID.String()),
+			zap.String("document_id", doc.ID.String()),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
+
+	return nil
 }
 
-func (r *customerRepository) CreateDocument(ctx context.Context, doc *entity.CustomerDocument) error {
+// UpsertDocument replaces the URL of the customer's existing document of the same
+// type, or creates doc when there is none. On update doc is refreshed with the
+// stored ID and CreatedAt.
+func (r *customerRepository) UpsertDocument(ctx context.Context, doc *entity.CustomerDocument) (created bool, err error) {
 	tr := otel.Tracer("repository.customer")
-	ctx, span := tr.Start(ctx, "CreateDocument")
+	ctx, span := tr.Start(ctx, "UpsertDocument")
 	defer span.End()
 
 	span.SetAttributes(
@@ -228,33 +322,128 @@ func (r *customerRepository) CreateDocument(ctx context.Context, doc *entity.Cus
 		attribute.String("document.type", string(doc.DocumentType)),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		if err := tx.Create(doc).Error; err != nil {
-			r.logger.Error("failed to create customer document",
+	err = r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		var existing entity.CustomerDocument
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ? AND document_type = ?", doc.CustomerID, doc.DocumentType).
+			First(&existing).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			loggerPkg.FromContext(ctx).Error("failed to get customer document for upsert",
 				zap.Error(err),
 				zap.String("customer_id", doc.CustomerID.String()),
 				zap.String("document_type", string(doc.DocumentType)),
 			)
-			return fmt.Errorf("failed to create customer document: %w", err)
+			return fmt.Errorf("failed to get customer document: %w", err)
 		}
 
-		cacheKeys := []string{
-			cacher.GetCustomerCacheKeyByID(doc.CustomerID),
-			cacher.GetCustomerDocumentsCacheKey(doc.CustomerID),
-			cacher.GetCustomerDocumentCacheKey(doc.ID),
+		if err == gorm.ErrRecordNotFound {
+			if err := tx.Create(doc).Error; err != nil {
+				loggerPkg.FromContext(ctx).Error("failed to create customer document",
+					zap.Error(err),
+					zap.String("customer_id", doc.CustomerID.String()),
+					zap.String("document_type", string(doc.DocumentType)),
+				)
+				return fmt.Errorf("failed to create customer document: %w", err)
+			}
+			created = true
+			return nil
 		}
 
-		if err := r.redis.Del(ctx, cacheKeys...); err != nil {
-			r.logger.Warn("failed to invalidate customer document related caches",
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"document_url": doc.DocumentURL,
+			"updated_at":   doc.UpdatedAt,
+		}).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to update customer document",
 				zap.Error(err),
-				zap.String("customer_id", doc.CustomerID.String()),
-				zap.String("document_id", doc.ID.String()),
-				zap.Strings("cache_keys", cacheKeys),
+				zap.String("document_id", existing.ID.String()),
+			)
+			return fmt.Errorf("failed to update customer document: %w", err)
+		}
+		doc.ID = existing.ID
+		doc.CreatedAt = existing.CreatedAt
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	cacheKeys := []string{
+		cacher.GetCustomerCacheKeyByID(doc.CustomerID),
+		cacher.GetCustomerDocumentsCacheKey(doc.CustomerID),
+		cacher.GetCustomerDocumentCacheKey(doc.ID),
+	}
+
+	if err := r.redis.Del(ctx, cacheKeys...); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate customer document related caches",
+			zap.Error(err),
+			zap.String("customer_id", doc.CustomerID.String()),
+			zap.String("document_id", doc.ID.String()),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
+
+	return created, nil
+}
+
+// DeleteDocument removes a document only if it belongs to customerID; a document
+// of another customer is reported as not found.
+func (r *customerRepository) DeleteDocument(ctx context.Context, customerID, documentID uuid.UUID) error {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "DeleteDocument")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("customer.id", customerID.String()),
+		attribute.String("document.id", documentID.String()),
+	)
+
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		var doc entity.CustomerDocument
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&doc, "id = ? AND customer_id = ?", documentID, customerID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrDocumentNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get customer document for deletion",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+				zap.String("document_id", documentID.String()),
+			)
+			return fmt.Errorf("failed to get customer document for deletion: %w", err)
+		}
+
+		if err := tx.Delete(&doc).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to delete customer document",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+				zap.String("document_id", documentID.String()),
 			)
+			return fmt.Errorf("failed to delete customer document: %w", err)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	cacheKeys := []string{
+		cacher.GetCustomerCacheKeyByID(customerID),
+		cacher.GetCustomerDocumentsCacheKey(customerID),
+		cacher.GetCustomerDocumentCacheKey(documentID),
+	}
+
+	if err := r.redis.Del(ctx, cacheKeys...); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate customer document related caches",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+			zap.String("document_id", documentID.String()),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
+
+	return nil
 }
 
 func (r *customerRepository) GetDocuments(ctx context.Context, filter entity.DocumentFilterRepository) (documents []entity.CustomerDocument, count int64, err error) {
@@ -269,7 +458,7 @@ func (r *customerRepository) GetDocuments(ctx context.Context, filter entity.Doc
 	)
 
 	if filter.Limit < 0 || filter.Offset < 0 {
-		r.logger.Error("invalid pagination parameters",
+		loggerPkg.FromContext(ctx).Error("invalid pagination parameters",
 			zap.Int("limit", filter.Limit),
 			zap.Int("offset", filter.Offset),
 			zap.String("customer_id", filter.CustomerID.String()),
@@ -286,7 +475,7 @@ func (r *customerRepository) GetDocuments(ctx context.Context, filter entity.Doc
 	}
 
 	if err := query.Count(&count).Error; err != nil {
-		r.logger.Error("failed to count customer documents",
+		loggerPkg.FromContext(ctx).Error("failed to count customer documents",
 			zap.Error(err),
 			zap.String("customer_id", filter.CustomerID.String()),
 		)
@@ -294,7 +483,7 @@ func (r *customerRepository) GetDocuments(ctx context.Context, filter entity.Doc
 	}
 
 	if count > 0 && filter.Offset >= int(count) {
-		r.logger.Warn("offset exceeds total count",
+		loggerPkg.FromContext(ctx).Warn("offset exceeds total count",
 			zap.Int("offset", filter.Offset),
 			zap.Int64("total_count", count),
 			zap.String("customer_id", filter.CustomerID.String()),
@@ -306,7 +495,7 @@ func (r *customerRepository) GetDocuments(ctx context.Context, filter entity.Doc
 		Limit(filter.Limit).
 		Offset(filter.Offset).
 		Find(&documents).Error; err != nil {
-		r.logger.Error("failed to get customer documents",
+		loggerPkg.FromContext(ctx).Error("failed to get customer documents",
 			zap.Error(err),
 			zap.String("customer_id", filter.CustomerID.String()),
 		)
@@ -314,4 +503,279 @@ func (r *customerRepository) GetDocuments(ctx context.Context, filter entity.Doc
 	}
 
 	return documents, count, nil
-}
\ No newline at end of file
+}
+
+// DocumentsComplete reports whether the customer has a document of every type
+// in required. An empty required set is always complete.
+func (r *customerRepository) DocumentsComplete(ctx context.Context, customerID uuid.UUID, required []entity.DocumentType) (bool, error) {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "DocumentsComplete")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("customer.id", customerID.String()),
+		attribute.Int("required.count", len(required)),
+	)
+
+	if len(required) == 0 {
+		return true, nil
+	}
+
+	var present int64
+	if err := r.db.WithContext(ctx).Model(&entity.CustomerDocument{}).
+		Where("customer_id = ? AND document_type IN ?", customerID, required).
+		Distinct("document_type").
+		Count(&present).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to check customer documents",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return false, fmt.Errorf("failed to check customer documents: %w", err)
+	}
+
+	unique := make(map[entity.DocumentType]bool, len(required))
+	for _, documentType := range required {
+		unique[documentType] = true
+	}
+
+	return present >= int64(len(unique)), nil
+}
+
+// GetTransactionStats counts the customer's active and completed transactions
+// and sums what is still owed on their installments.
+func (r *customerRepository) GetTransactionStats(ctx context.Context, customerID uuid.UUID) (*entity.CustomerTransactionStats, error) {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "GetTransactionStats")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", customerID.String()))
+
+	var stats entity.CustomerTransactionStats
+	if err := r.db.WithReplica(ctx).
+		Model(&entity.Transaction{}).
+		Select(`COUNT(CASE WHEN status = ? THEN 1 END) AS active_transactions,
+			COUNT(CASE WHEN status = ? THEN 1 END) AS completed_transactions`,
+			entity.TransactionStatusActive,
+			entity.TransactionStatusCompleted,
+		).
+		Where("customer_id = ?", customerID).
+		Scan(&stats).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to count customer transactions",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, fmt.Errorf("failed to count customer transactions: %w", err)
+	}
+
+	if err := r.db.WithReplica(ctx).
+		Model(&entity.TransactionDetail{}).
+		Select("COALESCE(SUM(transaction_details.amount - transaction_details.paid_amount), 0)").
+		Joins("JOIN transactions ON transactions.id = transaction_details.transaction_id").
+		Where("transactions.customer_id = ? AND transactions.status <> ? AND transaction_details.status <> ?",
+			customerID,
+			entity.TransactionStatusCancelled,
+			entity.TransactionDetailStatusPaid,
+		).
+		Scan(&stats.TotalOutstanding).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to sum customer outstanding amount",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, fmt.Errorf("failed to sum customer outstanding amount: %w", err)
+	}
+
+	return &stats, nil
+}
+
+func (r *customerRepository) List(ctx context.Context, filter entity.CustomerFilterRepository) (customers []entity.Customer, count int64, err error) {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "List")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("filter.full_name", filter.FullName),
+		attribute.Int("filter.limit", filter.Limit),
+		attribute.Int("filter.offset", filter.Offset),
+	)
+
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, 0, fmt.Errorf("invalid pagination parameters: limit and offset must be non-negative")
+	}
+
+	query := r.db.WithReplica(ctx).Model(&entity.Customer{})
+	if filter.FullName != "" {
+		query = query.Where("full_name LIKE ?", "%"+escapeLike(filter.FullName)+"%")
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+		span.SetAttributes(attribute.Bool("filter.is_active", *filter.IsActive))
+	}
+
+	if err = query.Count(&count).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to count customers",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return nil, 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+
+	if count > 0 && filter.Offset >= int(count) {
+		return []entity.Customer{}, count, nil
+	}
+
+	if err = query.
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Order("created_at DESC").
+		Find(&customers).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to list customers",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return nil, 0, fmt.Errorf("failed to list customers: %w", err)
+	}
+
+	return customers, count, nil
+}
+
+func (r *customerRepository) Reactivate(ctx context.Context, id uuid.UUID) (*entity.Customer, error) {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "Reactivate")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", id.String()))
+
+	var customer entity.Customer
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&customer, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrCustomerNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get customer for reactivation",
+				zap.Error(err),
+				zap.String("customer_id", id.String()),
+			)
+			return fmt.Errorf("failed to get customer for reactivation: %w", err)
+		}
+
+		if customer.IsActive {
+			return entity.ErrCustomerAlreadyActive
+		}
+
+		customer.IsActive = true
+		customer.UpdatedAt = r.clock.Now()
+		if err := tx.Model(&customer).Updates(map[string]interface{}{
+			"is_active":  customer.IsActive,
+			"updated_at": customer.UpdatedAt,
+		}).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to reactivate customer",
+				zap.Error(err),
+				zap.String("customer_id", id.String()),
+			)
+			return fmt.Errorf("failed to reactivate customer: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := cacher.GetCustomerCacheKeyByID(id)
+	if err := r.redis.Del(ctx, cacheKey); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate customer cache",
+			zap.Error(err),
+			zap.String("customer_id", id.String()),
+			zap.String("cache_key", cacheKey),
+		)
+	}
+
+	return &customer, nil
+}
+
+// escapeLike escapes the LIKE wildcards in user input so it is matched literally.
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// sharedLoadTimeout bounds a load shared through a singleflight group, since it
+// no longer ends with the request that started it.
+const sharedLoadTimeout = 10 * time.Second
+
+// loadShared runs load once per key across concurrent callers. The load is
+// detached from the first caller's cancellation so one aborted request does not
+// fail everyone waiting on it; each caller still stops waiting when its own ctx
+// is done.
+func loadShared[T any](ctx context.Context, group *singleflight.Group, key string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	results := group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedLoadTimeout)
+		defer cancel()
+
+		return load(loadCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*T), nil
+	}
+}
+
+// StreamList walks the customers matching filter row by row, without loading
+// them all, and calls fn for each. filter.Limit caps the number of rows.
+func (r *customerRepository) StreamList(ctx context.Context, filter entity.CustomerFilterRepository, fn func(customer *entity.Customer) error) (int, error) {
+	tr := otel.Tracer("repository.customer")
+	ctx, span := tr.Start(ctx, "StreamList")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("filter.full_name", filter.FullName),
+		attribute.Int("filter.limit", filter.Limit),
+	)
+
+	query := r.db.WithReplica(ctx).Model(&entity.Customer{})
+	if filter.FullName != "" {
+		query = query.Where("full_name LIKE ?", "%"+escapeLike(filter.FullName)+"%")
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+		span.SetAttributes(attribute.Bool("filter.is_active", *filter.IsActive))
+	}
+
+	rows, err := query.
+		Limit(filter.Limit).
+		Order("created_at DESC").
+		Rows()
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to query customers for streaming",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return 0, fmt.Errorf("failed to query customers: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var customer entity.Customer
+		if err := query.ScanRows(rows, &customer); err != nil {
+			return count, fmt.Errorf("failed to scan customer: %w", err)
+		}
+		if err := fn(&customer); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to iterate customers: %w", err)
+	}
+
+	return count, nil
+}
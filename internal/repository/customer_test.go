@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestCustomerRepository(t *testing.T) (*customerRepository, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	db, mock := newMockDB(t)
+	redisClient, server := newTestRedis(t)
+
+	repo := NewCustomerRepository(db, redisClient, fixedClock{now: testNow}, zap.NewNop())
+	return repo.(*customerRepository), mock, server
+}
+
+// expectCustomerLoad expects GetByID's query and its documents preload, with
+// the customer row held back for delay.
+func expectCustomerLoad(mock sqlmock.Sqlmock, id uuid.UUID, delay time.Duration) {
+	mock.ExpectQuery("SELECT \\* FROM `customers` WHERE id = \\?").
+		WithArgs(id, 1).
+		WillDelayFor(delay).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "is_active"}).AddRow(id.String(), "Budi Santoso", true))
+	mock.ExpectQuery("SELECT \\* FROM `customer_documents` WHERE `customer_documents`.`customer_id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func TestCustomerRepositoryGetByIDLoadsColdKeyOnce(t *testing.T) {
+	repo, mock, _ := newTestCustomerRepository(t)
+	id := uuid.New()
+	//Expected once; a second load would fail as an unexpected query
+	expectCustomerLoad(mock, id, 50*time.Millisecond)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			customer, err := repo.GetByID(context.Background(), id, true)
+			if err == nil && (customer == nil || customer.ID != id) {
+				err = errors.New("GetByID returned the wrong customer")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetByID returned error: %v", err)
+		}
+	}
+}
+
+func TestCustomerRepositoryGetByIDSurvivesFirstCallerCancelling(t *testing.T) {
+	repo, mock, _ := newTestCustomerRepository(t)
+	id := uuid.New()
+	expectCustomerLoad(mock, id, 100*time.Millisecond)
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := repo.GetByID(firstCtx, id, true)
+		firstErr <- err
+	}()
+
+	//Let the first caller start the shared load, then join it and cancel the first
+	time.Sleep(20 * time.Millisecond)
+	secondDone := make(chan struct{})
+	var customer *entity.Customer
+	var secondErr error
+	go func() {
+		defer close(secondDone)
+		customer, secondErr = repo.GetByID(context.Background(), id, true)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancelFirst()
+
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v, want context.Canceled", err)
+	}
+	<-secondDone
+	if secondErr != nil {
+		t.Fatalf("waiting caller got error: %v", secondErr)
+	}
+	if customer == nil || customer.ID != id {
+		t.Errorf("waiting caller got %+v, want customer %s", customer, id)
+	}
+}
+
+func TestCustomerRepositoryGetByIDHidesDeactivatedCustomer(t *testing.T) {
+	expectDeactivatedLoad := func(mock sqlmock.Sqlmock, id uuid.UUID) {
+		mock.ExpectQuery("SELECT \\* FROM `customers` WHERE id = \\?").
+			WithArgs(id, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "is_active"}).AddRow(id.String(), "Budi Santoso", false))
+		mock.ExpectQuery("SELECT \\* FROM `customer_documents` WHERE `customer_documents`.`customer_id` = \\?").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	t.Run("freshly loaded", func(t *testing.T) {
+		repo, mock, _ := newTestCustomerRepository(t)
+		id := uuid.New()
+		expectDeactivatedLoad(mock, id)
+
+		customer, err := repo.GetByID(context.Background(), id, false)
+		if err != nil {
+			t.Fatalf("GetByID returned error: %v", err)
+		}
+		if customer != nil {
+			t.Errorf("GetByID returned deactivated customer %s", customer.ID)
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		repo, mock, _ := newTestCustomerRepository(t)
+		id := uuid.New()
+		//Expected once; the second lookup must be answered from the cache
+		expectDeactivatedLoad(mock, id)
+
+		customer, err := repo.GetByID(context.Background(), id, true)
+		if err != nil || customer == nil {
+			t.Fatalf("GetByID including inactive returned %v, %v", customer, err)
+		}
+
+		customer, err = repo.GetByID(context.Background(), id, false)
+		if err != nil {
+			t.Fatalf("GetByID returned error: %v", err)
+		}
+		if customer != nil {
+			t.Errorf("GetByID returned cached deactivated customer %s", customer.ID)
+		}
+	})
+}
+
+func TestCustomerRepositoryUpdateRejectsSecondOfTwoConcurrentEdits(t *testing.T) {
+	repo, mock, _ := newTestCustomerRepository(t)
+	id := uuid.New()
+	//Both requests read the customer at version 1 before either saves
+	first := &entity.Customer{ID: id, FullName: "Budi", Version: 1}
+	second := &entity.Customer{ID: id, FullName: "Budi Santoso", Version: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `version` FROM `customers` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectExec("UPDATE `customers`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `version` FROM `customers` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectRollback()
+
+	if err := repo.Update(context.Background(), first); err != nil {
+		t.Fatalf("first Update returned error: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("first Update left version %d, want 2", first.Version)
+	}
+	if err := repo.Update(context.Background(), second); err != entity.ErrStaleWrite {
+		t.Fatalf("second Update returned %v, want ErrStaleWrite", err)
+	}
+}
+
+func TestCustomerRepositoryDocumentsComplete(t *testing.T) {
+	required := []entity.DocumentType{entity.DocumentTypeKTP, entity.DocumentTypeSelfie}
+	for _, tc := range []struct {
+		name    string
+		present int
+		want    bool
+	}{
+		{"both documents", 2, true},
+		{"only one document", 1, false},
+		{"no documents", 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock, _ := newTestCustomerRepository(t)
+			customerID := uuid.New()
+
+			mock.ExpectQuery("SELECT COUNT\\(DISTINCT\\(`document_type`\\)\\) FROM `customer_documents` WHERE customer_id = \\? AND document_type IN \\(\\?,\\?\\)").
+				WithArgs(customerID, string(entity.DocumentTypeKTP), string(entity.DocumentTypeSelfie)).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tc.present))
+
+			complete, err := repo.DocumentsComplete(context.Background(), customerID, required)
+			if err != nil {
+				t.Fatalf("DocumentsComplete returned error: %v", err)
+			}
+			if complete != tc.want {
+				t.Errorf("DocumentsComplete = %v, want %v", complete, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"kredit-plus/infra/mysql"
+	"kredit-plus/infra/redis"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPublisher keeps every published payload so tests can assert on the
+// events a write emitted.
+type recordingPublisher struct {
+	mu       sync.Mutex
+	payloads []any
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, _ string, payload any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.payloads = append(p.payloads, payload)
+}
+
+func (p *recordingPublisher) published() []any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]any(nil), p.payloads...)
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+var testNow = time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+
+// newMockDB returns a client whose statements are checked against the
+// returned sqlmock, in order, using regular expressions.
+func newMockDB(t *testing.T) (*mysql.Client, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet sql expectations: %v", err)
+		}
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		NowFunc: func() time.Time {
+			return testNow
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+
+	return mysql.NewClientFromDB(db, zap.NewNop()), mock
+}
+
+func newTestRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	port, err := strconv.Atoi(server.Port())
+	if err != nil {
+		t.Fatalf("invalid miniredis port: %v", err)
+	}
+	client, err := redis.NewClient(redis.Config{
+		Host: server.Host(),
+		Port: port,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, server
+}
+
+// floatArg matches a bound float64 equal to want.
+type floatArg float64
+
+func (a floatArg) Match(v driver.Value) bool {
+	f, ok := v.(float64)
+	return ok && f == float64(a)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
@@ -9,24 +10,38 @@ import (
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"kredit-plus/cacher"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/infra/mysql"
+	"kredit-plus/infra/redis"
 	"kredit-plus/internal/entity"
+	"math"
 	"time"
 )
 
+// idempotencyKeyPending marks a reserved idempotency key whose transaction has
+// not been created yet.
+const idempotencyKeyPending = "pending"
+
 type transactionRepository struct {
-	db     *mysql.Client
-	logger *zap.Logger
+	db        *mysql.Client
+	redis     *redis.Client
+	publisher entity.EventPublisher
+	clock     entity.Clock
+	logger    *zap.Logger
 }
 
-func NewTransactionRepository(db *mysql.Client, logger *zap.Logger) entity.TransactionRepository {
+func NewTransactionRepository(db *mysql.Client, redisClient *redis.Client, publisher entity.EventPublisher, clock entity.Clock, logger *zap.Logger) entity.TransactionRepository {
 	return &transactionRepository{
-		db:     db,
-		logger: logger,
+		db:        db,
+		redis:     redisClient,
+		publisher: publisher,
+		clock:     clock,
+		logger:    logger,
 	}
 }
 
-func (r *transactionRepository) Create(ctx context.Context, transaction *entity.Transaction) error {
+func (r *transactionRepository) Create(ctx context.Context, transaction *entity.Transaction, schedule []float64) error {
 	tr := otel.Tracer("repository.transaction")
 	ctx, span := tr.Start(ctx, "Create")
 	defer span.End()
@@ -38,51 +53,92 @@ func (r *transactionRepository) Create(ctx context.Context, transaction *entity.
 		attribute.String("contract.number", transaction.ContractNumber),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
-		var creditLimit entity.CreditLimit
+	var creditLimit entity.CreditLimit
+	err := r.db.TransactionWithRetry(ctx, func(tx *gorm.DB) error {
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 			Where("customer_id = ? AND tenor_month = ?", transaction.CustomerID, transaction.TenorMonth).
 			First(&creditLimit).Error; err != nil {
-			r.logger.Error("failed to get credit limit for transaction",
+			loggerPkg.FromContext(ctx).Error("failed to get credit limit for transaction",
 				zap.Error(err),
 				zap.String("customer_id", transaction.CustomerID.String()),
 			)
 			return fmt.Errorf("failed to get credit limit: %w", err)
 		}
 
-		if creditLimit.UsedAmount+transaction.OTRAmount > creditLimit.LimitAmount {
-			return fmt.Errorf("insufficient credit limit: available %.2f, requested %.2f",
-				creditLimit.LimitAmount-creditLimit.UsedAmount, transaction.OTRAmount)
+		totalAmount := transaction.TotalAmount()
+		if creditLimit.UsedAmount+totalAmount > creditLimit.LimitAmount {
+			loggerPkg.FromContext(ctx).Warn("insufficient credit limit for transaction",
+				zap.String("customer_id", transaction.CustomerID.String()),
+				zap.Float64("available", creditLimit.LimitAmount-creditLimit.UsedAmount),
+				zap.Float64("requested", totalAmount),
+			)
+			return entity.ErrInsufficientCreditLimit
 		}
 
 		if err := tx.Create(transaction).Error; err != nil {
-			r.logger.Error("failed to create transaction",
+			//The service pre-checks the contract number, but a concurrent create can still win the race
+			if mysql.IsDuplicateKeyError(err) {
+				return entity.ErrDuplicateContract
+			}
+			loggerPkg.FromContext(ctx).Error("failed to create transaction",
 				zap.Error(err),
 				zap.String("customer_id", transaction.CustomerID.String()),
 			)
 			return fmt.Errorf("failed to create transaction: %w", err)
 		}
 
-		installments := r.generateInstallments(transaction)
+		result := tx.Model(&entity.Asset{}).
+			Where("id = ? AND stock > 0", transaction.AssetID).
+			UpdateColumn("stock", gorm.Expr("stock - 1"))
+		if result.Error != nil {
+			loggerPkg.FromContext(ctx).Error("failed to decrement asset stock",
+				zap.Error(result.Error),
+				zap.String("asset_id", transaction.AssetID.String()),
+			)
+			return fmt.Errorf("failed to decrement asset stock: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrAssetOutOfStock
+		}
+
+		installments := r.generateInstallments(transaction, schedule)
 		if err := tx.Create(&installments).Error; err != nil {
-			r.logger.Error("failed to create transaction details",
+			loggerPkg.FromContext(ctx).Error("failed to create transaction details",
 				zap.Error(err),
 				zap.String("transaction_id", transaction.ID.String()),
 			)
 			return fmt.Errorf("failed to create transaction details: %w", err)
 		}
 
-		creditLimit.UsedAmount += transaction.OTRAmount
+		previousUsed := creditLimit.UsedAmount
+		creditLimit.UsedAmount += totalAmount
 		if err := tx.Save(&creditLimit).Error; err != nil {
-			r.logger.Error("failed to update credit limit",
+			loggerPkg.FromContext(ctx).Error("failed to update credit limit",
 				zap.Error(err),
 				zap.String("credit_limit_id", creditLimit.ID.String()),
 			)
 			return fmt.Errorf("failed to update credit limit: %w", err)
 		}
 
+		if err := recordCreditLimitChange(tx, &creditLimit, creditLimit.UsedAmount-previousUsed, entity.LedgerReasonTransaction(transaction.ID), r.clock.Now()); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to record credit limit ledger entry",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimit.ID.String()),
+			)
+			return err
+		}
+
 		return nil
-	})
+	}, mysql.DefaultMaxRetries)
+	if err != nil {
+		return err
+	}
+
+	r.invalidateTransactionCache(ctx, transaction.ID)
+	invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &creditLimit)
+	invalidateAssetCache(ctx, r.redis, loggerPkg.FromContext(ctx), transaction.AssetID)
+
+	return nil
 }
 
 func (r *transactionRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Transaction, error) {
@@ -92,25 +148,39 @@ func (r *transactionRepository) GetByID(ctx context.Context, id uuid.UUID) (*ent
 
 	span.SetAttributes(attribute.String("transaction.id", id.String()))
 
+	cacheKey := cacher.GetTransactionCacheKey(id)
 	var transaction entity.Transaction
+
+	cachedData, err := r.redis.Get(ctx, cacheKey)
+	if err == nil {
+		if err := json.Unmarshal([]byte(cachedData), &transaction); err == nil {
+			return &transaction, nil
+		}
+	}
+
 	if err := r.db.WithContext(ctx).
-		Preload("TransactionDetail").
+		Preload("TransactionDetails", orderByInstallmentNumber).
 		Preload("Customer").
-		Preload("Asset").
+		Preload("Asset", withRetiredAssets).
 		First(&transaction, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
-		r.logger.Error("failed to get transaction by id",
+		loggerPkg.FromContext(ctx).Error("failed to get transaction by id",
 			zap.Error(err),
 			zap.String("transaction_id", id.String()),
 		)
 		return nil, fmt.Errorf("failed to get transaction: %w", err)
 	}
 
+	r.cacheTransaction(ctx, &transaction)
+
 	return &transaction, nil
 }
 
+// GetByContractNumber caches only the contract number to id mapping, which never
+// changes, and serves the transaction itself from the id-keyed cache so that a
+// single invalidation covers both lookups.
 func (r *transactionRepository) GetByContractNumber(ctx context.Context, contractNumber string) (*entity.Transaction, error) {
 	tr := otel.Tracer("repository.transaction")
 	ctx, span := tr.Start(ctx, "GetByContractNumber")
@@ -118,25 +188,80 @@ func (r *transactionRepository) GetByContractNumber(ctx context.Context, contrac
 
 	span.SetAttributes(attribute.String("contract.number", contractNumber))
 
+	cacheKey := cacher.GetTransactionCacheKeyByContractNumber(contractNumber)
+	if cachedID, err := r.redis.Get(ctx, cacheKey); err == nil {
+		if id, err := uuid.Parse(cachedID); err == nil {
+			return r.GetByID(ctx, id)
+		}
+	}
+
 	var transaction entity.Transaction
 	if err := r.db.WithContext(ctx).
-		Preload("TransactionDetail").
+		Preload("TransactionDetails", orderByInstallmentNumber).
 		Preload("Customer").
-		Preload("Asset").
+		Preload("Asset", withRetiredAssets).
 		First(&transaction, "contract_number = ?", contractNumber).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
-		r.logger.Error("failed to get transaction by contract number",
+		loggerPkg.FromContext(ctx).Error("failed to get transaction by contract number",
 			zap.Error(err),
 			zap.String("contract_number", contractNumber),
 		)
 		return nil, fmt.Errorf("failed to get transaction by contract number: %w", err)
 	}
 
+	if err := r.redis.SetWithJitter(ctx, cacheKey, transaction.ID.String(), entity.DefaultCacheTTL); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to cache transaction contract number",
+			zap.Error(err),
+			zap.String("contract_number", contractNumber),
+		)
+	}
+	r.cacheTransaction(ctx, &transaction)
+
 	return &transaction, nil
 }
 
+func (r *transactionRepository) GetInstallments(ctx context.Context, transactionID uuid.UUID, limit, offset int) ([]entity.TransactionDetail, int64, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "GetInstallments")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("transaction.id", transactionID.String()),
+		attribute.Int("limit", limit),
+		attribute.Int("offset", offset),
+	)
+
+	query := r.db.WithContext(ctx).
+		Model(&entity.TransactionDetail{}).
+		Where("transaction_id = ?", transactionID)
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to count transaction installments",
+			zap.Error(err),
+			zap.String("transaction_id", transactionID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to count installments: %w", err)
+	}
+
+	var installments []entity.TransactionDetail
+	if err := query.
+		Order("installment_number ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&installments).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get transaction installments",
+			zap.Error(err),
+			zap.String("transaction_id", transactionID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get installments: %w", err)
+	}
+
+	return installments, count, nil
+}
+
 func (r *transactionRepository) GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, filter entity.TransactionFilterRepository) ([]entity.Transaction, int64, error) {
 	tr := otel.Tracer("repository.transaction")
 	ctx, span := tr.Start(ctx, "GetAllByCustomerID")
@@ -145,6 +270,8 @@ func (r *transactionRepository) GetAllByCustomerID(ctx context.Context, customer
 	span.SetAttributes(
 		attribute.String("customer.id", customerID.String()),
 		attribute.String("status", string(filter.Status)),
+		attribute.String("sort_by", filter.SortBy),
+		attribute.String("sort_dir", filter.SortDir),
 		attribute.Int("limit", filter.Limit),
 		attribute.Int("offset", filter.Offset),
 	)
@@ -152,28 +279,41 @@ func (r *transactionRepository) GetAllByCustomerID(ctx context.Context, customer
 	var transactions []entity.Transaction
 	var count int64
 
-	query := r.db.WithContext(ctx).Model(&entity.Transaction{}).
-		Where("customer_id = ?", customerID)
-	if filter.Status != "" {
-		query = query.Where("status = ?", filter.Status)
-	}
+	query := r.customerTransactionsQuery(ctx, customerID, filter)
 
 	if err := query.Count(&count).Error; err != nil {
-		r.logger.Error("failed to count customer transactions",
+		loggerPkg.FromContext(ctx).Error("failed to count customer transactions",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
 		return nil, 0, fmt.Errorf("failed to count transactions: %w", err)
 	}
 
+	desc := filter.SortDir == "desc"
+	if filter.Cursor != nil {
+		operator := ">"
+		if desc {
+			operator = "<"
+		}
+		query = query.Where(fmt.Sprintf("(created_at, id) %s (?, ?)", operator), filter.Cursor.CreatedAt, filter.Cursor.ID)
+		span.SetAttributes(attribute.String("cursor.id", filter.Cursor.ID.String()))
+	}
+
 	if err := query.
-		Preload("TransactionDetail").
-		Preload("Asset").
-		Order("created_at DESC").
+		Preload("TransactionDetails", orderByInstallmentNumber).
+		Preload("Asset", withRetiredAssets).
+		Order(clause.OrderByColumn{
+			Column: clause.Column{Name: filter.SortBy},
+			Desc:   desc,
+		}).
+		Order(clause.OrderByColumn{
+			Column: clause.Column{Name: "id"},
+			Desc:   desc,
+		}).
 		Limit(filter.Limit).
 		Offset(filter.Offset).
 		Find(&transactions).Error; err != nil {
-		r.logger.Error("failed to get customer transactions",
+		loggerPkg.FromContext(ctx).Error("failed to get customer transactions",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
@@ -183,6 +323,166 @@ func (r *transactionRepository) GetAllByCustomerID(ctx context.Context, customer
 	return transactions, count, nil
 }
 
+// StreamByCustomerID walks the customer's transactions matching filter, newest
+// first, using row iteration so the full result set is never held in memory.
+func (r *transactionRepository) StreamByCustomerID(ctx context.Context, customerID uuid.UUID, filter entity.TransactionFilterRepository, fn func(transaction *entity.Transaction) error) (int, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "StreamByCustomerID")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("customer.id", customerID.String()),
+		attribute.String("status", string(filter.Status)),
+		attribute.Int("limit", filter.Limit),
+	)
+
+	query := r.customerTransactionsQuery(ctx, customerID, filter)
+	rows, err := query.
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(filter.Limit).
+		Rows()
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to query customer transactions for streaming",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return 0, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var transaction entity.Transaction
+		if err := query.ScanRows(rows, &transaction); err != nil {
+			return count, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if err := fn(&transaction); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to iterate transactions: %w", err)
+	}
+
+	return count, nil
+}
+
+// customerTransactionsQuery scopes a replica query to the customer's transactions
+// matching the status and created_at filters.
+func (r *transactionRepository) customerTransactionsQuery(ctx context.Context, customerID uuid.UUID, filter entity.TransactionFilterRepository) *gorm.DB {
+	query := r.db.WithReplica(ctx).Model(&entity.Transaction{}).
+		Where("customer_id = ?", customerID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+
+	return query
+}
+
+// SumOpenInstallmentAmounts totals the monthly installment amount of the
+// customer's pending and active transactions.
+func (r *transactionRepository) SumOpenInstallmentAmounts(ctx context.Context, customerID uuid.UUID) (float64, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "SumOpenInstallmentAmounts")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", customerID.String()))
+
+	var total float64
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Transaction{}).
+		Select("COALESCE(SUM(installment_amount), 0)").
+		Where("customer_id = ? AND status IN ?", customerID, []entity.TransactionStatus{
+			entity.TransactionStatusPending,
+			entity.TransactionStatusActive,
+		}).
+		Scan(&total).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to sum open installment amounts",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return 0, fmt.Errorf("failed to sum open installment amounts: %w", err)
+	}
+
+	return total, nil
+}
+
+func (r *transactionRepository) GetCustomerSummary(ctx context.Context, customerID uuid.UUID) (*entity.CustomerTransactionSummary, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "GetCustomerSummary")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", customerID.String()))
+
+	var transactionTotals struct {
+		TotalFinanced         float64
+		ActiveTransactions    int64
+		CompletedTransactions int64
+	}
+	var installmentTotals struct {
+		TotalPaid           float64
+		TotalOutstanding    float64
+		OverdueInstallments int64
+	}
+
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Transaction{}).
+		Select(`COALESCE(SUM(otr_amount + admin_fee + interest_amount), 0) AS total_financed,
+			COUNT(CASE WHEN status = ? THEN 1 END) AS active_transactions,
+			COUNT(CASE WHEN status = ? THEN 1 END) AS completed_transactions`,
+			entity.TransactionStatusActive,
+			entity.TransactionStatusCompleted,
+		).
+		Where("customer_id = ? AND status <> ?", customerID, entity.TransactionStatusCancelled).
+		Scan(&transactionTotals).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to aggregate customer transactions",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+
+	if err := r.db.WithContext(ctx).
+		Model(&entity.TransactionDetail{}).
+		Select(`COALESCE(SUM(transaction_details.paid_amount), 0) AS total_paid,
+			COALESCE(SUM(CASE WHEN transaction_details.status <> ? THEN transaction_details.amount + transaction_details.late_fee - transaction_details.paid_amount END), 0) AS total_outstanding,
+			COUNT(CASE WHEN transaction_details.status = ? THEN 1 END) AS overdue_installments`,
+			entity.TransactionDetailStatusPaid,
+			entity.TransactionDetailStatusOverdue,
+		).
+		Joins("JOIN transactions ON transactions.id = transaction_details.transaction_id").
+		Where("transactions.customer_id = ? AND transactions.status <> ?", customerID, entity.TransactionStatusCancelled).
+		Scan(&installmentTotals).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to aggregate customer installments",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, fmt.Errorf("failed to aggregate installments: %w", err)
+	}
+
+	summary := entity.CustomerTransactionSummary{
+		CustomerID:            customerID,
+		TotalFinanced:         transactionTotals.TotalFinanced,
+		TotalPaid:             installmentTotals.TotalPaid,
+		TotalOutstanding:      installmentTotals.TotalOutstanding,
+		OverdueInstallments:   installmentTotals.OverdueInstallments,
+		ActiveTransactions:    transactionTotals.ActiveTransactions,
+		CompletedTransactions: transactionTotals.CompletedTransactions,
+	}
+
+	return &summary, nil
+}
+
+// UpdateStatus locks the transaction and moves it to status, rejecting the
+// change with ErrInvalidStatusTransition when the stored status does not allow it.
 func (r *transactionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status entity.TransactionStatus) error {
 	tr := otel.Tracer("repository.transaction")
 	ctx, span := tr.Start(ctx, "UpdateStatus")
@@ -193,35 +493,828 @@ func (r *transactionRepository) UpdateStatus(ctx context.Context, id uuid.UUID,
 		attribute.String("status", string(status)),
 	)
 
-	return r.db.Transaction(ctx, func(tx *gorm.DB) error {
+	var previous entity.TransactionStatus
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
 		var transaction entity.Transaction
-		if err := tx.First(&transaction, "id = ?", id).Error; err != nil {
-			r.logger.Error("failed to get transaction for status update",
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&transaction, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrTransactionNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get transaction for status update",
 				zap.Error(err),
 				zap.String("transaction_id", id.String()),
 			)
 			return fmt.Errorf("failed to get transaction: %w", err)
 		}
+		previous = transaction.Status
+
+		//The caller's check may have used a cached or concurrently changed status
+		if !transaction.Status.CanTransitionTo(status) {
+			return entity.NewInvalidStatusTransitionError(transaction.Status, status)
+		}
+
+		return r.updateStatusTx(tx, &transaction, status)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.invalidateTransactionCache(ctx, id)
+	r.publishStatusChange(ctx, id, previous, status)
+
+	return nil
+}
+
+// BatchUpdateStatus moves every transaction in ids to status in a single
+// database transaction. The rows are locked and each transition is checked
+// first; if any ID is missing or cannot make the transition nothing is
+// written and applied is false. The results follow the order of ids.
+func (r *transactionRepository) BatchUpdateStatus(ctx context.Context, ids []uuid.UUID, status entity.TransactionStatus) ([]entity.BatchUpdateStatusResult, bool, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "BatchUpdateStatus")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("transaction.count", len(ids)),
+		attribute.String("status", string(status)),
+	)
 
-		if err := tx.Model(&transaction).Update("status", status).Error; err != nil {
-			r.logger.Error("failed to update transaction status",
+	results := make([]entity.BatchUpdateStatusResult, len(ids))
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		//Locked in primary key order so concurrent batches cannot deadlock
+		var transactions []entity.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", ids).
+			Order("id ASC").
+			Find(&transactions).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to get transactions for batch status update",
+				zap.Error(err),
+				zap.Int("count", len(ids)),
+			)
+			return fmt.Errorf("failed to get transactions: %w", err)
+		}
+
+		byID := make(map[uuid.UUID]*entity.Transaction, len(transactions))
+		for i := range transactions {
+			byID[transactions[i].ID] = &transactions[i]
+		}
+
+		rejected := false
+		for i, id := range ids {
+			result := entity.BatchUpdateStatusResult{TransactionID: id}
+
+			transaction, found := byID[id]
+			switch {
+			case !found:
+				result.Error = entity.ErrTransactionNotFound.Error()
+				result.ErrorCode = entity.ErrTransactionNotFound.Code
+			case !transaction.Status.CanTransitionTo(status):
+				result.PreviousStatus = transaction.Status
+				result.Error = entity.NewInvalidStatusTransitionError(transaction.Status, status).Error()
+				result.ErrorCode = entity.ErrInvalidStatusTransition.Code
+			default:
+				result.PreviousStatus = transaction.Status
+				result.Success = true
+			}
+			if !result.Success {
+				rejected = true
+			}
+
+			results[i] = result
+		}
+
+		if rejected {
+			return entity.ErrBatchStatusUpdateRejected
+		}
+
+		for i := range transactions {
+			if err := r.updateStatusTx(tx, &transactions[i], status); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err == entity.ErrBatchStatusUpdateRejected {
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, result := range results {
+		r.invalidateTransactionCache(ctx, result.TransactionID)
+		r.publishStatusChange(ctx, result.TransactionID, result.PreviousStatus, status)
+	}
+
+	return results, true, nil
+}
+
+// PayInstallment applies amount to the installment, marking it paid once the
+// accumulated payments cover it and any late fee. Any excess is rejected unless allowOverpay is
+// set, in which case it is carried to the following unpaid installments in order.
+func (r *transactionRepository) PayInstallment(ctx context.Context, transactionID uuid.UUID, installmentNumber int, amount float64, allowOverpay bool) (*entity.TransactionDetail, entity.TransactionStatus, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "PayInstallment")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("transaction.id", transactionID.String()),
+		attribute.Int("installment.number", installmentNumber),
+		attribute.Float64("amount", amount),
+		attribute.Bool("allow_overpay", allowOverpay),
+	)
+
+	var transaction entity.Transaction
+	var installment entity.TransactionDetail
+	var previous entity.TransactionStatus
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&transaction, "id = ?", transactionID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrTransactionNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get transaction for installment payment",
+				zap.Error(err),
+				zap.String("transaction_id", transactionID.String()),
+			)
+			return fmt.Errorf("failed to get transaction: %w", err)
+		}
+		previous = transaction.Status
+
+		//Cancelled and completed transactions keep their installment rows
+		if !transaction.Status.IsOpen() {
+			return entity.ErrTransactionNotPayable
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("transaction_id = ? AND installment_number = ?", transactionID, installmentNumber).
+			First(&installment).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrInstallmentNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get installment for payment",
+				zap.Error(err),
+				zap.String("transaction_id", transactionID.String()),
+				zap.Int("installment_number", installmentNumber),
+			)
+			return fmt.Errorf("failed to get installment: %w", err)
+		}
+
+		if installment.Status == entity.TransactionDetailStatusPaid {
+			return entity.ErrInstallmentAlreadyPaid
+		}
+
+		payment := math.Min(amount, installment.RemainingAmount())
+		excess := math.Round((amount-payment)*100) / 100
+		if excess > 0 && !allowOverpay {
+			return entity.ErrInstallmentOverpayment
+		}
+
+		if err := applyInstallmentPaymentTx(tx, &installment, payment, r.clock.Now()); err != nil {
+			return err
+		}
+
+		if excess > 0 {
+			var following []entity.TransactionDetail
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("transaction_id = ? AND installment_number > ? AND status <> ?", transactionID, installmentNumber, entity.TransactionDetailStatusPaid).
+				Order("installment_number ASC").
+				Find(&following).Error; err != nil {
+				loggerPkg.FromContext(ctx).Error("failed to get following installments for overpayment",
+					zap.Error(err),
+					zap.String("transaction_id", transactionID.String()),
+				)
+				return fmt.Errorf("failed to get following installments: %w", err)
+			}
+
+			for i := range following {
+				if excess <= 0 {
+					break
+				}
+				carried := math.Min(excess, following[i].RemainingAmount())
+				if err := applyInstallmentPaymentTx(tx, &following[i], carried, r.clock.Now()); err != nil {
+					return err
+				}
+				excess = math.Round((excess-carried)*100) / 100
+			}
+
+			//More than the whole outstanding balance was paid
+			if excess > 0 {
+				return entity.ErrInstallmentOverpayment
+			}
+		}
+
+		var remaining int64
+		if err := tx.Model(&entity.TransactionDetail{}).
+			Where("transaction_id = ? AND status IN ?", transactionID, []entity.TransactionDetailStatus{
+				entity.TransactionDetailStatusPending,
+				entity.TransactionDetailStatusOverdue,
+			}).
+			Count(&remaining).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to count remaining installments",
+				zap.Error(err),
+				zap.String("transaction_id", transactionID.String()),
+			)
+			return fmt.Errorf("failed to count remaining installments: %w", err)
+		}
+
+		status := transaction.Status.AfterInstallmentPaid(remaining)
+		if status == transaction.Status {
+			return nil
+		}
+
+		return r.updateStatusTx(tx, &transaction, status)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	r.invalidateTransactionCache(ctx, transactionID)
+	r.publishStatusChange(ctx, transactionID, previous, transaction.Status)
+
+	return &installment, transaction.Status, nil
+}
+
+func (r *transactionRepository) Cancel(ctx context.Context, id uuid.UUID) error {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "Cancel")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("transaction.id", id.String()))
+
+	var creditLimit entity.CreditLimit
+	var assetID uuid.UUID
+	var previous entity.TransactionStatus
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		var transaction entity.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&transaction, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrTransactionNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get transaction for cancellation",
 				zap.Error(err),
 				zap.String("transaction_id", id.String()),
 			)
-			return fmt.Errorf("failed to update transaction status: %w", err)
+			return fmt.Errorf("failed to get transaction: %w", err)
+		}
+
+		if !transaction.Status.CanTransitionTo(entity.TransactionStatusCancelled) {
+			return entity.ErrTransactionNotCancellable
 		}
+		previous = transaction.Status
 
+		var paidCount int64
+		if err := tx.Model(&entity.TransactionDetail{}).
+			Where("transaction_id = ? AND (status = ? OR paid_amount > 0)", id, entity.TransactionDetailStatusPaid).
+			Count(&paidCount).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to count paid installments",
+				zap.Error(err),
+				zap.String("transaction_id", id.String()),
+			)
+			return fmt.Errorf("failed to count paid installments: %w", err)
+		}
+
+		if paidCount > 0 {
+			return entity.ErrTransactionHasPayments
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ? AND tenor_month = ?", transaction.CustomerID, transaction.TenorMonth).
+			First(&creditLimit).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to get credit limit for cancellation",
+				zap.Error(err),
+				zap.String("customer_id", transaction.CustomerID.String()),
+			)
+			return fmt.Errorf("failed to get credit limit: %w", err)
+		}
+
+		previousUsed := creditLimit.UsedAmount
+		creditLimit.UsedAmount -= transaction.TotalAmount()
+		if creditLimit.UsedAmount < 0 {
+			creditLimit.UsedAmount = 0
+		}
+		if err := tx.Save(&creditLimit).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to restore credit limit",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimit.ID.String()),
+			)
+			return fmt.Errorf("failed to restore credit limit: %w", err)
+		}
+
+		if err := recordCreditLimitChange(tx, &creditLimit, creditLimit.UsedAmount-previousUsed, entity.LedgerReasonCancellation(transaction.ID), r.clock.Now()); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to record credit limit ledger entry",
+				zap.Error(err),
+				zap.String("credit_limit_id", creditLimit.ID.String()),
+			)
+			return err
+		}
+
+		if err := tx.Model(&entity.Asset{}).
+			Where("id = ?", transaction.AssetID).
+			UpdateColumn("stock", gorm.Expr("stock + 1")).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to restore asset stock",
+				zap.Error(err),
+				zap.String("asset_id", transaction.AssetID.String()),
+			)
+			return fmt.Errorf("failed to restore asset stock: %w", err)
+		}
+		assetID = transaction.AssetID
+
+		return r.updateStatusTx(tx, &transaction, entity.TransactionStatusCancelled)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.invalidateTransactionCache(ctx, id)
+	invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &creditLimit)
+	invalidateAssetCache(ctx, r.redis, loggerPkg.FromContext(ctx), assetID)
+	r.publishStatusChange(ctx, id, previous, entity.TransactionStatusCancelled)
+
+	return nil
+}
+
+func (r *transactionRepository) Settle(ctx context.Context, id uuid.UUID, quote entity.PayoffQuoteFunc) (*entity.PayoffResponse, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "Settle")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("transaction.id", id.String()))
+
+	var payoff *entity.PayoffResponse
+	var creditLimit entity.CreditLimit
+	var previous entity.TransactionStatus
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		var transaction entity.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&transaction, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return entity.ErrTransactionNotFound
+			}
+			loggerPkg.FromContext(ctx).Error("failed to get transaction for settlement",
+				zap.Error(err),
+				zap.String("transaction_id", id.String()),
+			)
+			return fmt.Errorf("failed to get transaction: %w", err)
+		}
+
+		if transaction.Status != entity.TransactionStatusPending &&
+			transaction.Status != entity.TransactionStatusActive {
+			return entity.ErrTransactionNotSettleable
+		}
+		previous = transaction.Status
+
+		var unpaid []entity.TransactionDetail
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("transaction_id = ? AND status <> ?", id, entity.TransactionDetailStatusPaid).
+			Order("installment_number ASC").
+			Find(&unpaid).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to get unpaid installments for settlement",
+				zap.Error(err),
+				zap.String("transaction_id", id.String()),
+			)
+			return fmt.Errorf("failed to get unpaid installments: %w", err)
+		}
+
+		if len(unpaid) == 0 {
+			return entity.ErrTransactionNotSettleable
+		}
+
+		payoff = quote(&transaction, unpaid)
+
+		if err := tx.Model(&entity.TransactionDetail{}).
+			Where("transaction_id = ? AND status <> ?", id, entity.TransactionDetailStatusPaid).
+			Updates(map[string]interface{}{
+				"status":      entity.TransactionDetailStatusPaid,
+				"paid_amount": gorm.Expr("amount + late_fee"),
+				"updated_at":  r.clock.Now(),
+			}).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to mark installments as paid",
+				zap.Error(err),
+				zap.String("transaction_id", id.String()),
+			)
+			return fmt.Errorf("failed to mark installments as paid: %w", err)
+		}
+
+		if payoff.WaivedInterest > 0 {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("customer_id = ? AND tenor_month = ?", transaction.CustomerID, transaction.TenorMonth).
+				First(&creditLimit).Error; err != nil {
+				loggerPkg.FromContext(ctx).Error("failed to get credit limit for settlement",
+					zap.Error(err),
+					zap.String("customer_id", transaction.CustomerID.String()),
+				)
+				return fmt.Errorf("failed to get credit limit: %w", err)
+			}
+
+			previousUsed := creditLimit.UsedAmount
+			creditLimit.UsedAmount -= payoff.WaivedInterest
+			if creditLimit.UsedAmount < 0 {
+				creditLimit.UsedAmount = 0
+			}
+			if err := tx.Save(&creditLimit).Error; err != nil {
+				loggerPkg.FromContext(ctx).Error("failed to release credit limit",
+					zap.Error(err),
+					zap.String("credit_limit_id", creditLimit.ID.String()),
+				)
+				return fmt.Errorf("failed to release credit limit: %w", err)
+			}
+
+			if err := recordCreditLimitChange(tx, &creditLimit, creditLimit.UsedAmount-previousUsed, entity.LedgerReasonSettlement(transaction.ID), r.clock.Now()); err != nil {
+				loggerPkg.FromContext(ctx).Error("failed to record credit limit ledger entry",
+					zap.Error(err),
+					zap.String("credit_limit_id", creditLimit.ID.String()),
+				)
+				return err
+			}
+		}
+
+		return r.updateStatusTx(tx, &transaction, entity.TransactionStatusCompleted)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidateTransactionCache(ctx, id)
+	if creditLimit.ID != uuid.Nil {
+		invalidateCreditLimitCache(ctx, r.redis, loggerPkg.FromContext(ctx), &creditLimit)
+	}
+	r.publishStatusChange(ctx, id, previous, entity.TransactionStatusCompleted)
+
+	return payoff, nil
+}
+
+// MarkOverdueInstallments flips pending installments past their due date to overdue
+// and (re)computes the late fee of every overdue installment as lateFeeRate percent
+// of the installment amount for each month started since the due date. The fee is
+// assigned rather than accumulated, so running the sweep repeatedly is idempotent.
+// Only installments of pending or active transactions are touched.
+func (r *transactionRepository) MarkOverdueInstallments(ctx context.Context, lateFeeRate float64) (int, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "MarkOverdueInstallments")
+	defer span.End()
+
+	span.SetAttributes(attribute.Float64("late_fee_rate", lateFeeRate))
+
+	now := r.clock.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var affected int64
+	var staleIDs []uuid.UUID
+	err := r.db.Transaction(ctx, func(tx *gorm.DB) error {
+		//Cancelled and completed transactions keep their installment rows but no longer run
+		openTransactions := tx.Session(&gorm.Session{NewDB: true}).
+			Model(&entity.Transaction{}).
+			Select("id").
+			Where("status IN ?", []entity.TransactionStatus{
+				entity.TransactionStatusPending,
+				entity.TransactionStatusActive,
+			})
+		lateFee := gorm.Expr(
+			"ROUND(amount * ? / 100 * (TIMESTAMPDIFF(MONTH, due_date, ?) + 1), 2)",
+			lateFeeRate, today,
+		)
+
+		//Only transactions with an installment about to change need their cache dropped
+		changing := tx.Model(&entity.TransactionDetail{}).
+			Where("transaction_id IN (?)", openTransactions)
+		if lateFeeRate > 0 {
+			changing = changing.Where("(due_date < ? AND status = ?) OR (status = ? AND late_fee <> ?)",
+				today, entity.TransactionDetailStatusPending, entity.TransactionDetailStatusOverdue, lateFee)
+		} else {
+			changing = changing.Where("due_date < ? AND status = ?", today, entity.TransactionDetailStatusPending)
+		}
+		if err := changing.
+			Distinct().
+			Pluck("transaction_id", &staleIDs).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to collect transactions affected by overdue sweep",
+				zap.Error(err),
+			)
+			return fmt.Errorf("failed to collect affected transactions: %w", err)
+		}
+		if len(staleIDs) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&entity.TransactionDetail{}).
+			Where("transaction_id IN ? AND due_date < ? AND status = ?", staleIDs, today, entity.TransactionDetailStatusPending).
+			Updates(map[string]interface{}{
+				"status":     entity.TransactionDetailStatusOverdue,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			loggerPkg.FromContext(ctx).Error("failed to mark overdue installments",
+				zap.Error(result.Error),
+			)
+			return fmt.Errorf("failed to mark overdue installments: %w", result.Error)
+		}
+		affected = result.RowsAffected
+
+		if lateFeeRate <= 0 {
+			return nil
+		}
+
+		if err := tx.Model(&entity.TransactionDetail{}).
+			Where("transaction_id IN ? AND status = ? AND late_fee <> ?", staleIDs, entity.TransactionDetailStatusOverdue, lateFee).
+			Update("late_fee", lateFee).Error; err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to accrue late fees",
+				zap.Error(err),
+			)
+			return fmt.Errorf("failed to accrue late fees: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.invalidateTransactionCache(ctx, staleIDs...)
+
+	span.SetAttributes(attribute.Int64("rows_affected", affected))
+
+	return int(affected), nil
+}
+
+// ReserveIdempotencyKey claims the key for a request about to create a
+// transaction. It returns false when another request already holds the key.
+func (r *transactionRepository) ReserveIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) (bool, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "ReserveIdempotencyKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", customerID.String()))
+
+	cacheKey := cacher.GetTransactionIdempotencyCacheKey(customerID, idempotencyKey)
+	reserved, err := r.redis.SetNX(ctx, cacheKey, idempotencyKeyPending, entity.IdempotencyCacheTTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	return reserved, nil
+}
+
+// GetIdempotencyKey returns the transaction created under the key, uuid.Nil
+// when the key is unused, or ErrIdempotencyKeyInProgress while the request
+// holding it has not finished.
+func (r *transactionRepository) GetIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) (uuid.UUID, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "GetIdempotencyKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", customerID.String()))
+
+	cachedID, err := r.redis.Get(ctx, cacher.GetTransactionIdempotencyCacheKey(customerID, idempotencyKey))
+	if err != nil {
+		return uuid.Nil, nil
+	}
+	if cachedID == idempotencyKeyPending {
+		return uuid.Nil, entity.ErrIdempotencyKeyInProgress
+	}
+
+	transactionID, err := uuid.Parse(cachedID)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Warn("invalid transaction id stored for idempotency key",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return uuid.Nil, nil
+	}
+
+	return transactionID, nil
+}
+
+func (r *transactionRepository) SetIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string, transactionID uuid.UUID) error {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "SetIdempotencyKey")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("customer.id", customerID.String()),
+		attribute.String("transaction.id", transactionID.String()),
+	)
+
+	cacheKey := cacher.GetTransactionIdempotencyCacheKey(customerID, idempotencyKey)
+	if err := r.redis.Set(ctx, cacheKey, transactionID.String(), entity.IdempotencyCacheTTL); err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a reservation whose request failed, so a retry
+// with the same key is processed. Keys already bound to a transaction are kept.
+func (r *transactionRepository) ReleaseIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) error {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "ReleaseIdempotencyKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("customer.id", customerID.String()))
+
+	cacheKey := cacher.GetTransactionIdempotencyCacheKey(customerID, idempotencyKey)
+	value, err := r.redis.Get(ctx, cacheKey)
+	if err != nil || value != idempotencyKeyPending {
 		return nil
+	}
+
+	if err := r.redis.Del(ctx, cacheKey); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *transactionRepository) LockCreditLimit(ctx context.Context, customerID uuid.UUID, tenorMonth int) (func(), bool, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "LockCreditLimit")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("customer.id", customerID.String()),
+		attribute.Int("tenor.month", tenorMonth),
+	)
+
+	release, acquired, err := r.redis.Lock(ctx, cacher.GetCreditLimitLockKey(customerID, tenorMonth), entity.CreditLimitLockTTL)
+	if err != nil {
+		return release, false, fmt.Errorf("failed to acquire credit limit lock: %w", err)
+	}
+
+	return release, acquired, nil
+}
+
+// GetInstallmentsDueBetween returns the unpaid installments due in [from, to)
+// of transactions that are still running.
+func (r *transactionRepository) GetInstallmentsDueBetween(ctx context.Context, from, to time.Time) ([]entity.TransactionDetail, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "GetInstallmentsDueBetween")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("from", from.Format(time.RFC3339)),
+		attribute.String("to", to.Format(time.RFC3339)),
+	)
+
+	var installments []entity.TransactionDetail
+	if err := r.db.WithContext(ctx).
+		Joins("JOIN transactions ON transactions.id = transaction_details.transaction_id").
+		Where("transaction_details.status = ? AND transaction_details.due_date >= ? AND transaction_details.due_date < ?",
+			entity.TransactionDetailStatusPending, from, to).
+		Where("transactions.status IN ?", []entity.TransactionStatus{
+			entity.TransactionStatusPending,
+			entity.TransactionStatusActive,
+		}).
+		Order("transaction_details.due_date ASC").
+		Find(&installments).Error; err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get installments due between",
+			zap.Error(err),
+			zap.Time("from", from),
+			zap.Time("to", to),
+		)
+		return nil, fmt.Errorf("failed to get due installments: %w", err)
+	}
+
+	return installments, nil
+}
+
+// MarkReminderSent claims the reminder for the installment on day, returning
+// false when it was already claimed.
+func (r *transactionRepository) MarkReminderSent(ctx context.Context, installmentID uuid.UUID, day time.Time) (bool, error) {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "MarkReminderSent")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("installment.id", installmentID.String()))
+
+	cacheKey := cacher.GetInstallmentReminderMarkerKey(installmentID, day.Format("2006-01-02"))
+	acquired, err := r.redis.SetNX(ctx, cacheKey, r.clock.Now().Format(time.RFC3339), entity.ReminderMarkerTTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to set reminder marker: %w", err)
+	}
+
+	return acquired, nil
+}
+
+// ClearReminderMarker releases a claim made by MarkReminderSent so the reminder
+// is retried on the next run.
+func (r *transactionRepository) ClearReminderMarker(ctx context.Context, installmentID uuid.UUID, day time.Time) error {
+	tr := otel.Tracer("repository.transaction")
+	ctx, span := tr.Start(ctx, "ClearReminderMarker")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("installment.id", installmentID.String()))
+
+	if err := r.redis.Del(ctx, cacher.GetInstallmentReminderMarkerKey(installmentID, day.Format("2006-01-02"))); err != nil {
+		return fmt.Errorf("failed to clear reminder marker: %w", err)
+	}
+
+	return nil
+}
+
+// applyInstallmentPaymentTx adds amount to the installment's paid amount and
+// marks it paid once nothing remains.
+func applyInstallmentPaymentTx(tx *gorm.DB, installment *entity.TransactionDetail, amount float64, now time.Time) error {
+	installment.PaidAmount = math.Round((installment.PaidAmount+amount)*100) / 100
+	if installment.RemainingAmount() <= 0 {
+		installment.Status = entity.TransactionDetailStatusPaid
+	}
+	installment.UpdatedAt = now
+
+	if err := tx.Save(installment).Error; err != nil {
+		loggerPkg.FromContext(tx.Statement.Context).Error("failed to update installment payment",
+			zap.Error(err),
+			zap.String("installment_id", installment.ID.String()),
+		)
+		return fmt.Errorf("failed to update installment: %w", err)
+	}
+
+	return nil
+}
+
+func (r *transactionRepository) updateStatusTx(tx *gorm.DB, transaction *entity.Transaction, status entity.TransactionStatus) error {
+	if err := tx.Model(transaction).Update("status", status).Error; err != nil {
+		loggerPkg.FromContext(tx.Statement.Context).Error("failed to update transaction status",
+			zap.Error(err),
+			zap.String("transaction_id", transaction.ID.String()),
+		)
+		return fmt.Errorf("failed to update transaction status: %w", err)
+	}
+	transaction.Status = status
+
+	return nil
+}
+
+// publishStatusChange announces a committed status transition; it is a no-op
+// when the status did not change.
+func (r *transactionRepository) publishStatusChange(ctx context.Context, id uuid.UUID, from, to entity.TransactionStatus) {
+	if from == to {
+		return
+	}
+
+	r.publisher.Publish(ctx, entity.EventTransactionStatusChanged, entity.TransactionStatusChangedEvent{
+		TransactionID: id,
+		OldStatus:     from,
+		NewStatus:     to,
+		Timestamp:     r.clock.Now(),
 	})
 }
 
-func (r *transactionRepository) generateInstallments(transaction *entity.Transaction) []entity.TransactionDetail {
+func (r *transactionRepository) cacheTransaction(ctx context.Context, transaction *entity.Transaction) {
+	transactionJSON, err := json.Marshal(transaction)
+	if err != nil {
+		return
+	}
+
+	if err := r.redis.SetWithJitter(ctx, cacher.GetTransactionCacheKey(transaction.ID), string(transactionJSON), entity.DefaultCacheTTL); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to cache transaction",
+			zap.Error(err),
+			zap.String("transaction_id", transaction.ID.String()),
+		)
+	}
+}
+
+func (r *transactionRepository) invalidateTransactionCache(ctx context.Context, ids ...uuid.UUID) {
+	if len(ids) == 0 {
+		return
+	}
+
+	cacheKeys := make([]string, len(ids))
+	for i, id := range ids {
+		cacheKeys[i] = cacher.GetTransactionCacheKey(id)
+	}
+
+	if err := r.redis.Del(ctx, cacheKeys...); err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to invalidate transaction cache",
+			zap.Error(err),
+			zap.Strings("cache_keys", cacheKeys),
+		)
+	}
+}
+
+func orderByInstallmentNumber(db *gorm.DB) *gorm.DB {
+	return db.Order("installment_number ASC")
+}
+
+// withRetiredAssets keeps soft-deleted assets in the preload so existing
+// transactions still show the asset they were made for.
+func withRetiredAssets(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// generateInstallments schedules the installments from the transaction's
+// CreatedAt, which the service sets from its clock.
+func (r *transactionRepository) generateInstallments(transaction *entity.Transaction, schedule []float64) []entity.TransactionDetail {
 	installments := make([]entity.TransactionDetail, transaction.TenorMonth)
-	installmentAmount := transaction.InstallmentAmount
-	dueDate := time.Now().UTC()
+	start := transaction.CreatedAt
 
 	for i := 0; i < transaction.TenorMonth; i++ {
-		dueDate = dueDate.AddDate(0, 1, 0)
+		dueDate := entity.InstallmentDueDate(start, i+1, transaction.BillingDay)
+		installmentAmount := transaction.InstallmentAmount
+		if i < len(schedule) {
+			installmentAmount = schedule[i]
+		}
 		installments[i] = entity.TransactionDetail{
 			ID:                uuid.New(),
 			TransactionID:     transaction.ID,
@@ -229,8 +1322,8 @@ func (r *transactionRepository) generateInstallments(transaction *entity.Transac
 			Amount:            installmentAmount,
 			DueDate:           dueDate,
 			Status:            entity.TransactionDetailStatusPending,
-			CreatedAt:         time.Now().UTC(),
-			UpdatedAt:         time.Now().UTC(),
+			CreatedAt:         start,
+			UpdatedAt:         start,
 		}
 	}
 
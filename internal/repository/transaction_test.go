@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"testing"
+)
+
+func newTestTransactionRepository(t *testing.T) (*transactionRepository, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock := newMockDB(t)
+	redisClient, _ := newTestRedis(t)
+
+	repo := NewTransactionRepository(db, redisClient, &recordingPublisher{}, fixedClock{now: testNow}, zap.NewNop())
+	return repo.(*transactionRepository), mock
+}
+
+func TestTransactionRepositoryCreateDeductsTotalOnce(t *testing.T) {
+	repo, mock := newTestTransactionRepository(t)
+
+	customerID := uuid.New()
+	creditLimitID := uuid.New()
+	transaction := &entity.Transaction{
+		ID:                uuid.New(),
+		CustomerID:        customerID,
+		AssetID:           uuid.New(),
+		ContractNumber:    "KP-TEST-0001",
+		OTRAmount:         1000,
+		AdminFee:          50,
+		InterestAmount:    150,
+		TenorMonth:        3,
+		InstallmentAmount: 400,
+		InterestType:      entity.InterestTypeFlat,
+		Status:            entity.TransactionStatusPending,
+		CreatedAt:         testNow,
+		UpdatedAt:         testNow,
+	}
+	total := transaction.TotalAmount()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `credit_limits` WHERE .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "tenor_month", "limit_amount", "used_amount"}).
+			AddRow(creditLimitID.String(), customerID.String(), 3, 5000.0, 0.0))
+	mock.ExpectExec("INSERT INTO `transactions`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `assets` SET `stock`=stock - 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `transaction_details`").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE `credit_limits` SET `customer_id`=\\?,`tenor_month`=\\?,`limit_amount`=\\?,`used_amount`=\\?").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), floatArg(total),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `credit_limit_ledgers`").
+		WithArgs(sqlmock.AnyArg(), creditLimitID.String(), floatArg(total), sqlmock.AnyArg(), floatArg(total), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Create(context.Background(), transaction, []float64{400, 400, 400}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+}
+
+// expectTransactionLoad expects GetByID's query and its preloads for a
+// transaction with the given number of installments.
+func expectTransactionLoad(mock sqlmock.Sqlmock, id uuid.UUID, installments int) {
+	customerID := uuid.New()
+	assetID := uuid.New()
+
+	mock.ExpectQuery("SELECT \\* FROM `transactions` WHERE id = \\?").
+		WithArgs(id, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "asset_id", "tenor_month", "status"}).
+			AddRow(id.String(), customerID.String(), assetID.String(), installments, "active"))
+	mock.ExpectQuery("SELECT \\* FROM `assets` WHERE `assets`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(assetID.String()))
+	mock.ExpectQuery("SELECT \\* FROM `customers` WHERE `customers`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(customerID.String()))
+	details := sqlmock.NewRows([]string{"id", "transaction_id", "installment_number", "amount", "status"})
+	for i := 1; i <= installments; i++ {
+		details.AddRow(uuid.New().String(), id.String(), i, 100.0, "pending")
+	}
+	mock.ExpectQuery("SELECT \\* FROM `transaction_details` WHERE `transaction_details`.`transaction_id` = \\? ORDER BY installment_number ASC").
+		WillReturnRows(details)
+}
+
+func TestTransactionRepositoryGetByIDPreloadsEveryInstallment(t *testing.T) {
+	repo, mock := newTestTransactionRepository(t)
+	id := uuid.New()
+	expectTransactionLoad(mock, id, 6)
+
+	transaction, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID returned error: %v", err)
+	}
+	if got := len(transaction.TransactionDetails); got != 6 {
+		t.Fatalf("got %d installments, want 6", got)
+	}
+	for i, detail := range transaction.TransactionDetails {
+		if detail.InstallmentNumber != i+1 {
+			t.Errorf("installment %d has number %d", i, detail.InstallmentNumber)
+		}
+	}
+}
+
+func TestTransactionRepositoryGetByIDServesRepeatReadsFromCache(t *testing.T) {
+	repo, mock := newTestTransactionRepository(t)
+	id := uuid.New()
+	//Expected once; a second load would fail as an unexpected query
+	expectTransactionLoad(mock, id, 3)
+
+	first, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("first GetByID returned error: %v", err)
+	}
+	second, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("second GetByID returned error: %v", err)
+	}
+
+	if second.ID != first.ID || len(second.TransactionDetails) != len(first.TransactionDetails) {
+		t.Errorf("cached transaction %+v differs from loaded %+v", second, first)
+	}
+	if len(second.TransactionDetails) != 3 {
+		t.Errorf("cached transaction has %d installments, want 3", len(second.TransactionDetails))
+	}
+}
+
+func TestTransactionRepositoryUpdateStatusInvalidatesCache(t *testing.T) {
+	repo, mock := newTestTransactionRepository(t)
+	id := uuid.New()
+	expectTransactionLoad(mock, id, 3)
+
+	if _, err := repo.GetByID(context.Background(), id); err != nil {
+		t.Fatalf("GetByID returned error: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `transactions` WHERE id = \\? .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(id.String(), "active"))
+	mock.ExpectExec("UPDATE `transactions` SET `status`=\\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := repo.UpdateStatus(context.Background(), id, entity.TransactionStatusCompleted); err != nil {
+		t.Fatalf("UpdateStatus returned error: %v", err)
+	}
+
+	//The status change must drop the cached copy, so this read reloads
+	expectTransactionLoad(mock, id, 3)
+	if _, err := repo.GetByID(context.Background(), id); err != nil {
+		t.Fatalf("GetByID after update returned error: %v", err)
+	}
+}
+
+func TestTransactionRepositoryUpdateStatusRechecksTransitionUnderLock(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		current entity.TransactionStatus
+		next    entity.TransactionStatus
+		wantErr error
+	}{
+		{"pending to active", entity.TransactionStatusPending, entity.TransactionStatusActive, nil},
+		{"active to completed", entity.TransactionStatusActive, entity.TransactionStatusCompleted, nil},
+		{"completed to pending", entity.TransactionStatusCompleted, entity.TransactionStatusPending, entity.ErrInvalidStatusTransition},
+		{"completed to active", entity.TransactionStatusCompleted, entity.TransactionStatusActive, entity.ErrInvalidStatusTransition},
+		{"active to pending", entity.TransactionStatusActive, entity.TransactionStatusPending, entity.ErrInvalidStatusTransition},
+		{"cancelled to active", entity.TransactionStatusCancelled, entity.TransactionStatusActive, entity.ErrInvalidStatusTransition},
+		{"pending to completed", entity.TransactionStatusPending, entity.TransactionStatusCompleted, entity.ErrInvalidStatusTransition},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newTestTransactionRepository(t)
+			id := uuid.New()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT \\* FROM `transactions` WHERE id = \\? .* FOR UPDATE").
+				WithArgs(id, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(id.String(), string(tc.current)))
+			if tc.wantErr == nil {
+				mock.ExpectExec("UPDATE `transactions` SET `status`=\\?").
+					WithArgs(string(tc.next), sqlmock.AnyArg(), id.String()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			err := repo.UpdateStatus(context.Background(), id, tc.next)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("UpdateStatus error = %v, want %v", err, tc.wantErr)
+			}
+
+			events := repo.publisher.(*recordingPublisher).published()
+			if tc.wantErr != nil {
+				if len(events) != 0 {
+					t.Errorf("rejected transition published %d events", len(events))
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("published %d events, want 1", len(events))
+			}
+			event := events[0].(entity.TransactionStatusChangedEvent)
+			if event.OldStatus != tc.current || event.NewStatus != tc.next || !event.Timestamp.Equal(testNow) {
+				t.Errorf("published %+v", event)
+			}
+		})
+	}
+}
+
+func TestTransactionRepositoryPayInstallmentLeavesLateFeeOwed(t *testing.T) {
+	repo, mock := newTestTransactionRepository(t)
+	id := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `transactions` WHERE id = \\? .* FOR UPDATE").
+		WithArgs(id, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(id.String(), "active"))
+	mock.ExpectQuery("SELECT \\* FROM `transaction_details` WHERE transaction_id = \\? AND installment_number = \\? .* FOR UPDATE").
+		WithArgs(id, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "installment_number", "amount", "late_fee", "status"}).
+			AddRow(uuid.New().String(), id.String(), 2, 112.0, 5.6, "overdue"))
+	mock.ExpectExec("UPDATE `transaction_details` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `transaction_details`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+
+	//Covering only the installment amount keeps it overdue until the fee is paid
+	installment, status, err := repo.PayInstallment(context.Background(), id, 2, 112, false)
+	if err != nil {
+		t.Fatalf("PayInstallment returned error: %v", err)
+	}
+	if installment.Status != entity.TransactionDetailStatusOverdue || installment.RemainingAmount() != 5.6 {
+		t.Errorf("installment status = %s, remaining = %v, want overdue with 5.6 owed", installment.Status, installment.RemainingAmount())
+	}
+	if status != entity.TransactionStatusActive {
+		t.Errorf("transaction status = %s, want active", status)
+	}
+}
+
+func TestTransactionRepositoryCreateTranslatesDuplicateContract(t *testing.T) {
+	repo, mock := newTestTransactionRepository(t)
+	transaction := &entity.Transaction{
+		ID:             uuid.New(),
+		CustomerID:     uuid.New(),
+		AssetID:        uuid.New(),
+		ContractNumber: "KP-TEST-0001",
+		OTRAmount:      1000,
+		TenorMonth:     3,
+		Status:         entity.TransactionStatusPending,
+		CreatedAt:      testNow,
+		UpdatedAt:      testNow,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `credit_limits` WHERE .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "tenor_month", "limit_amount", "used_amount"}).
+			AddRow(uuid.New().String(), transaction.CustomerID.String(), 3, 5000.0, 0.0))
+	mock.ExpectExec("INSERT INTO `transactions`").
+		WillReturnError(&mysqlDriver.MySQLError{Number: 1062, Message: "Duplicate entry 'KP-TEST-0001' for key 'contract_number'"})
+	mock.ExpectRollback()
+
+	err := repo.Create(context.Background(), transaction, []float64{333.33, 333.33, 333.34})
+	if err != entity.ErrDuplicateContract {
+		t.Fatalf("Create returned %v, want ErrDuplicateContract", err)
+	}
+}
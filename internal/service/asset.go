@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
 	"strings"
 	"time"
@@ -12,33 +13,39 @@ import (
 
 type assetService struct {
 	repo   entity.AssetRepository
+	clock  entity.Clock
 	logger *zap.Logger
 }
 
-func NewAssetService(repo entity.AssetRepository, logger *zap.Logger) entity.AssetService {
+func NewAssetService(repo entity.AssetRepository, clock entity.Clock, logger *zap.Logger) entity.AssetService {
 	return &assetService{
 		repo:   repo,
+		clock:  clock,
 		logger: logger,
 	}
 }
 
 func (s *assetService) Create(ctx context.Context, req entity.CreateAssetRequest) (*entity.AssetResponse, error) {
+	//XSS Protection
+	req.Sanitize()
 	if errors := req.Validate(); len(errors) > 0 {
 		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
+	now := s.clock.Now()
 	asset := &entity.Asset{
 		ID:          uuid.New(),
 		Name:        req.Name,
 		Category:    req.Category,
 		Description: req.Description,
 		Price:       req.Price,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Stock:       req.Stock,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
 	if err := s.repo.Create(ctx, asset); err != nil {
-		s.logger.Error("failed to create asset", zap.Error(err))
+		loggerPkg.FromContext(ctx).Error("failed to create asset", zap.Error(err))
 		return nil, err
 	}
 
@@ -48,21 +55,44 @@ func (s *assetService) Create(ctx context.Context, req entity.CreateAssetRequest
 func (s *assetService) GetByID(ctx context.Context, id uuid.UUID) (*entity.AssetResponse, error) {
 	asset, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get asset", zap.Error(err))
+		loggerPkg.FromContext(ctx).Error("failed to get asset", zap.Error(err))
 		return nil, err
 	}
 
 	if asset == nil {
-		return nil, fmt.Errorf("asset not found")
+		return nil, entity.ErrAssetNotFound
 	}
 
 	return s.toResponse(asset), nil
 }
 
+func (s *assetService) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]entity.AssetResponse, error) {
+	if len(ids) == 0 || len(ids) > entity.MaxAssetBatchSize {
+		return nil, fmt.Errorf("validation failed: ids must contain between 1 and %d items", entity.MaxAssetBatchSize)
+	}
+
+	assets, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get assets by ids", zap.Error(err))
+		return nil, err
+	}
+
+	responses := make(map[uuid.UUID]entity.AssetResponse, len(assets))
+	for id, asset := range assets {
+		responses[id] = *s.toResponse(asset)
+	}
+
+	return responses, nil
+}
+
 func (s *assetService) GetAll(ctx context.Context, filter entity.AssetFilterRequest) ([]entity.AssetResponse, int64, error) {
+	if errors := filter.Validate(); len(errors) > 0 {
+		return nil, 0, fmt.Errorf("validation failed: %v", errors)
+	}
+
 	assets, count, err := s.repo.GetAllWithFilter(ctx, filter.ToAssetFilterRepo())
 	if err != nil {
-		s.logger.Error("failed to get assets", zap.Error(err))
+		loggerPkg.FromContext(ctx).Error("failed to get assets", zap.Error(err))
 		return nil, 0, err
 	}
 
@@ -75,27 +105,39 @@ func (s *assetService) GetAll(ctx context.Context, filter entity.AssetFilterRequ
 }
 
 func (s *assetService) Update(ctx context.Context, id uuid.UUID, req entity.UpdateAssetRequest) (*entity.AssetResponse, error) {
+	//XSS Protection
+	req.Sanitize()
 	if errors := req.Validate(); len(errors) > 0 {
-		return nil, fmt.Errorf("validation failed: %v", errors)
+		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
 	asset, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get asset for update", zap.Error(err))
+		loggerPkg.FromContext(ctx).Error("failed to get asset for update", zap.Error(err))
 		return nil, err
 	}
 
-	if asset == nil {
-		return nil, fmt.Errorf("asset not found")
+	if asset == nil || asset.Retired() {
+		return nil, entity.ErrAssetNotFound
+	}
+
+	if req.Version != nil && *req.Version != asset.Version {
+		return nil, entity.ErrStaleWrite
 	}
 
 	asset.Name = req.Name
+	if req.Category != "" {
+		asset.Category = req.Category
+	}
 	asset.Description = req.Description
 	asset.Price = req.Price
-	asset.UpdatedAt = time.Now().UTC()
+	if req.Stock != nil {
+		asset.Stock = *req.Stock
+	}
+	asset.UpdatedAt = s.clock.Now()
 
-	if err := s.repo.Update(ctx, asset); err != nil {
-		s.logger.Error("failed to update asset", zap.Error(err))
+	if err := s.repo.Update(ctx, asset, req.Stock != nil); err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to update asset", zap.Error(err))
 		return nil, err
 	}
 
@@ -104,20 +146,64 @@ func (s *assetService) Update(ctx context.Context, id uuid.UUID, req entity.Upda
 
 func (s *assetService) Delete(ctx context.Context, id uuid.UUID) error {
 	if err := s.repo.Delete(ctx, id); err != nil {
-		s.logger.Error("failed to delete asset", zap.Error(err))
+		loggerPkg.FromContext(ctx).Error("failed to delete asset", zap.Error(err))
 		return err
 	}
 	return nil
 }
 
 func (s *assetService) toResponse(asset *entity.Asset) *entity.AssetResponse {
-	return &entity.AssetResponse{
+	response := &entity.AssetResponse{
 		ID:          asset.ID,
 		Name:        asset.Name,
 		Category:    asset.Category,
 		Description: asset.Description,
 		Price:       asset.Price,
+		Stock:       asset.Stock,
+		Version:     asset.Version,
 		CreatedAt:   asset.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:   asset.UpdatedAt.Format(time.RFC3339),
 	}
+	if asset.Retired() {
+		response.DeletedAt = asset.DeletedAt.Time.Format(time.RFC3339)
+	}
+
+	return response
+}
+
+func (s *assetService) GetPriceHistory(ctx context.Context, id uuid.UUID, page, perPage int) ([]entity.AssetPriceHistoryResponse, int64, error) {
+	asset, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get asset for price history",
+			zap.Error(err),
+			zap.String("asset_id", id.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get asset: %w", err)
+	}
+
+	if asset == nil {
+		return nil, 0, entity.ErrAssetNotFound
+	}
+
+	entries, count, err := s.repo.GetPriceHistory(ctx, id, perPage, (page-1)*perPage)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get asset price history",
+			zap.Error(err),
+			zap.String("asset_id", id.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get asset price history: %w", err)
+	}
+
+	responses := make([]entity.AssetPriceHistoryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = entity.AssetPriceHistoryResponse{
+			ID:        entry.ID,
+			AssetID:   entry.AssetID,
+			OldPrice:  entry.OldPrice,
+			NewPrice:  entry.NewPrice,
+			ChangedAt: entry.ChangedAt.Format(time.RFC3339),
+		}
+	}
+
+	return responses, count, nil
 }
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestAssetGetByIDSeparatesNotFoundFromFailures(t *testing.T) {
+	stored := &entity.Asset{ID: uuid.New(), Name: "Kulkas", Price: 3000000}
+	errDatabaseDown := errors.New("dial tcp: connection refused")
+
+	for _, tc := range []struct {
+		name    string
+		id      uuid.UUID
+		repoErr error
+		wantErr error
+	}{
+		{"found", stored.ID, nil, nil},
+		{"missing", uuid.New(), nil, entity.ErrAssetNotFound},
+		{"database down", stored.ID, errDatabaseDown, errDatabaseDown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newFakeAssetRepository(stored)
+			repo.err = tc.repoErr
+			service := NewAssetService(repo, fixedClock{now: testNow}, zap.NewNop())
+
+			asset, err := service.GetByID(context.Background(), tc.id)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("GetByID returned %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && asset.ID != stored.ID {
+				t.Errorf("GetByID returned asset %v, want %v", asset.ID, stored.ID)
+			}
+			if tc.repoErr != nil && errors.Is(err, entity.ErrAssetNotFound) {
+				t.Error("a database failure was reported as not found")
+			}
+		})
+	}
+}
+
+const (
+	scriptName    = `<script>alert("x")</script>`
+	escapedScript = `&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;`
+)
+
+func TestAssetCreateStoresEscapedText(t *testing.T) {
+	repo := newFakeAssetRepository()
+	service := NewAssetService(repo, fixedClock{now: testNow}, zap.NewNop())
+
+	created, err := service.Create(context.Background(), entity.CreateAssetRequest{
+		Name:        scriptName,
+		Category:    "white_goods",
+		Description: "<b>bold</b>",
+		Price:       3000000,
+		Stock:       5,
+	})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	stored := repo.stored(created.ID)
+	if stored.Name != escapedScript {
+		t.Errorf("stored name %q, want %q", stored.Name, escapedScript)
+	}
+	if stored.Description != "&lt;b&gt;bold&lt;/b&gt;" {
+		t.Errorf("stored description %q was not escaped", stored.Description)
+	}
+	if created.Name != stored.Name {
+		t.Errorf("response name %q differs from stored %q", created.Name, stored.Name)
+	}
+}
+
+func TestAssetUpdateStoresEscapedText(t *testing.T) {
+	asset := &entity.Asset{ID: uuid.New(), Name: "Kulkas", Category: "white_goods", Description: "Dua pintu", Price: 3000000}
+	repo := newFakeAssetRepository(asset)
+	service := NewAssetService(repo, fixedClock{now: testNow}, zap.NewNop())
+
+	if _, err := service.Update(context.Background(), asset.ID, entity.UpdateAssetRequest{
+		Name:        scriptName,
+		Description: "Dua pintu",
+		Price:       3000000,
+	}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	if stored := repo.stored(asset.ID); stored.Name != escapedScript {
+		t.Errorf("stored name %q, want %q", stored.Name, escapedScript)
+	}
+}
+
+func TestAssetUpdateJoinsValidationErrors(t *testing.T) {
+	asset := &entity.Asset{ID: uuid.New(), Name: "Kulkas", Price: 3000000}
+	repo := newFakeAssetRepository(asset)
+	service := NewAssetService(repo, fixedClock{now: testNow}, zap.NewNop())
+
+	_, err := service.Update(context.Background(), asset.ID, entity.UpdateAssetRequest{Name: "TV", Description: "Layar datar"})
+	if err == nil {
+		t.Fatal("Update accepted an invalid request")
+	}
+	want := "validation failed: name min||price must be greater than 0"
+	if err.Error() != want {
+		t.Errorf("Update error %q, want %q", err.Error(), want)
+	}
+	if stored := repo.stored(asset.ID); stored.Name != "Kulkas" {
+		t.Errorf("invalid update was stored as %q", stored.Name)
+	}
+}
+
+// withLocalZone runs the test with the process time zone set to UTC+7, so
+// anything relying on time.Now() without UTC shows up as a non-UTC location.
+func withLocalZone(t *testing.T) {
+	t.Helper()
+
+	local := time.Local
+	time.Local = time.FixedZone("WIB", 7*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestAssetTimestampsAreStoredInUTC(t *testing.T) {
+	withLocalZone(t)
+	repo := newFakeAssetRepository()
+	service := NewAssetService(repo, NewSystemClock(), zap.NewNop())
+
+	created, err := service.Create(context.Background(), entity.CreateAssetRequest{
+		Name:        "Kulkas",
+		Category:    "white_goods",
+		Description: "Dua pintu",
+		Price:       3000000,
+	})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	stored := repo.stored(created.ID)
+	if stored.CreatedAt.Location() != time.UTC || stored.UpdatedAt.Location() != time.UTC {
+		t.Errorf("Create stored %v and %v, want UTC", stored.CreatedAt, stored.UpdatedAt)
+	}
+
+	if _, err := service.Update(context.Background(), created.ID, entity.UpdateAssetRequest{
+		Name:        "Kulkas",
+		Description: "Tiga pintu",
+		Price:       3500000,
+	}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if updated := repo.stored(created.ID); updated.UpdatedAt.Location() != time.UTC {
+		t.Errorf("Update stored %v, want UTC", updated.UpdatedAt)
+	}
+}
+
+func TestAssetTimestampsComeFromClock(t *testing.T) {
+	repo := newFakeAssetRepository()
+	service := NewAssetService(repo, fixedClock{now: testNow}, zap.NewNop())
+
+	created, err := service.Create(context.Background(), entity.CreateAssetRequest{
+		Name:        "Kulkas",
+		Category:    "white_goods",
+		Description: "Dua pintu",
+		Price:       3000000,
+	})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	stored := repo.stored(created.ID)
+	if !stored.CreatedAt.Equal(testNow) || !stored.UpdatedAt.Equal(testNow) {
+		t.Errorf("stored %v and %v, want %v", stored.CreatedAt, stored.UpdatedAt, testNow)
+	}
+	if created.CreatedAt != "2024-03-15T09:30:00Z" {
+		t.Errorf("response created_at %q, want RFC3339 in UTC", created.CreatedAt)
+	}
+}
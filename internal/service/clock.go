@@ -0,0 +1,17 @@
+package service
+
+import (
+	"kredit-plus/internal/entity"
+	"time"
+)
+
+type systemClock struct{}
+
+// NewSystemClock returns the wall clock, reporting time in UTC.
+func NewSystemClock() entity.Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,14 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSystemClockReportsUTC(t *testing.T) {
+	withLocalZone(t)
+
+	if location := NewSystemClock().Now().Location(); location != time.UTC {
+		t.Errorf("Now() is in %v, want UTC", location)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
 	"strings"
 	"time"
@@ -12,12 +13,14 @@ import (
 
 type creditLimitService struct {
 	repo   entity.CreditLimitRepository
+	clock  entity.Clock
 	logger *zap.Logger
 }
 
-func NewCreditLimitService(repo entity.CreditLimitRepository, logger *zap.Logger) entity.CreditLimitService {
+func NewCreditLimitService(repo entity.CreditLimitRepository, clock entity.Clock, logger *zap.Logger) entity.CreditLimitService {
 	return &creditLimitService{
 		repo:   repo,
+		clock:  clock,
 		logger: logger,
 	}
 }
@@ -29,7 +32,7 @@ func (s *creditLimitService) Create(ctx context.Context, req entity.CreateCredit
 
 	existingLimit, err := s.repo.GetByCustomerIDAndTenor(ctx, req.CustomerID, req.TenorMonth)
 	if err != nil {
-		s.logger.Error("failed to check existing credit limit",
+		loggerPkg.FromContext(ctx).Error("failed to check existing credit limit",
 			zap.Error(err),
 			zap.String("customer_id", req.CustomerID.String()),
 			zap.Int("tenor_month", req.TenorMonth),
@@ -47,12 +50,15 @@ func (s *creditLimitService) Create(ctx context.Context, req entity.CreateCredit
 		TenorMonth:  req.TenorMonth,
 		LimitAmount: req.LimitAmount,
 		UsedAmount:  0,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+		CreatedAt:   s.clock.Now(),
+		UpdatedAt:   s.clock.Now(),
 	}
 
 	if err := s.repo.Create(ctx, limit); err != nil {
-		s.logger.Error("failed to create credit limit",
+		if err == entity.ErrDuplicateCreditLimit {
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to create credit limit",
 			zap.Error(err),
 			zap.String("customer_id", req.CustomerID.String()),
 			zap.Int("tenor_month", req.TenorMonth),
@@ -63,10 +69,38 @@ func (s *creditLimitService) Create(ctx context.Context, req entity.CreateCredit
 	return s.toResponse(limit), nil
 }
 
+// CreateIfAbsent creates the limit, or returns the customer's existing limit for
+// the tenor unchanged when there already is one. created reports which happened.
+func (s *creditLimitService) CreateIfAbsent(ctx context.Context, req entity.CreateCreditLimitRequest) (*entity.CreditLimitResponse, bool, error) {
+	limit, err := s.Create(ctx, req)
+	if err == nil {
+		return limit, true, nil
+	}
+	if err != entity.ErrDuplicateCreditLimit {
+		return nil, false, err
+	}
+
+	//Either found by the pre-check or created concurrently and rejected by the unique index
+	existing, err := s.repo.GetByCustomerIDAndTenor(ctx, req.CustomerID, req.TenorMonth)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get existing credit limit",
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID.String()),
+			zap.Int("tenor_month", req.TenorMonth),
+		)
+		return nil, false, fmt.Errorf("failed to get existing credit limit: %w", err)
+	}
+	if existing == nil {
+		return nil, false, entity.ErrCreditLimitNotFound
+	}
+
+	return s.toResponse(existing), false, nil
+}
+
 func (s *creditLimitService) GetByID(ctx context.Context, id uuid.UUID) (*entity.CreditLimitResponse, error) {
 	limit, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get credit limit by ID",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit by ID",
 			zap.Error(err),
 			zap.String("credit_limit_id", id.String()),
 		)
@@ -81,18 +115,13 @@ func (s *creditLimitService) GetByID(ctx context.Context, id uuid.UUID) (*entity
 }
 
 func (s *creditLimitService) GetByCustomerIDAndTenor(ctx context.Context, customerID uuid.UUID, tenorMonth int) (*entity.CreditLimitResponse, error) {
-	isValidTenor := func(tenor int) bool {
-		validTenors := map[int]bool{1: true, 2: true, 3: true, 6: true}
-		return validTenors[tenor]
-	}
-
-	if !isValidTenor(tenorMonth) {
-		return nil, fmt.Errorf("invalid tenor month: must be 1, 2, 3, or 6")
+	if !entity.IsAllowedTenor(tenorMonth) {
+		return nil, fmt.Errorf("invalid tenor month: %s", entity.InvalidTenorMessage())
 	}
 
 	limit, err := s.repo.GetByCustomerIDAndTenor(ctx, customerID, tenorMonth)
 	if err != nil {
-		s.logger.Error("failed to get credit limit by customer ID and tenor",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit by customer ID and tenor",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 			zap.Int("tenor_month", tenorMonth),
@@ -107,14 +136,48 @@ func (s *creditLimitService) GetByCustomerIDAndTenor(ctx context.Context, custom
 	return s.toResponse(limit), nil
 }
 
-func (s *creditLimitService) GetAllByCustomerID(ctx context.Context, customerID uuid.UUID) ([]entity.CreditLimitResponse, error) {
+// GetAllByCustomerID pages through the customer's credit limits. A customer has
+// at most one limit per tenor, so the page is cut from the cached full list.
+func (s *creditLimitService) GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, page, perPage int) ([]entity.CreditLimitResponse, int64, error) {
 	limits, err := s.repo.GetAllByCustomerID(ctx, customerID)
 	if err != nil {
-		s.logger.Error("failed to get credit limits by customer ID",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limits by customer ID",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
-		return nil, fmt.Errorf("failed to get credit limits: %w", err)
+		return nil, 0, fmt.Errorf("failed to get credit limits: %w", err)
+	}
+
+	total := int64(len(limits))
+	start := (page - 1) * perPage
+	if start > len(limits) {
+		start = len(limits)
+	}
+	end := start + perPage
+	if end > len(limits) {
+		end = len(limits)
+	}
+
+	responses := make([]entity.CreditLimitResponse, 0, end-start)
+	for _, limit := range limits[start:end] {
+		responses = append(responses, *s.toResponse(&limit))
+	}
+
+	return responses, total, nil
+}
+
+func (s *creditLimitService) GetAll(ctx context.Context, filter entity.CreditLimitFilterRequest) ([]entity.CreditLimitResponse, int64, error) {
+	if errors := filter.Validate(); len(errors) > 0 {
+		return nil, 0, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	limits, count, err := s.repo.GetAll(ctx, filter.ToCreditLimitFilterRepo())
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to list credit limits",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return nil, 0, fmt.Errorf("failed to list credit limits: %w", err)
 	}
 
 	responses := make([]entity.CreditLimitResponse, len(limits))
@@ -122,13 +185,13 @@ func (s *creditLimitService) GetAllByCustomerID(ctx context.Context, customerID
 		responses[i] = *s.toResponse(&limit)
 	}
 
-	return responses, nil
+	return responses, count, nil
 }
 
 func (s *creditLimitService) Delete(ctx context.Context, id uuid.UUID) error {
 	limit, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get credit limit for deletion",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit for deletion",
 			zap.Error(err),
 			zap.String("credit_limit_id", id.String()),
 		)
@@ -144,7 +207,7 @@ func (s *creditLimitService) Delete(ctx context.Context, id uuid.UUID) error {
 	}
 
 	if err := s.repo.Delete(ctx, id); err != nil {
-		s.logger.Error("failed to delete credit limit",
+		loggerPkg.FromContext(ctx).Error("failed to delete credit limit",
 			zap.Error(err),
 			zap.String("credit_limit_id", id.String()),
 		)
@@ -154,14 +217,14 @@ func (s *creditLimitService) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (s *creditLimitService) UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64) error {
+func (s *creditLimitService) UpdateUsedAmount(ctx context.Context, id uuid.UUID, amount float64, reason string) error {
 	if amount == 0 {
 		return nil
 	}
 
 	limit, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get credit limit for updating used amount",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit for updating used amount",
 			zap.Error(err),
 			zap.String("credit_limit_id", id.String()),
 		)
@@ -184,8 +247,12 @@ func (s *creditLimitService) UpdateUsedAmount(ctx context.Context, id uuid.UUID,
 		}
 	}
 
-	if err := s.repo.UpdateUsedAmount(ctx, id, amount); err != nil {
-		s.logger.Error("failed to update credit limit used amount",
+	if reason == "" {
+		reason = entity.LedgerReasonManualAdjustment
+	}
+
+	if err := s.repo.UpdateUsedAmount(ctx, id, amount, reason); err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to update credit limit used amount",
 			zap.Error(err),
 			zap.String("credit_limit_id", id.String()),
 			zap.Float64("amount", amount),
@@ -196,14 +263,100 @@ func (s *creditLimitService) UpdateUsedAmount(ctx context.Context, id uuid.UUID,
 	return nil
 }
 
+func (s *creditLimitService) UpdateLimitAmount(ctx context.Context, id uuid.UUID, newLimit float64) (*entity.CreditLimitResponse, error) {
+	if newLimit <= 0 {
+		return nil, entity.ErrInvalidLimitAmount
+	}
+
+	limit, err := s.repo.UpdateLimitAmount(ctx, id, newLimit)
+	if err != nil {
+		switch err {
+		case entity.ErrCreditLimitNotFound, entity.ErrLimitBelowUsedAmount:
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to update credit limit amount",
+			zap.Error(err),
+			zap.String("credit_limit_id", id.String()),
+			zap.Float64("limit_amount", newLimit),
+		)
+		return nil, fmt.Errorf("failed to update credit limit amount: %w", err)
+	}
+
+	return s.toResponse(limit), nil
+}
+
+func (s *creditLimitService) GetLedger(ctx context.Context, id uuid.UUID, page, perPage int) ([]entity.CreditLimitLedgerResponse, int64, error) {
+	limit, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit for ledger",
+			zap.Error(err),
+			zap.String("credit_limit_id", id.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get credit limit: %w", err)
+	}
+
+	if limit == nil {
+		return nil, 0, entity.ErrCreditLimitNotFound
+	}
+
+	entries, count, err := s.repo.GetLedger(ctx, id, perPage, (page-1)*perPage)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit ledger",
+			zap.Error(err),
+			zap.String("credit_limit_id", id.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get credit limit ledger: %w", err)
+	}
+
+	responses := make([]entity.CreditLimitLedgerResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = entity.CreditLimitLedgerResponse{
+			ID:            entry.ID,
+			CreditLimitID: entry.CreditLimitID,
+			Delta:         entry.Delta,
+			Reason:        entry.Reason,
+			BalanceAfter:  entry.BalanceAfter,
+			CreatedAt:     entry.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	return responses, count, nil
+}
+
+func (s *creditLimitService) RecalculateUsedAmount(ctx context.Context, id uuid.UUID) (*entity.CreditLimitRecalculation, error) {
+	recalculation, err := s.repo.RecalculateUsedAmount(ctx, id)
+	if err != nil {
+		if err == entity.ErrCreditLimitNotFound {
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to recalculate credit limit used amount",
+			zap.Error(err),
+			zap.String("credit_limit_id", id.String()),
+		)
+		return nil, fmt.Errorf("failed to recalculate used amount: %w", err)
+	}
+
+	if recalculation.Difference != 0 {
+		loggerPkg.FromContext(ctx).Warn("credit limit used amount drift corrected",
+			zap.String("credit_limit_id", id.String()),
+			zap.Float64("previous_used_amount", recalculation.PreviousUsedAmount),
+			zap.Float64("used_amount", recalculation.UsedAmount),
+		)
+	}
+
+	return recalculation, nil
+}
+
 func (s *creditLimitService) toResponse(limit *entity.CreditLimit) *entity.CreditLimitResponse {
 	return &entity.CreditLimitResponse{
-		ID:          limit.ID,
-		CustomerID:  limit.CustomerID,
-		TenorMonth:  limit.TenorMonth,
-		LimitAmount: limit.LimitAmount,
-		UsedAmount:  limit.UsedAmount,
-		CreatedAt:   limit.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   limit.UpdatedAt.Format(time.RFC3339),
+		ID:                 limit.ID,
+		CustomerID:         limit.CustomerID,
+		TenorMonth:         limit.TenorMonth,
+		LimitAmount:        limit.LimitAmount,
+		UsedAmount:         limit.UsedAmount,
+		AvailableAmount:    limit.AvailableAmount(),
+		UtilizationPercent: limit.UtilizationPercent(),
+		CreatedAt:          limit.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          limit.UpdatedAt.Format(time.RFC3339),
 	}
 }
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"testing"
+)
+
+func TestCreditLimitResponseAvailableAmount(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		limitAmount     float64
+		usedAmount      float64
+		wantAvailable   float64
+		wantUtilization float64
+	}{
+		{"unused", 1000, 0, 1000, 0},
+		{"partly used", 1000, 250, 750, 25},
+		{"fully used", 1000, 1000, 0, 100},
+		{"rounded utilization", 3000, 1000, 2000, 33.33},
+		{"used beyond limit", 1000, 1200, 0, 120},
+		{"zero limit unused", 0, 0, 0, 0},
+		{"zero limit used", 0, 500, 0, 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			response := (&creditLimitService{}).toResponse(&entity.CreditLimit{
+				LimitAmount: tc.limitAmount,
+				UsedAmount:  tc.usedAmount,
+			})
+
+			if response.AvailableAmount != tc.wantAvailable {
+				t.Errorf("AvailableAmount = %v, want %v", response.AvailableAmount, tc.wantAvailable)
+			}
+			if response.UtilizationPercent != tc.wantUtilization {
+				t.Errorf("UtilizationPercent = %v, want %v", response.UtilizationPercent, tc.wantUtilization)
+			}
+		})
+	}
+}
+
+func TestCreditLimitCreateConcurrentDuplicateSucceedsOnce(t *testing.T) {
+	const callers = 5
+	repo := newFakeCreditLimitRepository()
+	repo.tenorChecks = newBarrier(callers)
+	service := NewCreditLimitService(repo, fixedClock{now: testNow}, zap.NewNop())
+	req := entity.CreateCreditLimitRequest{CustomerID: uuid.New(), TenorMonth: 6, LimitAmount: 5000000}
+
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := service.Create(context.Background(), req)
+			errs <- err
+		}()
+	}
+
+	var succeeded, duplicates int
+	for i := 0; i < callers; i++ {
+		switch err := <-errs; {
+		case err == nil:
+			succeeded++
+		case err == entity.ErrDuplicateCreditLimit:
+			duplicates++
+		default:
+			t.Errorf("Create returned unexpected error: %v", err)
+		}
+	}
+
+	if succeeded != 1 || duplicates != callers-1 {
+		t.Errorf("got %d successes and %d duplicates, want 1 and %d", succeeded, duplicates, callers-1)
+	}
+}
+
+func TestCreditLimitCreateIfAbsentConcurrentReturnsOneLimit(t *testing.T) {
+	const callers = 5
+	repo := newFakeCreditLimitRepository()
+	repo.tenorChecks = newBarrier(callers)
+	service := NewCreditLimitService(repo, fixedClock{now: testNow}, zap.NewNop())
+	req := entity.CreateCreditLimitRequest{CustomerID: uuid.New(), TenorMonth: 6, LimitAmount: 5000000}
+
+	type result struct {
+		limit   *entity.CreditLimitResponse
+		created bool
+		err     error
+	}
+	results := make(chan result, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			limit, created, err := service.CreateIfAbsent(context.Background(), req)
+			results <- result{limit, created, err}
+		}()
+	}
+
+	created := 0
+	ids := make(map[uuid.UUID]bool)
+	for i := 0; i < callers; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("CreateIfAbsent returned error: %v", r.err)
+		}
+		if r.created {
+			created++
+		}
+		ids[r.limit.ID] = true
+	}
+
+	if created != 1 {
+		t.Errorf("%d callers created the limit, want 1", created)
+	}
+	if len(ids) != 1 {
+		t.Errorf("callers got %d different limits, want 1", len(ids))
+	}
+}
@@ -2,68 +2,218 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"io"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type customerService struct {
-	repo   entity.CustomerRepository
-	logger *zap.Logger
+	repo            entity.CustomerRepository
+	creditLimitRepo entity.CreditLimitRepository
+	creditPolicy    entity.CreditPolicy
+	clock           entity.Clock
+	logger          *zap.Logger
 }
 
-func NewCustomerService(repo entity.CustomerRepository, logger *zap.Logger) entity.CustomerService {
+func NewCustomerService(
+	repo entity.CustomerRepository,
+	creditLimitRepo entity.CreditLimitRepository,
+	creditPolicy entity.CreditPolicy,
+	clock entity.Clock,
+	logger *zap.Logger,
+) entity.CustomerService {
 	return &customerService{
-		repo:   repo,
-		logger: logger,
+		repo:            repo,
+		creditLimitRepo: creditLimitRepo,
+		creditPolicy:    creditPolicy,
+		clock:           clock,
+		logger:          logger,
 	}
 }
 
 func (s *customerService) Create(ctx context.Context, req entity.CreateCustomerRequest) (*entity.CustomerResponse, error) {
-	if errors := req.Validate(); len(errors) > 0 {
+	if errors := req.Validate(s.creditPolicy.MinimumAge, s.clock.Now()); len(errors) > 0 {
 		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
-	existingCustomer, err := s.repo.GetByNIK(ctx, req.NIK)
+	existingCustomer, err := s.repo.GetByNIK(ctx, req.NIK, true)
 	if err != nil {
-		s.logger.Error("failed to check existing customer", zap.Error(err))
+		loggerPkg.FromContext(ctx).Error("failed to check existing customer", zap.Error(err))
 		return nil, fmt.Errorf("failed to check existing customer: %w", err)
 	}
 	if existingCustomer != nil {
-		return nil, fmt.Errorf("customer with NIK %s already exists", req.NIK)
+		return nil, entity.ErrDuplicateNIK
 	}
 
 	customer := &entity.Customer{
-		ID:         uuid.New(),
-		NIK:        req.NIK,
-		FullName:   req.FullName,
-		LegalName:  req.LegalName,
-		BirthPlace: req.BirthPlace,
-		BirthDate:  req.BirthDate,
-		Salary:     req.Salary,
-		IsActive:   true,
-		CreatedAt:  time.Now().UTC(),
-		UpdatedAt:  time.Now().UTC(),
+		ID:          uuid.New(),
+		NIK:         req.NIK,
+		FullName:    req.FullName,
+		LegalName:   req.LegalName,
+		BirthPlace:  req.BirthPlace,
+		BirthDate:   req.BirthDate,
+		Salary:      req.Salary,
+		Email:       req.Email,
+		PhoneNumber: req.PhoneNumber,
+		IsActive:    true,
+		CreatedAt:   s.clock.Now(),
+		UpdatedAt:   s.clock.Now(),
 	}
 
 	if err := s.repo.Create(ctx, customer); err != nil {
-		s.logger.Error("failed to create customer",
+		loggerPkg.FromContext(ctx).Error("failed to create customer",
 			zap.Error(err),
 			zap.String("nik", req.NIK),
 		)
 		return nil, fmt.Errorf("failed to create customer: %w", err)
 	}
 
+	if s.creditPolicy.AutoProvisionLimits {
+		s.provisionDefaultLimits(ctx, customer)
+	}
+
 	return s.toResponse(customer), nil
 }
 
-func (s *customerService) GetByID(ctx context.Context, id uuid.UUID) (*entity.CustomerResponse, error) {
-	customer, err := s.repo.GetByID(ctx, id)
+// BulkCreate imports each row independently through Create, so one invalid or
+// duplicate row does not block the rest of the batch.
+func (s *customerService) BulkCreate(ctx context.Context, reqs []entity.CreateCustomerRequest) (*entity.BulkCustomerImportResponse, error) {
+	if len(reqs) == 0 {
+		return nil, entity.ErrBulkImportEmpty
+	}
+	if len(reqs) > entity.MaxBulkCustomerImport {
+		return nil, entity.ErrBulkImportTooLarge
+	}
+
+	response := &entity.BulkCustomerImportResponse{
+		Total:   len(reqs),
+		Results: make([]entity.BulkCustomerResult, len(reqs)),
+	}
+	for i, req := range reqs {
+		result := entity.BulkCustomerResult{Index: i}
+
+		customer, err := s.Create(ctx, req)
+		if err != nil {
+			result.Error = err.Error()
+			var customerErr *entity.CustomerError
+			if errors.As(err, &customerErr) {
+				result.ErrorCode = customerErr.Code
+			}
+			response.Failed++
+		} else {
+			result.Success = true
+			result.Customer = customer
+			response.Succeeded++
+		}
+
+		response.Results[i] = result
+	}
+
+	loggerPkg.FromContext(ctx).Info("bulk customer import processed",
+		zap.Int("total", response.Total),
+		zap.Int("succeeded", response.Succeeded),
+		zap.Int("failed", response.Failed),
+	)
+
+	return response, nil
+}
+
+// Export writes the customers matching filter to w as CSV, one row at a time,
+// up to entity.MaxCustomerExportRows. It returns the number of rows written.
+func (s *customerService) Export(ctx context.Context, filter entity.CustomerFilterRequest, w io.Writer) (int, error) {
+	if errors := filter.ValidateExport(); len(errors) > 0 {
+		return 0, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	repoFilter := filter.ToCustomerFilterRepo()
+	repoFilter.Limit = entity.MaxCustomerExportRows
+	repoFilter.Offset = 0
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"nik", "full_name", "legal_name", "birth_date", "salary", "is_active"}); err != nil {
+		return 0, fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	count, err := s.repo.StreamList(ctx, repoFilter, func(customer *entity.Customer) error {
+		return writer.Write([]string{
+			customer.NIK,
+			csvSafe(customer.FullName),
+			csvSafe(customer.LegalName),
+			customer.BirthDate.Format("2006-01-02"),
+			strconv.FormatFloat(customer.Salary, 'f', 2, 64),
+			strconv.FormatBool(customer.IsActive),
+		})
+	})
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to export customers",
+			zap.Error(err),
+			zap.Int("rows_written", count),
+		)
+		return count, fmt.Errorf("failed to export customers: %w", err)
+	}
+
+	loggerPkg.FromContext(ctx).Info("customers exported",
+		zap.Int("rows", count),
+		zap.Any("filter", filter),
+	)
+
+	return count, nil
+}
+
+// provisionDefaultLimits seeds a credit limit per configured tenor. A failed insert
+// is logged and skipped so it never fails the customer creation itself.
+func (s *customerService) provisionDefaultLimits(ctx context.Context, customer *entity.Customer) {
+	tenors := make([]int, 0, len(s.creditPolicy.TenorMultipliers))
+	for tenor := range s.creditPolicy.TenorMultipliers {
+		tenors = append(tenors, tenor)
+	}
+	sort.Ints(tenors)
+
+	for _, tenor := range tenors {
+		limitAmount := s.creditPolicy.DefaultLimitAmount(customer.Salary, tenor)
+		if limitAmount <= 0 {
+			continue
+		}
+
+		limit := &entity.CreditLimit{
+			ID:          uuid.New(),
+			CustomerID:  customer.ID,
+			TenorMonth:  tenor,
+			LimitAmount: limitAmount,
+			CreatedAt:   customer.CreatedAt,
+			UpdatedAt:   customer.CreatedAt,
+		}
+
+		if err := s.creditLimitRepo.Create(ctx, limit); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to provision default credit limit",
+				zap.Error(err),
+				zap.String("customer_id", customer.ID.String()),
+				zap.Int("tenor_month", tenor),
+			)
+		}
+	}
+}
+
+// GetByID returns the customer, with transaction stats only when includeStats
+// is set since they take extra aggregate queries.
+func (s *customerService) GetByID(ctx context.Context, id uuid.UUID, includeStats bool) (*entity.CustomerResponse, error) {
+	customer, err := s.repo.GetByID(ctx, id, true)
 	if err != nil {
-		s.logger.Error("failed to get customer by ID",
+		loggerPkg.FromContext(ctx).Error("failed to get customer by ID",
 			zap.Error(err),
 			zap.String("customer_id", id.String()),
 		)
@@ -71,10 +221,23 @@ func (s *customerService) GetByID(ctx context.Context, id uuid.UUID) (*entity.Cu
 	}
 
 	if customer == nil {
-		return nil, fmt.Errorf("customer not found")
+		return nil, entity.ErrCustomerNotFound
+	}
+
+	response := s.toResponse(customer)
+	if includeStats {
+		stats, err := s.repo.GetTransactionStats(ctx, id)
+		if err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to get customer transaction stats",
+				zap.Error(err),
+				zap.String("customer_id", id.String()),
+			)
+			return nil, fmt.Errorf("failed to get customer stats: %w", err)
+		}
+		response.Stats = stats
 	}
 
-	return s.toResponse(customer), nil
+	return response, nil
 }
 
 func (s *customerService) GetByNIK(ctx context.Context, nik string) (*entity.CustomerResponse, error) {
@@ -82,9 +245,9 @@ func (s *customerService) GetByNIK(ctx context.Context, nik string) (*entity.Cus
 		return nil, fmt.Errorf("invalid NIK format")
 	}
 
-	customer, err := s.repo.GetByNIK(ctx, nik)
+	customer, err := s.repo.GetByNIK(ctx, nik, true)
 	if err != nil {
-		s.logger.Error("failed to get customer by NIK",
+		loggerPkg.FromContext(ctx).Error("failed to get customer by NIK",
 			zap.Error(err),
 			zap.String("nik", nik),
 		)
@@ -92,20 +255,20 @@ func (s *customerService) GetByNIK(ctx context.Context, nik string) (*entity.Cus
 	}
 
 	if customer == nil {
-		return nil, fmt.Errorf("customer not found")
+		return nil, entity.ErrCustomerNotFound
 	}
 
 	return s.toResponse(customer), nil
 }
 
 func (s *customerService) Update(ctx context.Context, id uuid.UUID, req entity.UpdateCustomerRequest) (*entity.CustomerResponse, error) {
-	if errors := req.Validate(); len(errors) > 0 {
+	if errors := req.Validate(s.creditPolicy.MinimumAge, s.clock.Now()); len(errors) > 0 {
 		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
-	customer, err := s.repo.GetByID(ctx, id)
+	customer, err := s.repo.GetByID(ctx, id, true)
 	if err != nil {
-		s.logger.Error("failed to get customer for update",
+		loggerPkg.FromContext(ctx).Error("failed to get customer for update",
 			zap.Error(err),
 			zap.String("customer_id", id.String()),
 		)
@@ -113,35 +276,121 @@ func (s *customerService) Update(ctx context.Context, id uuid.UUID, req entity.U
 	}
 
 	if customer == nil {
-		return nil, fmt.Errorf("customer not found")
+		return nil, entity.ErrCustomerNotFound
 	}
 
 	if !customer.IsActive {
-		return nil, fmt.Errorf("cannot update inactive customer")
+		return nil, entity.ErrCustomerInactive
 	}
 
+	if req.Version != nil && *req.Version != customer.Version {
+		return nil, entity.ErrStaleWrite
+	}
+
+	previousSalary := customer.Salary
 	customer.FullName = req.FullName
 	customer.LegalName = req.LegalName
 	customer.BirthPlace = req.BirthPlace
 	customer.BirthDate = req.BirthDate
 	customer.Salary = req.Salary
-	customer.UpdatedAt = time.Now().UTC()
+	if req.Email != "" {
+		customer.Email = req.Email
+	}
+	if req.PhoneNumber != "" {
+		customer.PhoneNumber = req.PhoneNumber
+	}
+	customer.UpdatedAt = s.clock.Now()
 
 	if err := s.repo.Update(ctx, customer); err != nil {
-		s.logger.Error("failed to update customer",
+		loggerPkg.FromContext(ctx).Error("failed to update customer",
 			zap.Error(err),
 			zap.String("customer_id", id.String()),
 		)
 		return nil, fmt.Errorf("failed to update customer: %w", err)
 	}
 
+	//A failed recalculation keeps the old limits and never fails the update itself
+	if s.creditPolicy.RecalculateOnSalary && customer.Salary != previousSalary {
+		if err := s.RecalculateLimitsFromSalary(ctx, customer.ID); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to recalculate credit limits after salary change",
+				zap.Error(err),
+				zap.String("customer_id", customer.ID.String()),
+			)
+		}
+	}
+
 	return s.toResponse(customer), nil
 }
 
+// RecalculateLimitsFromSalary re-derives every credit limit of the customer from
+// the current salary and the policy's tenor multipliers. A limit is never set
+// below its used amount, and tenors without a multiplier are left untouched.
+func (s *customerService) RecalculateLimitsFromSalary(ctx context.Context, customerID uuid.UUID) error {
+	customer, err := s.repo.GetByID(ctx, customerID, true)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get customer for limit recalculation",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	if customer == nil {
+		return entity.ErrCustomerNotFound
+	}
+
+	limits, err := s.creditLimitRepo.GetAllByCustomerID(ctx, customerID)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get credit limits for recalculation",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return fmt.Errorf("failed to get credit limits: %w", err)
+	}
+
+	for _, limit := range limits {
+		limitAmount := s.creditPolicy.DefaultLimitAmount(customer.Salary, limit.TenorMonth)
+		if limitAmount <= 0 {
+			continue
+		}
+		limitAmount = math.Max(limitAmount, limit.UsedAmount)
+		if limitAmount == limit.LimitAmount {
+			continue
+		}
+
+		if _, err := s.creditLimitRepo.UpdateLimitAmount(ctx, limit.ID, limitAmount); err != nil {
+			//Usage can grow between the read above and the locked update
+			if err == entity.ErrLimitBelowUsedAmount {
+				loggerPkg.FromContext(ctx).Warn("skipped credit limit recalculation below used amount",
+					zap.String("credit_limit_id", limit.ID.String()),
+					zap.Float64("limit_amount", limitAmount),
+				)
+				continue
+			}
+			loggerPkg.FromContext(ctx).Error("failed to recalculate credit limit",
+				zap.Error(err),
+				zap.String("credit_limit_id", limit.ID.String()),
+			)
+			return fmt.Errorf("failed to update credit limit: %w", err)
+		}
+
+		loggerPkg.FromContext(ctx).Info("credit limit recalculated from salary",
+			zap.String("customer_id", customerID.String()),
+			zap.String("credit_limit_id", limit.ID.String()),
+			zap.Int("tenor_month", limit.TenorMonth),
+			zap.Float64("old_limit_amount", limit.LimitAmount),
+			zap.Float64("new_limit_amount", limitAmount),
+			zap.Float64("used_amount", limit.UsedAmount),
+		)
+	}
+
+	return nil
+}
+
 func (s *customerService) Delete(ctx context.Context, id uuid.UUID) error {
-	customer, err := s.repo.GetByID(ctx, id)
+	customer, err := s.repo.GetByID(ctx, id, true)
 	if err != nil {
-		s.logger.Error("failed to get customer for deletion",
+		loggerPkg.FromContext(ctx).Error("failed to get customer for deletion",
 			zap.Error(err),
 			zap.String("customer_id", id.String()),
 		)
@@ -149,15 +398,15 @@ func (s *customerService) Delete(ctx context.Context, id uuid.UUID) error {
 	}
 
 	if customer == nil {
-		return fmt.Errorf("customer not found")
+		return entity.ErrCustomerNotFound
 	}
 
 	if !customer.IsActive {
-		return fmt.Errorf("customer is already inactive")
+		return entity.ErrCustomerInactive
 	}
 
 	if err := s.repo.Delete(ctx, id); err != nil {
-		s.logger.Error("failed to delete customer",
+		loggerPkg.FromContext(ctx).Error("failed to delete customer",
 			zap.Error(err),
 			zap.String("customer_id", id.String()),
 		)
@@ -167,14 +416,31 @@ func (s *customerService) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+func (s *customerService) Reactivate(ctx context.Context, id uuid.UUID) (*entity.CustomerResponse, error) {
+	customer, err := s.repo.Reactivate(ctx, id)
+	if err != nil {
+		switch err {
+		case entity.ErrCustomerNotFound, entity.ErrCustomerAlreadyActive:
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to reactivate customer",
+			zap.Error(err),
+			zap.String("customer_id", id.String()),
+		)
+		return nil, fmt.Errorf("failed to reactivate customer: %w", err)
+	}
+
+	return s.toResponse(customer), nil
+}
+
 func (s *customerService) UploadDocument(ctx context.Context, customerID uuid.UUID, req entity.UploadDocumentRequest) (*entity.CustomerDocumentResponse, error) {
 	if errors := req.Validate(); len(errors) > 0 {
 		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
-	customer, err := s.repo.GetByID(ctx, customerID)
+	customer, err := s.repo.GetByID(ctx, customerID, true)
 	if err != nil {
-		s.logger.Error("failed to get customer for document upload",
+		loggerPkg.FromContext(ctx).Error("failed to get customer for document upload",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
@@ -182,11 +448,11 @@ func (s *customerService) UploadDocument(ctx context.Context, customerID uuid.UU
 	}
 
 	if customer == nil {
-		return nil, fmt.Errorf("customer not found")
+		return nil, entity.ErrCustomerNotFound
 	}
 
 	if !customer.IsActive {
-		return nil, fmt.Errorf("cannot upload document for inactive customer")
+		return nil, entity.ErrCustomerInactive
 	}
 
 	filter := entity.DocumentFilterRepository{
@@ -198,7 +464,7 @@ func (s *customerService) UploadDocument(ctx context.Context, customerID uuid.UU
 
 	existingDocs, _, err := s.repo.GetDocuments(ctx, filter)
 	if err != nil {
-		s.logger.Error("failed to check existing documents",
+		loggerPkg.FromContext(ctx).Error("failed to check existing documents",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
@@ -206,7 +472,7 @@ func (s *customerService) UploadDocument(ctx context.Context, customerID uuid.UU
 	}
 
 	if len(existingDocs) > 0 {
-		return nil, fmt.Errorf("document type %s already exists for customer", req.DocumentType)
+		return nil, entity.ErrDuplicateDocument
 	}
 
 	doc := &entity.CustomerDocument{
@@ -214,12 +480,12 @@ func (s *customerService) UploadDocument(ctx context.Context, customerID uuid.UU
 		CustomerID:   customerID,
 		DocumentType: req.DocumentType,
 		DocumentURL:  req.DocumentURL,
-		CreatedAt:    time.Now().UTC(),
-		UpdatedAt:    time.Now().UTC(),
+		CreatedAt:    s.clock.Now(),
+		UpdatedAt:    s.clock.Now(),
 	}
 
 	if err := s.repo.CreateDocument(ctx, doc); err != nil {
-		s.logger.Error("failed to create customer document",
+		loggerPkg.FromContext(ctx).Error("failed to create customer document",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 			zap.String("document_type", string(req.DocumentType)),
@@ -230,14 +496,88 @@ func (s *customerService) UploadDocument(ctx context.Context, customerID uuid.UU
 	return s.toDocumentResponse(doc), nil
 }
 
+func (s *customerService) UpsertDocument(ctx context.Context, customerID uuid.UUID, req entity.UploadDocumentRequest) (*entity.CustomerDocumentResponse, bool, error) {
+	if errors := req.Validate(); len(errors) > 0 {
+		return nil, false, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	customer, err := s.repo.GetByID(ctx, customerID, true)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get customer for document upsert",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, false, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	if customer == nil {
+		return nil, false, entity.ErrCustomerNotFound
+	}
+
+	if !customer.IsActive {
+		return nil, false, entity.ErrCustomerInactive
+	}
+
+	now := s.clock.Now()
+	doc := &entity.CustomerDocument{
+		ID:           uuid.New(),
+		CustomerID:   customerID,
+		DocumentType: req.DocumentType,
+		DocumentURL:  req.DocumentURL,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	created, err := s.repo.UpsertDocument(ctx, doc)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to upsert customer document",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+			zap.String("document_type", string(req.DocumentType)),
+		)
+		return nil, false, fmt.Errorf("failed to save document: %w", err)
+	}
+
+	return s.toDocumentResponse(doc), created, nil
+}
+
+func (s *customerService) DeleteDocument(ctx context.Context, customerID, documentID uuid.UUID) error {
+	customer, err := s.repo.GetByID(ctx, customerID, true)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get customer for document deletion",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	if customer == nil {
+		return entity.ErrCustomerNotFound
+	}
+
+	if err := s.repo.DeleteDocument(ctx, customerID, documentID); err != nil {
+		if err == entity.ErrDocumentNotFound {
+			return err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to delete customer document",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+			zap.String("document_id", documentID.String()),
+		)
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	return nil
+}
+
 func (s *customerService) GetDocuments(ctx context.Context, customerID uuid.UUID, filter entity.DocumentFilterRequest) ([]entity.CustomerDocumentResponse, int64, error) {
 	if errors := filter.Validate(); len(errors) > 0 {
 		return nil, 0, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
-	customer, err := s.repo.GetByID(ctx, customerID)
+	customer, err := s.repo.GetByID(ctx, customerID, true)
 	if err != nil {
-		s.logger.Error("failed to get customer for documents",
+		loggerPkg.FromContext(ctx).Error("failed to get customer for documents",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
@@ -245,12 +585,12 @@ func (s *customerService) GetDocuments(ctx context.Context, customerID uuid.UUID
 	}
 
 	if customer == nil {
-		return nil, 0, fmt.Errorf("customer not found")
+		return nil, 0, entity.ErrCustomerNotFound
 	}
 
 	docs, count, err := s.repo.GetDocuments(ctx, filter.ToDocumentFilterRepo(customerID))
 	if err != nil {
-		s.logger.Error("failed to get customer documents",
+		loggerPkg.FromContext(ctx).Error("failed to get customer documents",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
@@ -265,18 +605,43 @@ func (s *customerService) GetDocuments(ctx context.Context, customerID uuid.UUID
 	return responses, count, nil
 }
 
+func (s *customerService) List(ctx context.Context, filter entity.CustomerFilterRequest) ([]entity.CustomerResponse, int64, error) {
+	if errors := filter.Validate(); len(errors) > 0 {
+		return nil, 0, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	customers, count, err := s.repo.List(ctx, filter.ToCustomerFilterRepo())
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to list customers",
+			zap.Error(err),
+			zap.Any("filter", filter),
+		)
+		return nil, 0, fmt.Errorf("failed to list customers: %w", err)
+	}
+
+	responses := make([]entity.CustomerResponse, len(customers))
+	for i, customer := range customers {
+		responses[i] = *s.toResponse(&customer)
+	}
+
+	return responses, count, nil
+}
+
 func (s *customerService) toResponse(customer *entity.Customer) *entity.CustomerResponse {
 	response := &entity.CustomerResponse{
-		ID:         customer.ID,
-		NIK:        customer.NIK,
-		FullName:   customer.FullName,
-		LegalName:  customer.LegalName,
-		BirthPlace: customer.BirthPlace,
-		BirthDate:  customer.BirthDate.Format("2006-01-02"),
-		Salary:     customer.Salary,
-		IsActive:   customer.IsActive,
-		CreatedAt:  customer.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:  customer.UpdatedAt.Format(time.RFC3339),
+		ID:          customer.ID,
+		NIK:         customer.NIK,
+		FullName:    customer.FullName,
+		LegalName:   customer.LegalName,
+		BirthPlace:  customer.BirthPlace,
+		BirthDate:   customer.BirthDate.Format("2006-01-02"),
+		Salary:      customer.Salary,
+		Email:       customer.Email,
+		PhoneNumber: customer.PhoneNumber,
+		IsActive:    customer.IsActive,
+		Version:     customer.Version,
+		CreatedAt:   customer.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   customer.UpdatedAt.Format(time.RFC3339),
 	}
 
 	if len(customer.Documents) > 0 {
@@ -298,4 +663,12 @@ func (s *customerService) toDocumentResponse(doc *entity.CustomerDocument) *enti
 		CreatedAt:    doc.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:    doc.UpdatedAt.Format(time.RFC3339),
 	}
-}
\ No newline at end of file
+}
+
+// csvSafe neutralizes values a spreadsheet would evaluate as a formula.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
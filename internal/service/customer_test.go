@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"testing"
+)
+
+func TestRecalculateLimitsFromSalaryNeverDropsBelowUsedAmount(t *testing.T) {
+	policy := entity.CreditPolicy{TenorMultipliers: map[int]float64{3: 0.5, 6: 1, 12: 2}}
+
+	for _, tc := range []struct {
+		name        string
+		tenorMonth  int
+		limitAmount float64
+		usedAmount  float64
+		wantLimit   float64
+		wantUpdated bool
+	}{
+		{"raised to the salary multiple", 6, 3000000, 0, 10000000, true},
+		{"lowered to the salary multiple", 6, 15000000, 2000000, 10000000, true},
+		{"floored at the used amount", 3, 8000000, 6000000, 6000000, true},
+		{"already at the floor", 12, 25000000, 25000000, 25000000, false},
+		{"tenor without a multiplier", 9, 4000000, 1000000, 4000000, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			customer := &entity.Customer{ID: uuid.New(), Salary: 10000000, IsActive: true}
+			limit := &entity.CreditLimit{
+				ID:          uuid.New(),
+				CustomerID:  customer.ID,
+				TenorMonth:  tc.tenorMonth,
+				LimitAmount: tc.limitAmount,
+				UsedAmount:  tc.usedAmount,
+			}
+			limits := newFakeCreditLimitRepository(limit)
+			service := NewCustomerService(newFakeCustomerRepository(customer), limits, policy, fixedClock{now: testNow}, zap.NewNop())
+
+			if err := service.RecalculateLimitsFromSalary(context.Background(), customer.ID); err != nil {
+				t.Fatalf("RecalculateLimitsFromSalary returned error: %v", err)
+			}
+
+			got := limits.limit(limit.ID)
+			if got.LimitAmount != tc.wantLimit {
+				t.Errorf("LimitAmount = %v, want %v", got.LimitAmount, tc.wantLimit)
+			}
+			if got.LimitAmount < got.UsedAmount {
+				t.Errorf("LimitAmount %v is below UsedAmount %v", got.LimitAmount, got.UsedAmount)
+			}
+			if updated := limits.updates > 0; updated != tc.wantUpdated {
+				t.Errorf("updated = %v, want %v", updated, tc.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestRecalculateLimitsFromSalarySkipsLimitWhoseUsageGrew(t *testing.T) {
+	policy := entity.CreditPolicy{TenorMultipliers: map[int]float64{3: 0.5, 6: 1}}
+	customer := &entity.Customer{ID: uuid.New(), Salary: 10000000, IsActive: true}
+	raced := &entity.CreditLimit{ID: uuid.New(), CustomerID: customer.ID, TenorMonth: 3, LimitAmount: 8000000, UsedAmount: 1000000}
+	other := &entity.CreditLimit{ID: uuid.New(), CustomerID: customer.ID, TenorMonth: 6, LimitAmount: 3000000}
+	limits := newFakeCreditLimitRepository(raced, other)
+	//A purchase lands after the read, taking usage past the new 5,000,000 limit
+	limits.beforeUpdate = func(limit *entity.CreditLimit) {
+		if limit.ID == raced.ID {
+			limit.UsedAmount = 7000000
+		}
+	}
+	service := NewCustomerService(newFakeCustomerRepository(customer), limits, policy, fixedClock{now: testNow}, zap.NewNop())
+
+	if err := service.RecalculateLimitsFromSalary(context.Background(), customer.ID); err != nil {
+		t.Fatalf("RecalculateLimitsFromSalary returned error: %v", err)
+	}
+
+	if got := limits.limit(raced.ID); got.LimitAmount != 8000000 {
+		t.Errorf("raced LimitAmount = %v, want it left at 8000000", got.LimitAmount)
+	}
+	if got := limits.limit(other.ID); got.LimitAmount != 10000000 {
+		t.Errorf("other LimitAmount = %v, want 10000000", got.LimitAmount)
+	}
+}
+
+func TestRecalculateLimitsFromSalaryUnknownCustomer(t *testing.T) {
+	service := NewCustomerService(newFakeCustomerRepository(), newFakeCreditLimitRepository(),
+		entity.CreditPolicy{}, fixedClock{now: testNow}, zap.NewNop())
+
+	if err := service.RecalculateLimitsFromSalary(context.Background(), uuid.New()); err != entity.ErrCustomerNotFound {
+		t.Errorf("RecalculateLimitsFromSalary returned %v, want ErrCustomerNotFound", err)
+	}
+}
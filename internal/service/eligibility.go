@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/internal/entity"
+)
+
+// eligibilityEvaluator runs the financing rules shared by transaction creation
+// and the financing endpoints, so every path approves the same requests.
+type eligibilityEvaluator struct {
+	transactionRepo entity.TransactionRepository
+	customerRepo    entity.CustomerRepository
+	creditPolicy    entity.CreditPolicy
+}
+
+// eligibilityInput is what the rules are evaluated against. Customer and Asset
+// must be loaded and present; CreditLimit is nil when the customer has no limit
+// for the tenor.
+type eligibilityInput struct {
+	Customer     *entity.Customer
+	Asset        *entity.Asset
+	CreditLimit  *entity.CreditLimit
+	TenorMonth   int
+	AdminFee     float64
+	InterestRate float64
+	InterestType entity.InterestType
+}
+
+type eligibility struct {
+	interestType   entity.InterestType
+	otrAmount      float64
+	adminFee       float64
+	interestAmount float64
+	totalAmount    float64
+	schedule       []float64
+	rules          []entity.EligibilityRuleResult
+	failures       []error
+}
+
+func newEligibilityEvaluator(
+	transactionRepo entity.TransactionRepository,
+	customerRepo entity.CustomerRepository,
+	creditPolicy entity.CreditPolicy,
+) *eligibilityEvaluator {
+	return &eligibilityEvaluator{
+		transactionRepo: transactionRepo,
+		customerRepo:    customerRepo,
+		creditPolicy:    creditPolicy,
+	}
+}
+
+// evaluate checks every rule, recording failures instead of stopping at the
+// first one. The returned error is only for failures to load rule data.
+func (e *eligibilityEvaluator) evaluate(ctx context.Context, in eligibilityInput) (*eligibility, error) {
+	result := &eligibility{interestType: in.InterestType}
+	if result.interestType == "" {
+		result.interestType = entity.InterestTypeFlat
+	}
+	result.otrAmount = in.Asset.Price
+	result.adminFee = e.creditPolicy.ResolveAdminFee(in.AdminFee, in.Asset.Category)
+	result.interestAmount, result.schedule = calculateInstallmentSchedule(result.otrAmount, result.adminFee, in.InterestRate, in.TenorMonth, result.interestType)
+	result.totalAmount = result.otrAmount + result.adminFee + result.interestAmount
+
+	customerID := in.Customer.ID
+
+	//Check Customer
+	result.check(entity.FinancingRuleCustomerActive, in.Customer.IsActive, entity.ErrCustomerInactive)
+
+	complete, err := e.customerRepo.DocumentsComplete(ctx, customerID, e.creditPolicy.RequiredDocumentTypes())
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to check customer documents",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, fmt.Errorf("failed to check customer documents: %w", err)
+	}
+	result.check(entity.FinancingRuleKYCComplete, complete, entity.ErrKYCIncomplete)
+
+	//Check Asset
+	result.check(entity.FinancingRuleAssetAvailable, in.Asset.Stock > 0, entity.ErrAssetOutOfStock)
+
+	maxRate := e.creditPolicy.MaxInterestRate(in.Asset.Category)
+	result.check(entity.FinancingRuleInterestRate, in.InterestRate <= maxRate,
+		entity.NewInterestRateExceedsCapError(in.Asset.Category, in.InterestRate, maxRate))
+
+	maxFee := e.creditPolicy.MaxAdminFee
+	result.check(entity.FinancingRuleAdminFee, maxFee <= 0 || result.adminFee <= maxFee,
+		entity.NewAdminFeeExceedsMaxError(result.adminFee, maxFee))
+
+	//Check Credit Limit
+	if in.CreditLimit == nil {
+		result.check(entity.FinancingRuleCreditLimit, false, entity.ErrCreditLimitNotFound)
+	} else {
+		available := in.CreditLimit.LimitAmount - in.CreditLimit.UsedAmount
+		result.check(entity.FinancingRuleCreditLimit, result.totalAmount <= available, entity.ErrInsufficientCreditLimit)
+	}
+
+	//Check Debt To Income
+	if maxInstallments, ok := e.creditPolicy.MaxMonthlyInstallments(in.Customer.Salary); ok {
+		existingInstallments, err := e.transactionRepo.SumOpenInstallmentAmounts(ctx, customerID)
+		if err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to sum open installments",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+			)
+			return nil, fmt.Errorf("failed to check debt to income: %w", err)
+		}
+		installmentAmount := result.installmentAmount()
+		result.check(entity.FinancingRuleDebtToIncome, existingInstallments+installmentAmount <= maxInstallments,
+			entity.NewExceedsDebtToIncomeError(installmentAmount, existingInstallments, maxInstallments))
+	} else {
+		result.check(entity.FinancingRuleDebtToIncome, true, nil)
+	}
+
+	return result, nil
+}
+
+// check records the outcome of rule, with err as the reason when it failed.
+func (e *eligibility) check(rule entity.FinancingRule, passed bool, err error) {
+	outcome := entity.EligibilityRuleResult{Rule: rule, Passed: passed}
+	if !passed {
+		outcome.Message = err.Error()
+		var coded interface{ ErrorCode() string }
+		if errors.As(err, &coded) {
+			outcome.Code = coded.ErrorCode()
+		}
+		e.failures = append(e.failures, err)
+	}
+	e.rules = append(e.rules, outcome)
+}
+
+func (e *eligibility) installmentAmount() float64 {
+	return e.schedule[0]
+}
+
+// err returns the first failed rule's error, or nil when every rule passed.
+func (e *eligibility) err() error {
+	if len(e.failures) == 0 {
+		return nil
+	}
+	return e.failures[0]
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"kredit-plus/internal/entity"
+	"testing"
+)
+
+func TestEligibilityEvaluateReportsFailuresInRuleOrder(t *testing.T) {
+	policy := entity.CreditPolicy{
+		MaxInterestRates:  map[string]float64{"white_goods": 5},
+		MaxDebtToIncome:   0.5,
+		RequiredDocuments: []string{string(entity.DocumentTypeKTP), string(entity.DocumentTypeSelfie)},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		mutate func(in *eligibilityInput, customers *fakeCustomerRepository)
+		//Rules expected to fail, in the order evaluate reports them
+		want []entity.FinancingRule
+		//The error evaluation.err returns, the first failure's
+		wantErr error
+	}{
+		{
+			name:   "eligible",
+			mutate: func(*eligibilityInput, *fakeCustomerRepository) {},
+		},
+		{
+			name: "inactive customer",
+			mutate: func(in *eligibilityInput, _ *fakeCustomerRepository) {
+				in.Customer.IsActive = false
+			},
+			want:    []entity.FinancingRule{entity.FinancingRuleCustomerActive},
+			wantErr: entity.ErrCustomerInactive,
+		},
+		{
+			name: "missing documents before missing limit",
+			mutate: func(in *eligibilityInput, customers *fakeCustomerRepository) {
+				customers.documents[in.Customer.ID] = nil
+				in.CreditLimit = nil
+			},
+			want:    []entity.FinancingRule{entity.FinancingRuleKYCComplete, entity.FinancingRuleCreditLimit},
+			wantErr: entity.ErrKYCIncomplete,
+		},
+		{
+			name: "out of stock before rate and limit",
+			mutate: func(in *eligibilityInput, _ *fakeCustomerRepository) {
+				in.Asset.Stock = 0
+				in.InterestRate = 10
+				in.CreditLimit.UsedAmount = 4000000
+			},
+			want: []entity.FinancingRule{
+				entity.FinancingRuleAssetAvailable,
+				entity.FinancingRuleInterestRate,
+				entity.FinancingRuleCreditLimit,
+			},
+			wantErr: entity.ErrAssetOutOfStock,
+		},
+		{
+			name: "rate over cap",
+			mutate: func(in *eligibilityInput, _ *fakeCustomerRepository) {
+				in.InterestRate = 10
+			},
+			want:    []entity.FinancingRule{entity.FinancingRuleInterestRate},
+			wantErr: entity.ErrInterestRateExceedsCap,
+		},
+		{
+			name: "limit before debt to income",
+			mutate: func(in *eligibilityInput, _ *fakeCustomerRepository) {
+				in.CreditLimit.UsedAmount = 4000000
+				in.Customer.Salary = 1000000
+			},
+			want:    []entity.FinancingRule{entity.FinancingRuleCreditLimit, entity.FinancingRuleDebtToIncome},
+			wantErr: entity.ErrInsufficientCreditLimit,
+		},
+		{
+			name: "debt to income",
+			mutate: func(in *eligibilityInput, _ *fakeCustomerRepository) {
+				in.Customer.Salary = 1000000
+			},
+			want:    []entity.FinancingRule{entity.FinancingRuleDebtToIncome},
+			wantErr: entity.ErrExceedsDebtToIncome,
+		},
+		{
+			name: "every rule",
+			mutate: func(in *eligibilityInput, customers *fakeCustomerRepository) {
+				in.Customer.IsActive = false
+				in.Customer.Salary = 1000000
+				customers.documents[in.Customer.ID] = nil
+				in.Asset.Stock = 0
+				in.InterestRate = 10
+				in.CreditLimit = nil
+			},
+			want: []entity.FinancingRule{
+				entity.FinancingRuleCustomerActive,
+				entity.FinancingRuleKYCComplete,
+				entity.FinancingRuleAssetAvailable,
+				entity.FinancingRuleInterestRate,
+				entity.FinancingRuleCreditLimit,
+				entity.FinancingRuleDebtToIncome,
+			},
+			wantErr: entity.ErrCustomerInactive,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			customer := &entity.Customer{ID: uuid.New(), Salary: 10000000, IsActive: true}
+			customers := newFakeCustomerRepository(customer)
+			customers.documents[customer.ID] = []entity.DocumentType{entity.DocumentTypeKTP, entity.DocumentTypeSelfie}
+			in := eligibilityInput{
+				Customer:     customer,
+				Asset:        &entity.Asset{ID: uuid.New(), Category: "white_goods", Price: 3000000, Stock: 5},
+				CreditLimit:  &entity.CreditLimit{CustomerID: customer.ID, TenorMonth: 3, LimitAmount: 5000000},
+				TenorMonth:   3,
+				InterestRate: 2,
+			}
+			tc.mutate(&in, customers)
+			evaluator := newEligibilityEvaluator(newFakeTransactionRepository(), customers, policy)
+
+			evaluation, err := evaluator.evaluate(context.Background(), in)
+			if err != nil {
+				t.Fatalf("evaluate returned error: %v", err)
+			}
+
+			var failed []entity.FinancingRule
+			for _, rule := range evaluation.rules {
+				if !rule.Passed {
+					failed = append(failed, rule.Rule)
+				}
+			}
+			if len(failed) != len(tc.want) {
+				t.Fatalf("failed rules %v, want %v", failed, tc.want)
+			}
+			for i := range tc.want {
+				if failed[i] != tc.want[i] {
+					t.Errorf("failed rule %d is %s, want %s", i, failed[i], tc.want[i])
+				}
+			}
+
+			if err := evaluation.err(); !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Errorf("err() = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"kredit-plus/internal/entity"
+	"sync"
+	"time"
+)
+
+// The fakes below embed the repository interfaces so that they only implement
+// what the tests exercise; calling anything else panics.
+
+var testNow = time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+
+// barrier holds its first n callers until all n have arrived and lets any
+// later caller straight through. A nil barrier never blocks.
+type barrier struct {
+	mu      sync.Mutex
+	pending int
+	release chan struct{}
+}
+
+func newBarrier(n int) *barrier {
+	return &barrier{pending: n, release: make(chan struct{})}
+}
+
+func (b *barrier) wait() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	if b.pending == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.pending--
+	if b.pending == 0 {
+		close(b.release)
+	}
+	b.mu.Unlock()
+
+	<-b.release
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// fakeTransactionRepository keeps transactions in memory and enforces the
+// unique contract number the way the database index does.
+type fakeTransactionRepository struct {
+	entity.TransactionRepository
+
+	mu           sync.Mutex
+	transactions map[uuid.UUID]*entity.Transaction
+	lockErr      error
+	//Holds contract number pre-checks so concurrent creates all pass it
+	//before any of them inserts
+	contractChecks *barrier
+}
+
+func newFakeTransactionRepository() *fakeTransactionRepository {
+	return &fakeTransactionRepository{transactions: make(map[uuid.UUID]*entity.Transaction)}
+}
+
+func (r *fakeTransactionRepository) Create(_ context.Context, transaction *entity.Transaction, schedule []float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.transactions {
+		if existing.ContractNumber == transaction.ContractNumber {
+			return entity.ErrDuplicateContract
+		}
+	}
+
+	stored := *transaction
+	for i, amount := range schedule {
+		stored.TransactionDetails = append(stored.TransactionDetails, entity.TransactionDetail{
+			ID:                uuid.New(),
+			TransactionID:     transaction.ID,
+			InstallmentNumber: i + 1,
+			Amount:            amount,
+			DueDate:           entity.InstallmentDueDate(transaction.CreatedAt, i+1, transaction.BillingDay),
+			Status:            entity.TransactionDetailStatusPending,
+		})
+	}
+	r.transactions[transaction.ID] = &stored
+	return nil
+}
+
+func (r *fakeTransactionRepository) GetByID(_ context.Context, id uuid.UUID) (*entity.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transaction, ok := r.transactions[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *transaction
+	return &found, nil
+}
+
+func (r *fakeTransactionRepository) GetByContractNumber(_ context.Context, contractNumber string) (*entity.Transaction, error) {
+	r.contractChecks.wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, transaction := range r.transactions {
+		if transaction.ContractNumber == contractNumber {
+			found := *transaction
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeTransactionRepository) LockCreditLimit(context.Context, uuid.UUID, int) (func(), bool, error) {
+	return func() {}, r.lockErr == nil, r.lockErr
+}
+
+// SumOpenInstallmentAmounts counts one installment for every stored
+// transaction of the customer.
+func (r *fakeTransactionRepository) SumOpenInstallmentAmounts(_ context.Context, customerID uuid.UUID) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sum float64
+	for _, transaction := range r.transactions {
+		if transaction.CustomerID == customerID {
+			sum += transaction.InstallmentAmount
+		}
+	}
+	return sum, nil
+}
+
+func (r *fakeTransactionRepository) ReleaseIdempotencyKey(context.Context, uuid.UUID, string) error {
+	return nil
+}
+
+func (r *fakeTransactionRepository) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.transactions)
+}
+
+type fakeCustomerRepository struct {
+	entity.CustomerRepository
+
+	mu        sync.Mutex
+	customers map[uuid.UUID]*entity.Customer
+	documents map[uuid.UUID][]entity.DocumentType
+}
+
+func newFakeCustomerRepository(customers ...*entity.Customer) *fakeCustomerRepository {
+	r := &fakeCustomerRepository{
+		customers: make(map[uuid.UUID]*entity.Customer),
+		documents: make(map[uuid.UUID][]entity.DocumentType),
+	}
+	for _, customer := range customers {
+		r.customers[customer.ID] = customer
+	}
+	return r
+}
+
+func (r *fakeCustomerRepository) GetByID(_ context.Context, id uuid.UUID, includeInactive bool) (*entity.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customer, ok := r.customers[id]
+	if !ok || (!includeInactive && !customer.IsActive) {
+		return nil, nil
+	}
+	found := *customer
+	return &found, nil
+}
+
+func (r *fakeCustomerRepository) DocumentsComplete(_ context.Context, customerID uuid.UUID, required []entity.DocumentType) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uploaded := make(map[entity.DocumentType]bool)
+	for _, documentType := range r.documents[customerID] {
+		uploaded[documentType] = true
+	}
+	for _, documentType := range required {
+		if !uploaded[documentType] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fakeCreditLimitRepository enforces one limit per customer and tenor the way
+// the unique index does.
+type fakeCreditLimitRepository struct {
+	entity.CreditLimitRepository
+
+	mu     sync.Mutex
+	limits map[uuid.UUID]*entity.CreditLimit
+	//Holds the duplicate pre-checks so concurrent creates all pass it
+	tenorChecks *barrier
+	//Runs under the lock before a limit update, for usage that grows between
+	//the caller's read and its write
+	beforeUpdate func(limit *entity.CreditLimit)
+	updates      int
+}
+
+func newFakeCreditLimitRepository(limits ...*entity.CreditLimit) *fakeCreditLimitRepository {
+	r := &fakeCreditLimitRepository{limits: make(map[uuid.UUID]*entity.CreditLimit)}
+	for _, limit := range limits {
+		r.limits[limit.ID] = limit
+	}
+	return r
+}
+
+func (r *fakeCreditLimitRepository) Create(_ context.Context, limit *entity.CreditLimit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.limits {
+		if existing.CustomerID == limit.CustomerID && existing.TenorMonth == limit.TenorMonth {
+			return entity.ErrDuplicateCreditLimit
+		}
+	}
+	stored := *limit
+	r.limits[limit.ID] = &stored
+	return nil
+}
+
+func (r *fakeCreditLimitRepository) GetByCustomerIDAndTenor(_ context.Context, customerID uuid.UUID, tenorMonth int) (*entity.CreditLimit, error) {
+	r.tenorChecks.wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, limit := range r.limits {
+		if limit.CustomerID == customerID && limit.TenorMonth == tenorMonth {
+			found := *limit
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeCreditLimitRepository) GetAllByCustomerID(_ context.Context, customerID uuid.UUID) ([]entity.CreditLimit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var limits []entity.CreditLimit
+	for _, limit := range r.limits {
+		if limit.CustomerID == customerID {
+			limits = append(limits, *limit)
+		}
+	}
+	return limits, nil
+}
+
+// UpdateLimitAmount applies the same used amount guard as the repository.
+func (r *fakeCreditLimitRepository) UpdateLimitAmount(_ context.Context, id uuid.UUID, newLimit float64) (*entity.CreditLimit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limit, ok := r.limits[id]
+	if !ok {
+		return nil, entity.ErrCreditLimitNotFound
+	}
+	if r.beforeUpdate != nil {
+		r.beforeUpdate(limit)
+	}
+	if newLimit < limit.UsedAmount {
+		return nil, entity.ErrLimitBelowUsedAmount
+	}
+	limit.LimitAmount = newLimit
+	r.updates++
+	updated := *limit
+	return &updated, nil
+}
+
+func (r *fakeCreditLimitRepository) limit(id uuid.UUID) entity.CreditLimit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return *r.limits[id]
+}
+
+type fakeAssetRepository struct {
+	entity.AssetRepository
+
+	mu     sync.Mutex
+	assets map[uuid.UUID]*entity.Asset
+	//Returned by every call when set, standing in for a database failure
+	err error
+}
+
+func newFakeAssetRepository(assets ...*entity.Asset) *fakeAssetRepository {
+	r := &fakeAssetRepository{assets: make(map[uuid.UUID]*entity.Asset)}
+	for _, asset := range assets {
+		r.assets[asset.ID] = asset
+	}
+	return r
+}
+
+func (r *fakeAssetRepository) GetByID(_ context.Context, id uuid.UUID) (*entity.Asset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	asset, ok := r.assets[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *asset
+	return &found, nil
+}
+
+func (r *fakeAssetRepository) Create(_ context.Context, asset *entity.Asset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	stored := *asset
+	r.assets[asset.ID] = &stored
+	return nil
+}
+
+// Update applies the same version guard and stock handling as the repository.
+func (r *fakeAssetRepository) Update(_ context.Context, asset *entity.Asset, updateStock bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	current, ok := r.assets[asset.ID]
+	if !ok {
+		return entity.ErrAssetNotFound
+	}
+	if current.Version != asset.Version {
+		return entity.ErrStaleWrite
+	}
+	asset.Version++
+	stored := *asset
+	if !updateStock {
+		stored.Stock = current.Stock
+	}
+	r.assets[asset.ID] = &stored
+	return nil
+}
+
+func (r *fakeAssetRepository) stored(id uuid.UUID) *entity.Asset {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.assets[id]
+}
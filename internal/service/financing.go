@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/internal/entity"
+	"strings"
+)
+
+type financingService struct {
+	transactionService entity.TransactionService
+	transactionRepo    entity.TransactionRepository
+	customerRepo       entity.CustomerRepository
+	creditLimitRepo    entity.CreditLimitRepository
+	assetRepo          entity.AssetRepository
+	creditPolicy       entity.CreditPolicy
+	eligibility        *eligibilityEvaluator
+	logger             *zap.Logger
+}
+
+func NewFinancingService(
+	transactionService entity.TransactionService,
+	transactionRepo entity.TransactionRepository,
+	customerRepo entity.CustomerRepository,
+	creditLimitRepo entity.CreditLimitRepository,
+	assetRepo entity.AssetRepository,
+	creditPolicy entity.CreditPolicy,
+	logger *zap.Logger,
+) entity.FinancingService {
+	return &financingService{
+		transactionService: transactionService,
+		transactionRepo:    transactionRepo,
+		customerRepo:       customerRepo,
+		creditLimitRepo:    creditLimitRepo,
+		assetRepo:          assetRepo,
+		creditPolicy:       creditPolicy,
+		eligibility:        newEligibilityEvaluator(transactionRepo, customerRepo, creditPolicy),
+		logger:             logger,
+	}
+}
+
+// Apply checks every eligibility rule and creates the transaction only when
+// all of them pass. Failed rules are reported together in the response rather
+// than returned as an error.
+func (s *financingService) Apply(ctx context.Context, req entity.FinancingApplicationRequest) (*entity.FinancingApplicationResponse, error) {
+	evaluation, err := s.evaluate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(evaluation.failures) > 0 {
+		var rejections []entity.FinancingRejection
+		for _, rule := range evaluation.rules {
+			if !rule.Passed {
+				rejections = append(rejections, entity.FinancingRejection{
+					Rule:    rule.Rule,
+					Code:    rule.Code,
+					Message: rule.Message,
+				})
+			}
+		}
+		return &entity.FinancingApplicationResponse{Rejections: rejections}, nil
+	}
+
+	transaction, err := s.transactionService.Create(ctx, req.TransactionRequest())
+	if err != nil {
+		//The customer's state can change between the checks above and the insert
+		if rule, ok := financingRuleFor(err); ok {
+			return &entity.FinancingApplicationResponse{
+				Rejections: []entity.FinancingRejection{newFinancingRejection(rule, err)},
+			}, nil
+		}
+		return nil, err
+	}
+
+	return &entity.FinancingApplicationResponse{
+		Approved:    true,
+		Transaction: transaction,
+	}, nil
+}
+
+// Check evaluates the same rules as Apply without creating anything.
+func (s *financingService) Check(ctx context.Context, req entity.FinancingApplicationRequest) (*entity.FinancingCheckResponse, error) {
+	evaluation, err := s.evaluate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.FinancingCheckResponse{
+		Eligible:          len(evaluation.failures) == 0,
+		OTRAmount:         evaluation.otrAmount,
+		AdminFee:          evaluation.adminFee,
+		InterestAmount:    evaluation.interestAmount,
+		TotalAmount:       evaluation.totalAmount,
+		InstallmentAmount: evaluation.installmentAmount(),
+		Rules:             evaluation.rules,
+	}, nil
+}
+
+// evaluate loads what the rules need and runs the shared transaction rules
+// followed by the financing amount rule.
+func (s *financingService) evaluate(ctx context.Context, req entity.FinancingApplicationRequest) (*eligibility, error) {
+	if errors := req.Validate(); len(errors) > 0 {
+		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	interestRate, err := s.creditPolicy.ResolveInterestRate(req.InterestRate, req.TenorMonth)
+	if err != nil {
+		return nil, err
+	}
+
+	//Deactivated customers cannot apply, so they are reported as not found
+	customer, err := s.customerRepo.GetByID(ctx, req.CustomerID, false)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get customer",
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID.String()),
+		)
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer == nil {
+		return nil, entity.ErrCustomerNotFound
+	}
+
+	asset, err := s.assetRepo.GetByID(ctx, req.AssetID)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get asset",
+			zap.Error(err),
+			zap.String("asset_id", req.AssetID.String()),
+		)
+		return nil, fmt.Errorf("failed to get asset: %w", err)
+	}
+	if asset == nil || asset.Retired() {
+		return nil, entity.ErrAssetNotFound
+	}
+
+	limit, err := s.creditLimitRepo.GetByCustomerIDAndTenor(ctx, req.CustomerID, req.TenorMonth)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit",
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID.String()),
+			zap.Int("tenor_month", req.TenorMonth),
+		)
+		return nil, fmt.Errorf("failed to get credit limit: %w", err)
+	}
+
+	evaluation, err := s.eligibility.evaluate(ctx, eligibilityInput{
+		Customer:     customer,
+		Asset:        asset,
+		CreditLimit:  limit,
+		TenorMonth:   req.TenorMonth,
+		AdminFee:     req.AdminFee,
+		InterestRate: interestRate,
+		InterestType: req.InterestType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	evaluation.check(entity.FinancingRuleAmount, req.Amount >= asset.Price,
+		entity.NewFinancingAmountBelowPriceError(req.Amount, asset.Price))
+
+	return evaluation, nil
+}
+
+// financingRuleFor maps an eligibility error returned by transaction creation
+// back to the rule it violates.
+func financingRuleFor(err error) (entity.FinancingRule, bool) {
+	switch {
+	case errors.Is(err, entity.ErrCustomerInactive):
+		return entity.FinancingRuleCustomerActive, true
+	case errors.Is(err, entity.ErrKYCIncomplete):
+		return entity.FinancingRuleKYCComplete, true
+	case errors.Is(err, entity.ErrAssetOutOfStock):
+		return entity.FinancingRuleAssetAvailable, true
+	case errors.Is(err, entity.ErrInterestRateExceedsCap):
+		return entity.FinancingRuleInterestRate, true
+	case errors.Is(err, entity.ErrAdminFeeExceedsMax):
+		return entity.FinancingRuleAdminFee, true
+	case errors.Is(err, entity.ErrCreditLimitNotFound), errors.Is(err, entity.ErrInsufficientCreditLimit):
+		return entity.FinancingRuleCreditLimit, true
+	case errors.Is(err, entity.ErrExceedsDebtToIncome):
+		return entity.FinancingRuleDebtToIncome, true
+	default:
+		return "", false
+	}
+}
+
+func newFinancingRejection(rule entity.FinancingRule, err error) entity.FinancingRejection {
+	rejection := entity.FinancingRejection{
+		Rule:    rule,
+		Message: err.Error(),
+	}
+
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		rejection.Code = coded.ErrorCode()
+	}
+	return rejection
+}
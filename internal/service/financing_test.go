@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"testing"
+)
+
+// racingTransactionService fails Create with err, standing in for a rule that
+// stopped holding between Apply's checks and the insert.
+type racingTransactionService struct {
+	entity.TransactionService
+	err error
+}
+
+func (s racingTransactionService) Create(context.Context, entity.CreateTransactionRequest) (*entity.TransactionResponse, error) {
+	return nil, s.err
+}
+
+// newFinancingService applies through the createFixture's transaction service
+// and fakes, checking against policy.
+func newFinancingService(fixture *createFixture, policy entity.CreditPolicy) *financingService {
+	service := NewFinancingService(fixture.service, fixture.transactions, fixture.customers, fixture.limits,
+		fixture.assets, policy, zap.NewNop())
+	return service.(*financingService)
+}
+
+func financingRequest(fixture *createFixture) entity.FinancingApplicationRequest {
+	return entity.FinancingApplicationRequest{
+		CustomerID:   fixture.request.CustomerID,
+		AssetID:      fixture.request.AssetID,
+		TenorMonth:   fixture.request.TenorMonth,
+		Amount:       3000000,
+		InterestRate: 2,
+	}
+}
+
+func TestApplyApprovedCreatesOneTransaction(t *testing.T) {
+	fixture := newCreateFixture()
+	service := newFinancingService(fixture, fixture.service.creditPolicy)
+
+	response, err := service.Apply(context.Background(), financingRequest(fixture))
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if !response.Approved || response.Transaction == nil || len(response.Rejections) != 0 {
+		t.Fatalf("Apply returned %+v, want an approval with its transaction", response)
+	}
+	if got := fixture.transactions.count(); got != 1 {
+		t.Errorf("stored %d transactions, want 1", got)
+	}
+}
+
+func TestApplyRejectionListsEveryFailedRule(t *testing.T) {
+	fixture := newCreateFixture()
+	fixture.customers.documents[fixture.request.CustomerID] = nil
+	fixture.assets.assets[fixture.request.AssetID].Stock = 0
+	policy := fixture.service.creditPolicy
+	policy.MaxInterestRates = map[string]float64{"white_goods": 1}
+	policy.MaxDebtToIncome = 0.01
+	service := newFinancingService(fixture, policy)
+
+	req := financingRequest(fixture)
+	req.Amount = 1000000
+	//No credit limit exists for this tenor
+	req.TenorMonth = 6
+
+	response, err := service.Apply(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	want := []entity.FinancingRule{
+		entity.FinancingRuleKYCComplete,
+		entity.FinancingRuleAssetAvailable,
+		entity.FinancingRuleInterestRate,
+		entity.FinancingRuleCreditLimit,
+		entity.FinancingRuleDebtToIncome,
+		entity.FinancingRuleAmount,
+	}
+	if response.Approved || len(response.Rejections) != len(want) {
+		t.Fatalf("Apply returned %+v, want %d rejections", response, len(want))
+	}
+	for i, rule := range want {
+		if response.Rejections[i].Rule != rule {
+			t.Errorf("rejection %d is %s, want %s", i, response.Rejections[i].Rule, rule)
+		}
+	}
+	if got := fixture.transactions.count(); got != 0 {
+		t.Errorf("stored %d transactions, want 0", got)
+	}
+}
+
+func TestApplyReportsDeactivatedCustomerAsNotFound(t *testing.T) {
+	fixture := newCreateFixture()
+	fixture.customers.customers[fixture.request.CustomerID].IsActive = false
+	service := newFinancingService(fixture, fixture.service.creditPolicy)
+
+	if _, err := service.Apply(context.Background(), financingRequest(fixture)); err != entity.ErrCustomerNotFound {
+		t.Fatalf("Apply returned %v, want ErrCustomerNotFound", err)
+	}
+	if got := fixture.transactions.count(); got != 0 {
+		t.Errorf("stored %d transactions, want 0", got)
+	}
+}
+
+func TestApplyMapsCreateRaceToRule(t *testing.T) {
+	errDatabaseDown := errors.New("dial tcp: connection refused")
+
+	for _, tc := range []struct {
+		name      string
+		createErr error
+		wantRule  entity.FinancingRule
+	}{
+		{"sold out", entity.ErrAssetOutOfStock, entity.FinancingRuleAssetAvailable},
+		{"limit used up", entity.ErrInsufficientCreditLimit, entity.FinancingRuleCreditLimit},
+		{"document removed", entity.ErrKYCIncomplete, entity.FinancingRuleKYCComplete},
+		{"unrelated failure", errDatabaseDown, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fixture := newCreateFixture()
+			service := newFinancingService(fixture, fixture.service.creditPolicy)
+			service.transactionService = racingTransactionService{err: tc.createErr}
+
+			response, err := service.Apply(context.Background(), financingRequest(fixture))
+			if tc.wantRule == "" {
+				if !errors.Is(err, tc.createErr) {
+					t.Fatalf("Apply returned %v, want %v", err, tc.createErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply returned error: %v", err)
+			}
+
+			if response.Approved || len(response.Rejections) != 1 {
+				t.Fatalf("Apply returned %+v, want one rejection", response)
+			}
+			if rejection := response.Rejections[0]; rejection.Rule != tc.wantRule || rejection.Message != tc.createErr.Error() {
+				t.Errorf("rejection %+v, want rule %s for %v", rejection, tc.wantRule, tc.createErr)
+			}
+		})
+	}
+}
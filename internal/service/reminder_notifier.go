@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	loggerPkg "kredit-plus/infra/logger"
+	"kredit-plus/internal/entity"
+)
+
+// logReminderNotifier records reminders in the application log until a real
+// delivery channel (email, SMS) is wired in.
+type logReminderNotifier struct {
+	logger *zap.Logger
+}
+
+func NewLogReminderNotifier(logger *zap.Logger) entity.ReminderNotifier {
+	return &logReminderNotifier{
+		logger: logger,
+	}
+}
+
+func (n *logReminderNotifier) NotifyInstallmentDue(ctx context.Context, reminder entity.InstallmentReminder) error {
+	loggerPkg.FromContext(ctx).Info("installment due reminder",
+		zap.String("transaction_id", reminder.TransactionID.String()),
+		zap.String("contract_number", reminder.ContractNumber),
+		zap.String("customer_id", reminder.CustomerID.String()),
+		zap.Int("installment_number", reminder.InstallmentNumber),
+		zap.Float64("amount_due", reminder.AmountDue),
+		zap.String("due_date", reminder.DueDate),
+	)
+
+	return nil
+}
@@ -2,20 +2,35 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"io"
+	loggerPkg "kredit-plus/infra/logger"
 	"kredit-plus/internal/entity"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	maxContractNumberAttempts = 3
+	idempotencyPollInterval   = 100 * time.Millisecond
+)
+
 type transactionService struct {
 	transactionRepo entity.TransactionRepository
 	customerRepo    entity.CustomerRepository
 	creditLimitRepo entity.CreditLimitRepository
 	assetRepo       entity.AssetRepository
+	notifier        entity.ReminderNotifier
+	creditPolicy    entity.CreditPolicy
+	eligibility     *eligibilityEvaluator
+	clock           entity.Clock
 	logger          *zap.Logger
 }
 
@@ -24,6 +39,9 @@ func NewTransactionService(
 	customerRepo entity.CustomerRepository,
 	creditLimitRepo entity.CreditLimitRepository,
 	assetRepo entity.AssetRepository,
+	notifier entity.ReminderNotifier,
+	creditPolicy entity.CreditPolicy,
+	clock entity.Clock,
 	logger *zap.Logger,
 ) entity.TransactionService {
 	return &transactionService{
@@ -31,15 +49,64 @@ func NewTransactionService(
 		customerRepo:    customerRepo,
 		creditLimitRepo: creditLimitRepo,
 		assetRepo:       assetRepo,
+		notifier:        notifier,
+		creditPolicy:    creditPolicy,
+		eligibility:     newEligibilityEvaluator(transactionRepo, customerRepo, creditPolicy),
+		clock:           clock,
 		logger:          logger,
 	}
 }
 
+// Create creates the transaction. When the request carries an idempotency key,
+// the key must have been claimed with ClaimIdempotencyKey; it is released again
+// if creation fails so that a retry is processed.
 func (s *transactionService) Create(ctx context.Context, req entity.CreateTransactionRequest) (*entity.TransactionResponse, error) {
+	response, err := s.create(ctx, req)
+	if err != nil && req.IdempotencyKey != "" {
+		if releaseErr := s.transactionRepo.ReleaseIdempotencyKey(ctx, req.CustomerID, req.IdempotencyKey); releaseErr != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to release idempotency key",
+				zap.Error(releaseErr),
+				zap.String("customer_id", req.CustomerID.String()),
+			)
+		}
+	}
+	return response, err
+}
+
+func (s *transactionService) create(ctx context.Context, req entity.CreateTransactionRequest) (*entity.TransactionResponse, error) {
+	//XSS Protection
+	req.Sanitize()
 	if errors := req.Validate(); len(errors) > 0 {
 		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
+	interestRate, err := s.creditPolicy.ResolveInterestRate(req.InterestRate, req.TenorMonth)
+	if err != nil {
+		return nil, err
+	}
+	req.InterestRate = interestRate
+
+	generatedContract := req.ContractNumber == ""
+	if generatedContract {
+		contractNumber, err := s.generateContractNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.ContractNumber = contractNumber
+	}
+
+	//Fail fast when another request is already spending this credit limit
+	release, acquired, err := s.transactionRepo.LockCreditLimit(ctx, req.CustomerID, req.TenorMonth)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Warn("failed to acquire credit limit lock, relying on database lock",
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID.String()),
+		)
+	} else if !acquired {
+		return nil, entity.ErrConcurrentModification
+	}
+	defer release()
+
 	existingTxChan := make(chan struct {
 		trx *entity.Transaction
 		err error
@@ -69,7 +136,8 @@ func (s *transactionService) Create(ctx context.Context, req entity.CreateTransa
 	}()
 	go func() {
 		defer wg.Done()
-		customer, err := s.customerRepo.GetByID(ctx, req.CustomerID)
+		//Deactivated customers cannot buy, so they are reported as not found
+		customer, err := s.customerRepo.GetByID(ctx, req.CustomerID, false)
 		customerChan <- struct {
 			customer *entity.Customer
 			err      error
@@ -106,7 +174,7 @@ func (s *transactionService) Create(ctx context.Context, req entity.CreateTransa
 
 	//Check trx
 	if existingTxResult.err != nil {
-		s.logger.Error("failed to check existing contract number",
+		loggerPkg.FromContext(ctx).Error("failed to check existing contract number",
 			zap.Error(existingTxResult.err),
 			zap.String("contract_number", req.ContractNumber),
 		)
@@ -118,50 +186,52 @@ func (s *transactionService) Create(ctx context.Context, req entity.CreateTransa
 
 	//Check Customer
 	if customerResult.err != nil {
-		s.logger.Error("failed to get customer",
+		loggerPkg.FromContext(ctx).Error("failed to get customer",
 			zap.Error(customerResult.err),
 			zap.String("customer_id", req.CustomerID.String()),
 		)
 		return nil, fmt.Errorf("failed to get customer: %w", customerResult.err)
 	}
 	if customerResult.customer == nil {
-		return nil, fmt.Errorf("customer not found")
-	}
-	if !customerResult.customer.IsActive {
-		return nil, fmt.Errorf("customer is not active")
+		return nil, entity.ErrCustomerNotFound
 	}
 
 	//Check Asset
 	if assetResult.err != nil {
-		s.logger.Error("failed to get asset",
+		loggerPkg.FromContext(ctx).Error("failed to get asset",
 			zap.Error(assetResult.err),
 			zap.String("asset_id", req.AssetID.String()),
 		)
 		return nil, fmt.Errorf("failed to get asset: %w", assetResult.err)
 	}
-	if assetResult.asset == nil {
-		return nil, fmt.Errorf("asset not found")
+	if assetResult.asset == nil || assetResult.asset.Retired() {
+		return nil, entity.ErrAssetNotFound
 	}
 
 	//Check Credit Limit
 	if creditLimitResult.err != nil {
-		s.logger.Error("failed to get credit limit",
+		loggerPkg.FromContext(ctx).Error("failed to get credit limit",
 			zap.Error(creditLimitResult.err),
 			zap.String("customer_id", req.CustomerID.String()),
 			zap.Int("tenor_month", req.TenorMonth),
 		)
 		return nil, fmt.Errorf("failed to get credit limit: %w", creditLimitResult.err)
 	}
-	if creditLimitResult.creditLimit == nil {
-		return nil, fmt.Errorf("no credit limit found for tenor %d months", req.TenorMonth)
-	}
 
-	interestAmount := (assetResult.asset.Price * req.InterestRate * float64(req.TenorMonth)) / 100
-	totalAmount := assetResult.asset.Price + req.AdminFee + interestAmount
-	installmentAmount := totalAmount / float64(req.TenorMonth)
-
-	if totalAmount > creditLimitResult.creditLimit.LimitAmount-creditLimitResult.creditLimit.UsedAmount {
-		return nil, entity.ErrInsufficientCreditLimit
+	evaluation, err := s.eligibility.evaluate(ctx, eligibilityInput{
+		Customer:     customerResult.customer,
+		Asset:        assetResult.asset,
+		CreditLimit:  creditLimitResult.creditLimit,
+		TenorMonth:   req.TenorMonth,
+		AdminFee:     req.AdminFee,
+		InterestRate: req.InterestRate,
+		InterestType: req.InterestType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := evaluation.err(); err != nil {
+		return nil, err
 	}
 
 	transaction := &entity.Transaction{
@@ -170,34 +240,43 @@ func (s *transactionService) Create(ctx context.Context, req entity.CreateTransa
 		AssetID:           req.AssetID,
 		ContractNumber:    req.ContractNumber,
 		OTRAmount:         assetResult.asset.Price,
-		AdminFee:          req.AdminFee,
-		InterestAmount:    interestAmount,
+		AdminFee:          evaluation.adminFee,
+		InterestAmount:    evaluation.interestAmount,
 		TenorMonth:        req.TenorMonth,
-		InstallmentAmount: installmentAmount,
+		InstallmentAmount: evaluation.installmentAmount(),
+		InterestType:      evaluation.interestType,
+		BillingDay:        req.BillingDay,
 		Status:            entity.TransactionStatusPending,
-		CreatedAt:         time.Now().UTC(),
-		UpdatedAt:         time.Now().UTC(),
+		CreatedAt:         s.clock.Now(),
+		UpdatedAt:         s.clock.Now(),
 	}
 
-	if err := s.transactionRepo.Create(ctx, transaction); err != nil {
-		s.logger.Error("failed to create transaction",
+	//Credit limit usage is deducted atomically with the insert
+	if err := s.insertTransaction(ctx, transaction, evaluation.schedule, generatedContract); err != nil {
+		if err == entity.ErrInsufficientCreditLimit || err == entity.ErrAssetOutOfStock || err == entity.ErrDuplicateContract {
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to create transaction",
 			zap.Error(err),
 			zap.String("customer_id", req.CustomerID.String()),
 		)
 		return nil, fmt.Errorf("failed to create transaction: %w", err)
 	}
 
-	if err := s.creditLimitRepo.UpdateUsedAmount(ctx, creditLimitResult.creditLimit.ID, totalAmount); err != nil {
-		s.logger.Error("failed to update credit limit used amount",
-			zap.Error(err),
-			zap.String("credit_limit_id", creditLimitResult.creditLimit.ID.String()),
-		)
-		return nil, fmt.Errorf("failed to update credit limit: %w", err)
+	if req.IdempotencyKey != "" {
+		if err := s.transactionRepo.SetIdempotencyKey(ctx, req.CustomerID, req.IdempotencyKey, transaction.ID); err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to store idempotency key",
+				zap.Error(err),
+				zap.String("transaction_id", transaction.ID.String()),
+			)
+			//Do not leave retries waiting on a reservation that will never resolve
+			_ = s.transactionRepo.ReleaseIdempotencyKey(ctx, req.CustomerID, req.IdempotencyKey)
+		}
 	}
 
 	createdTx, err := s.transactionRepo.GetByID(ctx, transaction.ID)
 	if err != nil {
-		s.logger.Error("failed to get created transaction",
+		loggerPkg.FromContext(ctx).Error("failed to get created transaction",
 			zap.Error(err),
 			zap.String("transaction_id", transaction.ID.String()),
 		)
@@ -210,7 +289,7 @@ func (s *transactionService) Create(ctx context.Context, req entity.CreateTransa
 func (s *transactionService) GetByID(ctx context.Context, id uuid.UUID) (*entity.TransactionResponse, error) {
 	transaction, err := s.transactionRepo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get transaction",
+		loggerPkg.FromContext(ctx).Error("failed to get transaction",
 			zap.Error(err),
 			zap.String("transaction_id", id.String()),
 		)
@@ -224,10 +303,56 @@ func (s *transactionService) GetByID(ctx context.Context, id uuid.UUID) (*entity
 	return s.toResponse(transaction), nil
 }
 
+// ClaimIdempotencyKey reserves the key for the caller, returning nil when the
+// caller should go on to create the transaction. When the key was already used
+// it returns the transaction created under it, waiting up to
+// IdempotencyWaitTime for a concurrent request still holding the key.
+func (s *transactionService) ClaimIdempotencyKey(ctx context.Context, customerID uuid.UUID, idempotencyKey string) (*entity.TransactionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, entity.IdempotencyWaitTime)
+	defer cancel()
+
+	ticker := time.NewTicker(idempotencyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		reserved, err := s.transactionRepo.ReserveIdempotencyKey(ctx, customerID, idempotencyKey)
+		if err != nil {
+			//Without Redis the key cannot be honoured either way
+			loggerPkg.FromContext(ctx).Warn("failed to reserve idempotency key",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+			)
+			return nil, nil
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		transactionID, err := s.transactionRepo.GetIdempotencyKey(ctx, customerID, idempotencyKey)
+		if err != nil && err != entity.ErrIdempotencyKeyInProgress {
+			loggerPkg.FromContext(ctx).Error("failed to get idempotency key",
+				zap.Error(err),
+				zap.String("customer_id", customerID.String()),
+			)
+			return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		if transactionID != uuid.Nil {
+			return s.GetByID(ctx, transactionID)
+		}
+
+		//Either still in progress, or released by a failed request and free to reserve again
+		select {
+		case <-ctx.Done():
+			return nil, entity.ErrIdempotencyKeyInProgress
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *transactionService) GetByContractNumber(ctx context.Context, contractNumber string) (*entity.TransactionResponse, error) {
 	transaction, err := s.transactionRepo.GetByContractNumber(ctx, contractNumber)
 	if err != nil {
-		s.logger.Error("failed to get transaction by contract number",
+		loggerPkg.FromContext(ctx).Error("failed to get transaction by contract number",
 			zap.Error(err),
 			zap.String("contract_number", contractNumber),
 		)
@@ -241,18 +366,52 @@ func (s *transactionService) GetByContractNumber(ctx context.Context, contractNu
 	return s.toResponse(transaction), nil
 }
 
-func (s *transactionService) GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, filter entity.TransactionFilterRequest) ([]entity.TransactionResponse, int64, error) {
+func (s *transactionService) GetInstallments(ctx context.Context, transactionID uuid.UUID, page, perPage int) ([]entity.InstallmentResponse, int64, error) {
+	transaction, err := s.transactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get transaction for installments",
+			zap.Error(err),
+			zap.String("transaction_id", transactionID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	if transaction == nil {
+		return nil, 0, entity.ErrTransactionNotFound
+	}
+
+	installments, count, err := s.transactionRepo.GetInstallments(ctx, transactionID, perPage, (page-1)*perPage)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get installments",
+			zap.Error(err),
+			zap.String("transaction_id", transactionID.String()),
+		)
+		return nil, 0, fmt.Errorf("failed to get installments: %w", err)
+	}
+
+	responses := make([]entity.InstallmentResponse, len(installments))
+	for i, installment := range installments {
+		responses[i] = *s.toInstallmentResponse(&installment)
+	}
+
+	return responses, count, nil
+}
+
+// GetAllByCustomerID returns a next cursor whenever a full page sorted by
+// created_at was returned, so clients can continue in cursor mode from any page.
+func (s *transactionService) GetAllByCustomerID(ctx context.Context, customerID uuid.UUID, filter entity.TransactionFilterRequest) ([]entity.TransactionResponse, int64, string, error) {
 	if errors := filter.Validate(); len(errors) > 0 {
-		return nil, 0, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+		return nil, 0, "", fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
 	}
 
-	transactions, count, err := s.transactionRepo.GetAllByCustomerID(ctx, customerID, filter.ToTransactionFilterRepo())
+	repoFilter := filter.ToTransactionFilterRepo()
+	transactions, count, err := s.transactionRepo.GetAllByCustomerID(ctx, customerID, repoFilter)
 	if err != nil {
-		s.logger.Error("failed to get customer transactions",
+		loggerPkg.FromContext(ctx).Error("failed to get customer transactions",
 			zap.Error(err),
 			zap.String("customer_id", customerID.String()),
 		)
-		return nil, 0, fmt.Errorf("failed to get transactions: %w", err)
+		return nil, 0, "", fmt.Errorf("failed to get transactions: %w", err)
 	}
 
 	responses := make([]entity.TransactionResponse, len(transactions))
@@ -260,7 +419,80 @@ func (s *transactionService) GetAllByCustomerID(ctx context.Context, customerID
 		responses[i] = *s.toResponse(&tx)
 	}
 
-	return responses, count, nil
+	var nextCursor string
+	if len(transactions) > 0 && len(transactions) == repoFilter.Limit && repoFilter.SortBy == entity.DefaultTransactionSortBy {
+		last := transactions[len(transactions)-1]
+		nextCursor = entity.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	return responses, count, nextCursor, nil
+}
+
+// ExportByCustomerID writes the customer's transactions matching filter to w as
+// CSV, up to entity.MaxTransactionExportRows. It returns the number of rows written.
+func (s *transactionService) ExportByCustomerID(ctx context.Context, customerID uuid.UUID, filter entity.TransactionFilterRequest, w io.Writer) (int, error) {
+	if errors := filter.ValidateExport(); len(errors) > 0 {
+		return 0, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	repoFilter := filter.ToTransactionFilterRepo()
+	repoFilter.Limit = entity.MaxTransactionExportRows
+	repoFilter.Offset = 0
+	repoFilter.Cursor = nil
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"contract_number", "otr_amount", "admin_fee", "interest_amount",
+		"tenor_month", "installment_amount", "status", "created_at",
+	}); err != nil {
+		return 0, fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	count, err := s.transactionRepo.StreamByCustomerID(ctx, customerID, repoFilter, func(transaction *entity.Transaction) error {
+		return writer.Write([]string{
+			csvSafe(transaction.ContractNumber),
+			strconv.FormatFloat(transaction.OTRAmount, 'f', 2, 64),
+			strconv.FormatFloat(transaction.AdminFee, 'f', 2, 64),
+			strconv.FormatFloat(transaction.InterestAmount, 'f', 2, 64),
+			strconv.Itoa(transaction.TenorMonth),
+			strconv.FormatFloat(transaction.InstallmentAmount, 'f', 2, 64),
+			string(transaction.Status),
+			transaction.CreatedAt.Format(time.RFC3339),
+		})
+	})
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to export customer transactions",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+			zap.Int("rows_written", count),
+		)
+		return count, fmt.Errorf("failed to export transactions: %w", err)
+	}
+
+	loggerPkg.FromContext(ctx).Info("customer transactions exported",
+		zap.String("customer_id", customerID.String()),
+		zap.Int("rows", count),
+		zap.Any("filter", filter),
+	)
+
+	return count, nil
+}
+
+func (s *transactionService) GetCustomerSummary(ctx context.Context, customerID uuid.UUID) (*entity.CustomerTransactionSummary, error) {
+	summary, err := s.transactionRepo.GetCustomerSummary(ctx, customerID)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get customer transaction summary",
+			zap.Error(err),
+			zap.String("customer_id", customerID.String()),
+		)
+		return nil, fmt.Errorf("failed to get customer summary: %w", err)
+	}
+
+	return summary, nil
 }
 
 func (s *transactionService) UpdateStatus(ctx context.Context, id uuid.UUID, status entity.TransactionStatus) error {
@@ -268,9 +500,13 @@ func (s *transactionService) UpdateStatus(ctx context.Context, id uuid.UUID, sta
 		return entity.ErrInvalidStatus
 	}
 
+	if status == entity.TransactionStatusCancelled {
+		return s.Cancel(ctx, id)
+	}
+
 	transaction, err := s.transactionRepo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get transaction for status update",
+		loggerPkg.FromContext(ctx).Error("failed to get transaction for status update",
 			zap.Error(err),
 			zap.String("transaction_id", id.String()),
 		)
@@ -281,8 +517,15 @@ func (s *transactionService) UpdateStatus(ctx context.Context, id uuid.UUID, sta
 		return entity.ErrTransactionNotFound
 	}
 
+	if !transaction.Status.CanTransitionTo(status) {
+		return entity.NewInvalidStatusTransitionError(transaction.Status, status)
+	}
+
 	if err := s.transactionRepo.UpdateStatus(ctx, id, status); err != nil {
-		s.logger.Error("failed to update transaction status",
+		if err == entity.ErrTransactionNotFound || errors.Is(err, entity.ErrInvalidStatusTransition) {
+			return err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to update transaction status",
 			zap.Error(err),
 			zap.String("transaction_id", id.String()),
 		)
@@ -292,6 +535,370 @@ func (s *transactionService) UpdateStatus(ctx context.Context, id uuid.UUID, sta
 	return nil
 }
 
+// BatchUpdateStatus applies one status to many transactions atomically: either
+// every transaction moves to the new status or, when any of them is missing or
+// cannot make the transition, none do and the response reports why per ID.
+// Cancellation is not supported here because it also releases credit limit
+// and stock.
+func (s *transactionService) BatchUpdateStatus(ctx context.Context, req entity.BatchUpdateStatusRequest) (*entity.BatchUpdateStatusResponse, error) {
+	if len(req.IDs) == 0 {
+		return nil, entity.ErrBatchStatusUpdateEmpty
+	}
+	if len(req.IDs) > entity.MaxBatchStatusUpdate {
+		return nil, entity.ErrBatchStatusUpdateTooLarge
+	}
+	if !req.Status.IsValid() {
+		return nil, entity.ErrInvalidStatus
+	}
+	if req.Status == entity.TransactionStatusCancelled {
+		return nil, entity.ErrBatchCancelNotSupported
+	}
+
+	//Duplicate IDs are reported once
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	seen := make(map[uuid.UUID]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	results, applied, err := s.transactionRepo.BatchUpdateStatus(ctx, ids, req.Status)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to batch update transaction status",
+			zap.Error(err),
+			zap.Int("count", len(ids)),
+			zap.String("status", string(req.Status)),
+		)
+		return nil, fmt.Errorf("failed to batch update status: %w", err)
+	}
+
+	response := &entity.BatchUpdateStatusResponse{
+		Status:  req.Status,
+		Applied: applied,
+		Total:   len(results),
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	loggerPkg.FromContext(ctx).Info("batch transaction status update processed",
+		zap.String("status", string(req.Status)),
+		zap.Bool("applied", response.Applied),
+		zap.Int("total", response.Total),
+		zap.Int("failed", response.Failed),
+	)
+
+	return response, nil
+}
+
+func (s *transactionService) PayInstallment(ctx context.Context, transactionID uuid.UUID, installmentNumber int, amount float64, allowOverpay bool) (*entity.InstallmentResponse, error) {
+	if installmentNumber < 1 {
+		return nil, entity.ErrInstallmentNotFound
+	}
+	if amount <= 0 {
+		return nil, entity.ErrInvalidPaymentAmount
+	}
+
+	installment, status, err := s.transactionRepo.PayInstallment(ctx, transactionID, installmentNumber, amount, allowOverpay)
+	if err != nil {
+		switch err {
+		case entity.ErrTransactionNotFound, entity.ErrInstallmentNotFound, entity.ErrInstallmentAlreadyPaid, entity.ErrInstallmentOverpayment, entity.ErrTransactionNotPayable:
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to pay installment",
+			zap.Error(err),
+			zap.String("transaction_id", transactionID.String()),
+			zap.Int("installment_number", installmentNumber),
+		)
+		return nil, fmt.Errorf("failed to pay installment: %w", err)
+	}
+
+	response := s.toInstallmentResponse(installment)
+	response.TransactionStatus = status
+
+	return response, nil
+}
+
+func (s *transactionService) GetPayoff(ctx context.Context, id uuid.UUID) (*entity.PayoffResponse, error) {
+	transaction, err := s.transactionRepo.GetByID(ctx, id)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get transaction for payoff",
+			zap.Error(err),
+			zap.String("transaction_id", id.String()),
+		)
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	if transaction == nil {
+		return nil, entity.ErrTransactionNotFound
+	}
+
+	var unpaid []entity.TransactionDetail
+	for _, detail := range transaction.TransactionDetails {
+		if detail.Status != entity.TransactionDetailStatusPaid {
+			unpaid = append(unpaid, detail)
+		}
+	}
+
+	if len(unpaid) == 0 ||
+		(transaction.Status != entity.TransactionStatusPending && transaction.Status != entity.TransactionStatusActive) {
+		return nil, entity.ErrTransactionNotSettleable
+	}
+
+	return calculatePayoff(transaction, unpaid), nil
+}
+
+func (s *transactionService) Settle(ctx context.Context, id uuid.UUID) (*entity.PayoffResponse, error) {
+	payoff, err := s.transactionRepo.Settle(ctx, id, calculatePayoff)
+	if err != nil {
+		switch err {
+		case entity.ErrTransactionNotFound, entity.ErrTransactionNotSettleable:
+			return nil, err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to settle transaction",
+			zap.Error(err),
+			zap.String("transaction_id", id.String()),
+		)
+		return nil, fmt.Errorf("failed to settle transaction: %w", err)
+	}
+
+	loggerPkg.FromContext(ctx).Info("transaction settled early",
+		zap.String("transaction_id", id.String()),
+		zap.Float64("settlement_amount", payoff.SettlementAmount),
+		zap.Float64("late_fees", payoff.LateFees),
+		zap.Float64("waived_interest", payoff.WaivedInterest),
+	)
+
+	return payoff, nil
+}
+
+// Preview computes the installment schedule a transaction with these terms
+// would get if it were created now. Nothing is persisted.
+func (s *transactionService) Preview(ctx context.Context, req entity.TransactionPreviewRequest) (*entity.TransactionPreviewResponse, error) {
+	if errors := req.Validate(); len(errors) > 0 {
+		return nil, fmt.Errorf("validation failed: %v", strings.Join(errors, "||"))
+	}
+
+	interestRate, err := s.creditPolicy.ResolveInterestRate(req.InterestRate, req.TenorMonth)
+	if err != nil {
+		return nil, err
+	}
+	if maxFee := s.creditPolicy.MaxAdminFee; maxFee > 0 && req.AdminFee > maxFee {
+		return nil, entity.NewAdminFeeExceedsMaxError(req.AdminFee, maxFee)
+	}
+
+	interestType := req.InterestType
+	if interestType == "" {
+		interestType = entity.InterestTypeFlat
+	}
+
+	interestAmount, schedule := calculateInstallmentSchedule(req.OTRAmount, req.AdminFee, interestRate, req.TenorMonth, interestType)
+	totalAmount := roundCurrency(req.OTRAmount + req.AdminFee + interestAmount)
+
+	start := s.clock.Now()
+	remaining := totalAmount
+	installments := make([]entity.InstallmentPreview, len(schedule))
+	for i, amount := range schedule {
+		remaining = roundCurrency(remaining - amount)
+		installments[i] = entity.InstallmentPreview{
+			InstallmentNumber: i + 1,
+			Amount:            amount,
+			DueDate:           entity.InstallmentDueDate(start, i+1, req.BillingDay).Format("2006-01-02"),
+			RemainingBalance:  remaining,
+		}
+	}
+
+	return &entity.TransactionPreviewResponse{
+		OTRAmount:      req.OTRAmount,
+		AdminFee:       req.AdminFee,
+		InterestRate:   interestRate,
+		InterestType:   interestType,
+		InterestAmount: interestAmount,
+		TotalAmount:    totalAmount,
+		TenorMonth:     req.TenorMonth,
+		Installments:   installments,
+	}, nil
+}
+
+func (s *transactionService) RunOverdueSweep(ctx context.Context, lateFeeRate float64) (int, error) {
+	affected, err := s.transactionRepo.MarkOverdueInstallments(ctx, lateFeeRate)
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to run overdue sweep",
+			zap.Error(err),
+		)
+		return 0, fmt.Errorf("failed to run overdue sweep: %w", err)
+	}
+
+	if affected > 0 {
+		loggerPkg.FromContext(ctx).Info("marked installments as overdue",
+			zap.Int("count", affected),
+		)
+	}
+
+	return affected, nil
+}
+
+// SendDueReminders notifies customers of the installments falling due daysAhead
+// days from today. Each installment is reminded at most once per day, so the job
+// can run more often than daily.
+func (s *transactionService) SendDueReminders(ctx context.Context, daysAhead int) (int, error) {
+	now := s.clock.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, daysAhead)
+
+	installments, err := s.transactionRepo.GetInstallmentsDueBetween(ctx, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		loggerPkg.FromContext(ctx).Error("failed to get installments due for reminders",
+			zap.Error(err),
+			zap.Int("days_ahead", daysAhead),
+		)
+		return 0, fmt.Errorf("failed to get due installments: %w", err)
+	}
+
+	sent := 0
+	for _, installment := range installments {
+		acquired, err := s.transactionRepo.MarkReminderSent(ctx, installment.ID, today)
+		if err != nil {
+			loggerPkg.FromContext(ctx).Warn("failed to claim installment reminder",
+				zap.Error(err),
+				zap.String("installment_id", installment.ID.String()),
+			)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		if err := s.sendDueReminder(ctx, &installment); err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to send installment reminder",
+				zap.Error(err),
+				zap.String("installment_id", installment.ID.String()),
+			)
+			if err := s.transactionRepo.ClearReminderMarker(ctx, installment.ID, today); err != nil {
+				loggerPkg.FromContext(ctx).Warn("failed to clear installment reminder marker",
+					zap.Error(err),
+					zap.String("installment_id", installment.ID.String()),
+				)
+			}
+			continue
+		}
+		sent++
+	}
+
+	if sent > 0 {
+		loggerPkg.FromContext(ctx).Info("sent installment due reminders",
+			zap.Int("count", sent),
+			zap.Int("days_ahead", daysAhead),
+		)
+	}
+
+	return sent, nil
+}
+
+func (s *transactionService) sendDueReminder(ctx context.Context, installment *entity.TransactionDetail) error {
+	transaction, err := s.transactionRepo.GetByID(ctx, installment.TransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction: %w", err)
+	}
+	if transaction == nil {
+		return entity.ErrTransactionNotFound
+	}
+
+	customer, err := s.customerRepo.GetByID(ctx, transaction.CustomerID, true)
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer == nil {
+		return entity.ErrCustomerNotFound
+	}
+
+	return s.notifier.NotifyInstallmentDue(ctx, entity.InstallmentReminder{
+		TransactionID:     transaction.ID,
+		ContractNumber:    transaction.ContractNumber,
+		CustomerID:        customer.ID,
+		FullName:          customer.FullName,
+		Email:             customer.Email,
+		PhoneNumber:       customer.PhoneNumber,
+		InstallmentNumber: installment.InstallmentNumber,
+		AmountDue:         installment.RemainingAmount(),
+		DueDate:           installment.DueDate.Format("2006-01-02"),
+	})
+}
+
+func (s *transactionService) Cancel(ctx context.Context, id uuid.UUID) error {
+	if err := s.transactionRepo.Cancel(ctx, id); err != nil {
+		switch err {
+		case entity.ErrTransactionNotFound, entity.ErrTransactionNotCancellable, entity.ErrTransactionHasPayments:
+			return err
+		}
+		loggerPkg.FromContext(ctx).Error("failed to cancel transaction",
+			zap.Error(err),
+			zap.String("transaction_id", id.String()),
+		)
+		return fmt.Errorf("failed to cancel transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertTransaction persists the transaction. A generated contract number can
+// still collide with a concurrent insert after the pre-check, so it is minted
+// again and the insert retried, up to maxContractNumberAttempts in total.
+func (s *transactionService) insertTransaction(ctx context.Context, transaction *entity.Transaction, schedule []float64, generatedContract bool) error {
+	for attempt := 1; ; attempt++ {
+		err := s.transactionRepo.Create(ctx, transaction, schedule)
+		if err != entity.ErrDuplicateContract || !generatedContract || attempt == maxContractNumberAttempts {
+			return err
+		}
+
+		loggerPkg.FromContext(ctx).Warn("generated contract number collided on insert, retrying",
+			zap.String("contract_number", transaction.ContractNumber),
+			zap.Int("attempt", attempt),
+		)
+
+		contractNumber, err := entity.NewContractNumber(time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		transaction.ContractNumber = contractNumber
+	}
+}
+
+func (s *transactionService) generateContractNumber(ctx context.Context) (string, error) {
+	for attempt := 1; attempt <= maxContractNumberAttempts; attempt++ {
+		contractNumber, err := entity.NewContractNumber(s.clock.Now())
+		if err != nil {
+			return "", err
+		}
+
+		existing, err := s.transactionRepo.GetByContractNumber(ctx, contractNumber)
+		if err != nil {
+			loggerPkg.FromContext(ctx).Error("failed to check generated contract number",
+				zap.Error(err),
+				zap.String("contract_number", contractNumber),
+			)
+			return "", fmt.Errorf("failed to check existing contract: %w", err)
+		}
+		if existing == nil {
+			return contractNumber, nil
+		}
+
+		loggerPkg.FromContext(ctx).Warn("generated contract number collided, retrying",
+			zap.String("contract_number", contractNumber),
+			zap.Int("attempt", attempt),
+		)
+	}
+
+	return "", entity.ErrDuplicateContract
+}
+
 func (s *transactionService) toResponse(tx *entity.Transaction) *entity.TransactionResponse {
 	response := &entity.TransactionResponse{
 		ID:                tx.ID,
@@ -303,6 +910,8 @@ func (s *transactionService) toResponse(tx *entity.Transaction) *entity.Transact
 		InterestAmount:    tx.InterestAmount,
 		TenorMonth:        tx.TenorMonth,
 		InstallmentAmount: tx.InstallmentAmount,
+		InterestType:      tx.InterestType,
+		BillingDay:        tx.BillingDay,
 		Status:            tx.Status,
 		CreatedAt:         tx.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:         tx.UpdatedAt.Format(time.RFC3339),
@@ -315,6 +924,7 @@ func (s *transactionService) toResponse(tx *entity.Transaction) *entity.Transact
 			Category:    tx.Asset.Category,
 			Description: tx.Asset.Description,
 			Price:       tx.Asset.Price,
+			Stock:       tx.Asset.Stock,
 			CreatedAt:   tx.Asset.CreatedAt.Format(time.RFC3339),
 			UpdatedAt:   tx.Asset.UpdatedAt.Format(time.RFC3339),
 		}
@@ -335,20 +945,126 @@ func (s *transactionService) toResponse(tx *entity.Transaction) *entity.Transact
 		}
 	}
 
-	if tx.TransactionDetail != nil {
-		response.Installments = []entity.InstallmentResponse{
-			{
-				ID:                tx.TransactionDetail.ID,
-				TransactionID:     tx.TransactionDetail.TransactionID,
-				InstallmentNumber: tx.TransactionDetail.InstallmentNumber,
-				Amount:            tx.TransactionDetail.Amount,
-				DueDate:           tx.TransactionDetail.DueDate.Format("2006-01-02"),
-				Status:            tx.TransactionDetail.Status,
-				CreatedAt:         tx.TransactionDetail.CreatedAt.Format(time.RFC3339),
-				UpdatedAt:         tx.TransactionDetail.UpdatedAt.Format(time.RFC3339),
-			},
+	if len(tx.TransactionDetails) > 0 {
+		response.Installments = make([]entity.InstallmentResponse, len(tx.TransactionDetails))
+		for i, detail := range tx.TransactionDetails {
+			response.Installments[i] = *s.toInstallmentResponse(&detail)
 		}
 	}
 
 	return response
 }
+
+func (s *transactionService) toInstallmentResponse(detail *entity.TransactionDetail) *entity.InstallmentResponse {
+	return &entity.InstallmentResponse{
+		ID:                detail.ID,
+		TransactionID:     detail.TransactionID,
+		InstallmentNumber: detail.InstallmentNumber,
+		Amount:            detail.Amount,
+		PaidAmount:        detail.PaidAmount,
+		RemainingAmount:   detail.RemainingAmount(),
+		LateFee:           detail.LateFee,
+		DueDate:           detail.DueDate.Format("2006-01-02"),
+		Status:            detail.Status,
+		CreatedAt:         detail.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:         detail.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// calculateInstallmentSchedule returns the total interest and the amount due for each
+// installment. Flat interest is charged on the original principal for the whole tenor,
+// while effective interest is charged monthly on the outstanding principal only.
+func calculateInstallmentSchedule(principal, adminFee, interestRate float64, tenorMonth int, interestType entity.InterestType) (float64, []float64) {
+	schedule := make([]float64, tenorMonth)
+
+	if interestType == entity.InterestTypeEffective {
+		principalPerMonth := principal / float64(tenorMonth)
+		adminFeePerMonth := adminFee / float64(tenorMonth)
+		outstanding := principal
+		interestAmount := 0.0
+
+		for i := 0; i < tenorMonth; i++ {
+			interest := outstanding * interestRate / 100
+			schedule[i] = principalPerMonth + adminFeePerMonth + interest
+			interestAmount += interest
+			outstanding -= principalPerMonth
+		}
+
+		interestAmount = roundCurrency(interestAmount)
+		return interestAmount, balanceSchedule(schedule, principal+adminFee+interestAmount)
+	}
+
+	interestAmount := roundCurrency((principal * interestRate * float64(tenorMonth)) / 100)
+	return interestAmount, splitInstallments(principal+adminFee+interestAmount, tenorMonth)
+}
+
+// calculatePayoff quotes an early settlement: the principal still owed plus the
+// interest accrued for the current period and the late fees of overdue
+// installments. Interest on later periods is waived.
+// The principal portion of each installment is (OTR + admin fee) / tenor, so the
+// interest portion of the next installment is whatever remains of its amount,
+// which holds for both flat and effective schedules.
+func calculatePayoff(transaction *entity.Transaction, unpaid []entity.TransactionDetail) *entity.PayoffResponse {
+	payoff := &entity.PayoffResponse{
+		TransactionID:         transaction.ID,
+		RemainingInstallments: len(unpaid),
+	}
+	if len(unpaid) == 0 {
+		return payoff
+	}
+
+	principalPerMonth := (transaction.OTRAmount + transaction.AdminFee) / float64(transaction.TenorMonth)
+	scheduled := 0.0
+	lateFees := 0.0
+	partiallyPaid := 0.0
+	for _, detail := range unpaid {
+		//Payments go to the installment amount before its late fee
+		due := math.Max(detail.Amount-detail.PaidAmount, 0)
+		scheduled += due
+		lateFees += detail.RemainingAmount() - due
+		partiallyPaid += math.Min(detail.PaidAmount, detail.Amount)
+	}
+
+	//Partial payments on the unpaid installments have already reduced the principal
+	payoff.RemainingPrincipal = roundCurrency(math.Max(principalPerMonth*float64(len(unpaid))-partiallyPaid, 0))
+	payoff.AccruedInterest = roundCurrency(math.Max(unpaid[0].Amount-principalPerMonth, 0))
+	payoff.WaivedInterest = roundCurrency(scheduled - math.Min(payoff.RemainingPrincipal+payoff.AccruedInterest, scheduled))
+	//Only future interest is waived, late fees already accrued are owed in full
+	payoff.LateFees = roundCurrency(lateFees)
+	payoff.SettlementAmount = roundCurrency(scheduled - payoff.WaivedInterest + payoff.LateFees)
+
+	return payoff
+}
+
+// splitInstallments divides total into equal installments rounded to cents.
+// The last installment absorbs the rounding remainder, e.g. 1000.00 over 3
+// months yields 333.33, 333.33 and 333.34.
+func splitInstallments(total float64, tenorMonth int) []float64 {
+	schedule := make([]float64, tenorMonth)
+	for i := range schedule {
+		schedule[i] = total / float64(tenorMonth)
+	}
+	return balanceSchedule(schedule, total)
+}
+
+// balanceSchedule rounds every installment to cents and adjusts the last one so
+// the installments sum exactly to total.
+func balanceSchedule(schedule []float64, total float64) []float64 {
+	if len(schedule) == 0 {
+		return schedule
+	}
+
+	allocated := 0.0
+	last := len(schedule) - 1
+	for i := 0; i < last; i++ {
+		schedule[i] = roundCurrency(schedule[i])
+		allocated += schedule[i]
+	}
+	schedule[last] = roundCurrency(roundCurrency(total) - allocated)
+
+	return schedule
+}
+
+func roundCurrency(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"kredit-plus/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestToResponseListsEveryInstallment(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+	transaction := &entity.Transaction{
+		ID:         uuid.New(),
+		TenorMonth: 6,
+		Status:     entity.TransactionStatusActive,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+	for i := 0; i < transaction.TenorMonth; i++ {
+		transaction.TransactionDetails = append(transaction.TransactionDetails, entity.TransactionDetail{
+			ID:                uuid.New(),
+			TransactionID:     transaction.ID,
+			InstallmentNumber: i + 1,
+			Amount:            200,
+			DueDate:           createdAt.AddDate(0, i+1, 0),
+			Status:            entity.TransactionDetailStatusPending,
+		})
+	}
+
+	response := (&transactionService{}).toResponse(transaction)
+
+	if got := len(response.Installments); got != 6 {
+		t.Fatalf("got %d installments, want 6", got)
+	}
+	for i, installment := range response.Installments {
+		if installment.InstallmentNumber != i+1 {
+			t.Errorf("installment %d has number %d", i, installment.InstallmentNumber)
+		}
+		if installment.Amount != 200 {
+			t.Errorf("installment %d amount = %v, want 200", i+1, installment.Amount)
+		}
+	}
+}
+
+func TestSplitInstallmentsLastAbsorbsRemainder(t *testing.T) {
+	schedule := splitInstallments(1000, 3)
+
+	want := []float64{333.33, 333.33, 333.34}
+	if len(schedule) != len(want) {
+		t.Fatalf("got %d installments, want %d", len(schedule), len(want))
+	}
+	for i := range want {
+		if schedule[i] != want[i] {
+			t.Errorf("installment %d = %v, want %v", i+1, schedule[i], want[i])
+		}
+	}
+}
+
+func TestSplitInstallmentsSumToTotal(t *testing.T) {
+	for _, tc := range []struct {
+		total      float64
+		tenorMonth int
+	}{
+		{1000, 3},
+		{1000, 6},
+		{1234.56, 7},
+		{99.99, 12},
+		{10000000, 9},
+	} {
+		schedule := splitInstallments(tc.total, tc.tenorMonth)
+
+		sum := 0.0
+		for _, amount := range schedule {
+			if amount != roundCurrency(amount) {
+				t.Errorf("total %v over %d: installment %v is not rounded to cents", tc.total, tc.tenorMonth, amount)
+			}
+			sum += amount
+		}
+		if roundCurrency(sum) != tc.total {
+			t.Errorf("total %v over %d: installments sum to %v", tc.total, tc.tenorMonth, roundCurrency(sum))
+		}
+	}
+}
+
+// payoffTransaction is a 12-month flat schedule of 112 a month: 100 principal
+// and 12 interest.
+func payoffTransaction() (*entity.Transaction, []entity.TransactionDetail) {
+	transaction := &entity.Transaction{
+		ID:             uuid.New(),
+		OTRAmount:      1200,
+		InterestAmount: 144,
+		TenorMonth:     12,
+		InterestType:   entity.InterestTypeFlat,
+	}
+	_, schedule := calculateInstallmentSchedule(transaction.OTRAmount, transaction.AdminFee, 1, transaction.TenorMonth, transaction.InterestType)
+
+	details := make([]entity.TransactionDetail, len(schedule))
+	for i, amount := range schedule {
+		details[i] = entity.TransactionDetail{
+			InstallmentNumber: i + 1,
+			Amount:            amount,
+			Status:            entity.TransactionDetailStatusPending,
+		}
+	}
+	return transaction, details
+}
+
+func TestCalculatePayoff(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		paid           int     //Installments already paid
+		lateFee        float64 //Accrued on each of the first two unpaid installments
+		wantRemaining  int
+		wantPrincipal  float64
+		wantAccrued    float64
+		wantLateFees   float64
+		wantWaived     float64
+		wantSettlement float64
+	}{
+		{
+			name:           "full term outstanding",
+			wantRemaining:  12,
+			wantPrincipal:  1200,
+			wantAccrued:    12,
+			wantWaived:     132,
+			wantSettlement: 1212,
+		},
+		{
+			name:           "partial term outstanding",
+			paid:           4,
+			wantRemaining:  8,
+			wantPrincipal:  800,
+			wantAccrued:    12,
+			wantWaived:     84,
+			wantSettlement: 812,
+		},
+		{
+			name:           "partial term with overdue late fees",
+			paid:           4,
+			lateFee:        5.6,
+			wantRemaining:  8,
+			wantPrincipal:  800,
+			wantAccrued:    12,
+			wantLateFees:   11.2,
+			wantWaived:     84,
+			wantSettlement: 823.2,
+		},
+		{
+			name: "nothing outstanding",
+			paid: 12,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transaction, details := payoffTransaction()
+			unpaid := details[tc.paid:]
+			for i := 0; i < len(unpaid) && i < 2; i++ {
+				unpaid[i].LateFee = tc.lateFee
+			}
+
+			payoff := calculatePayoff(transaction, unpaid)
+
+			if payoff.TransactionID != transaction.ID {
+				t.Errorf("TransactionID = %v, want %v", payoff.TransactionID, transaction.ID)
+			}
+			if payoff.RemainingInstallments != tc.wantRemaining {
+				t.Errorf("RemainingInstallments = %d, want %d", payoff.RemainingInstallments, tc.wantRemaining)
+			}
+			if payoff.RemainingPrincipal != tc.wantPrincipal {
+				t.Errorf("RemainingPrincipal = %v, want %v", payoff.RemainingPrincipal, tc.wantPrincipal)
+			}
+			if payoff.AccruedInterest != tc.wantAccrued {
+				t.Errorf("AccruedInterest = %v, want %v", payoff.AccruedInterest, tc.wantAccrued)
+			}
+			if payoff.LateFees != tc.wantLateFees {
+				t.Errorf("LateFees = %v, want %v", payoff.LateFees, tc.wantLateFees)
+			}
+			if payoff.WaivedInterest != tc.wantWaived {
+				t.Errorf("WaivedInterest = %v, want %v", payoff.WaivedInterest, tc.wantWaived)
+			}
+			if payoff.SettlementAmount != tc.wantSettlement {
+				t.Errorf("SettlementAmount = %v, want %v", payoff.SettlementAmount, tc.wantSettlement)
+			}
+		})
+	}
+}
+
+// createFixture is a customer with both KYC documents, an in-stock asset and
+// a credit limit large enough for one purchase.
+type createFixture struct {
+	service      *transactionService
+	transactions *fakeTransactionRepository
+	customers    *fakeCustomerRepository
+	limits       *fakeCreditLimitRepository
+	assets       *fakeAssetRepository
+	request      entity.CreateTransactionRequest
+}
+
+func newCreateFixture() *createFixture {
+	customer := &entity.Customer{ID: uuid.New(), FullName: "Budi Santoso", Salary: 10000000, IsActive: true}
+	asset := &entity.Asset{ID: uuid.New(), Name: "Kulkas", Category: "white_goods", Price: 3000000, Stock: 5}
+	limit := &entity.CreditLimit{ID: uuid.New(), CustomerID: customer.ID, TenorMonth: 3, LimitAmount: 5000000}
+
+	transactions := newFakeTransactionRepository()
+	customers := newFakeCustomerRepository(customer)
+	customers.documents[customer.ID] = []entity.DocumentType{entity.DocumentTypeKTP, entity.DocumentTypeSelfie}
+	policy := entity.CreditPolicy{
+		RequiredDocuments:    []string{string(entity.DocumentTypeKTP), string(entity.DocumentTypeSelfie)},
+		DefaultInterestRates: map[int]float64{3: 2},
+	}
+
+	limits := newFakeCreditLimitRepository(limit)
+	assets := newFakeAssetRepository(asset)
+
+	service := NewTransactionService(transactions, customers, limits, assets, nil, policy, fixedClock{now: testNow}, zap.NewNop())
+
+	return &createFixture{
+		service:      service.(*transactionService),
+		transactions: transactions,
+		customers:    customers,
+		limits:       limits,
+		assets:       assets,
+		request: entity.CreateTransactionRequest{
+			CustomerID:     customer.ID,
+			AssetID:        asset.ID,
+			TenorMonth:     3,
+			ContractNumber: "KP-20240315-0001",
+		},
+	}
+}
+
+func TestCreateConcurrentDuplicateContractSucceedsOnce(t *testing.T) {
+	fixture := newCreateFixture()
+	//Without the Redis lock the unique contract number is the only guard, and
+	//both requests get past the pre-check before either inserts
+	fixture.transactions.lockErr = errors.New("redis unavailable")
+	fixture.transactions.contractChecks = newBarrier(2)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := fixture.service.Create(context.Background(), fixture.request)
+			errs <- err
+		}()
+	}
+
+	var succeeded, duplicates int
+	for i := 0; i < 2; i++ {
+		switch err := <-errs; {
+		case err == nil:
+			succeeded++
+		case err == entity.ErrDuplicateContract:
+			duplicates++
+		default:
+			t.Errorf("Create returned unexpected error: %v", err)
+		}
+	}
+
+	if succeeded != 1 || duplicates != 1 {
+		t.Errorf("got %d successes and %d duplicates, want exactly one of each", succeeded, duplicates)
+	}
+	if got := fixture.transactions.count(); got != 1 {
+		t.Errorf("stored %d transactions, want 1", got)
+	}
+}
+
+func TestCreateSchedulesDueDatesFromClock(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		now        time.Time
+		billingDay int
+		want       []time.Time
+	}{
+		{
+			name: "months from creation",
+			now:  testNow,
+			want: []time.Time{
+				time.Date(2024, time.April, 15, 9, 30, 0, 0, time.UTC),
+				time.Date(2024, time.May, 15, 9, 30, 0, 0, time.UTC),
+				time.Date(2024, time.June, 15, 9, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:       "billing day earlier in the month",
+			now:        testNow,
+			billingDay: 5,
+			want: []time.Time{
+				time.Date(2024, time.April, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.May, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.June, 5, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:       "created on the 31st",
+			now:        time.Date(2024, time.January, 31, 16, 0, 0, 0, time.UTC),
+			billingDay: entity.MaxBillingDay,
+			want: []time.Time{
+				time.Date(2024, time.February, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.March, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.April, 28, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fixture := newCreateFixture()
+			fixture.service.clock = fixedClock{now: tc.now}
+			fixture.request.BillingDay = tc.billingDay
+
+			created, err := fixture.service.Create(context.Background(), fixture.request)
+			if err != nil {
+				t.Fatalf("Create returned error: %v", err)
+			}
+
+			stored, _ := fixture.transactions.GetByID(context.Background(), created.ID)
+			if !stored.CreatedAt.Equal(tc.now) {
+				t.Errorf("created at %v, want the clock's %v", stored.CreatedAt, tc.now)
+			}
+			if len(stored.TransactionDetails) != len(tc.want) {
+				t.Fatalf("scheduled %d installments, want %d", len(stored.TransactionDetails), len(tc.want))
+			}
+			for i, detail := range stored.TransactionDetails {
+				if !detail.DueDate.Equal(tc.want[i]) {
+					t.Errorf("installment %d due %v, want %v", i+1, detail.DueDate, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateRequiresEveryKYCDocument(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		documents []entity.DocumentType
+	}{
+		{"only ktp", []entity.DocumentType{entity.DocumentTypeKTP}},
+		{"only selfie", []entity.DocumentType{entity.DocumentTypeSelfie}},
+		{"none", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fixture := newCreateFixture()
+			fixture.customers.documents[fixture.request.CustomerID] = tc.documents
+
+			_, err := fixture.service.Create(context.Background(), fixture.request)
+			if !errors.Is(err, entity.ErrKYCIncomplete) {
+				t.Fatalf("Create returned %v, want ErrKYCIncomplete", err)
+			}
+			if got := fixture.transactions.count(); got != 0 {
+				t.Errorf("stored %d transactions, want 0", got)
+			}
+		})
+	}
+}
+
+func TestCreateStoresEscapedContractNumber(t *testing.T) {
+	fixture := newCreateFixture()
+	fixture.request.ContractNumber = "KP-<b>0001</b>"
+
+	created, err := fixture.service.Create(context.Background(), fixture.request)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	stored, _ := fixture.transactions.GetByID(context.Background(), created.ID)
+	want := "KP-&lt;b&gt;0001&lt;/b&gt;"
+	if stored.ContractNumber != want {
+		t.Errorf("stored contract number %q, want %q", stored.ContractNumber, want)
+	}
+}
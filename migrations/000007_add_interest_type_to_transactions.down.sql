@@ -0,0 +1,2 @@
+-- 000007_add_interest_type_to_transactions.down.sql
+ALTER TABLE transactions DROP COLUMN interest_type;
\ No newline at end of file
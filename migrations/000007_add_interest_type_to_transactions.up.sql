@@ -0,0 +1,3 @@
+-- 000007_add_interest_type_to_transactions.up.sql
+ALTER TABLE transactions
+    ADD COLUMN interest_type VARCHAR(20) NOT NULL DEFAULT 'flat' CHECK (interest_type IN ('flat', 'effective')) AFTER installment_amount;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- 000008_add_cancelled_transaction_status.down.sql
+ALTER TABLE transactions DROP CHECK transactions_chk_1;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_chk_1 CHECK (status IN ('pending', 'active', 'completed'));
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- 000009_add_late_fee_to_transaction_details.down.sql
+ALTER TABLE transaction_details DROP COLUMN late_fee;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- 000009_add_late_fee_to_transaction_details.up.sql
+ALTER TABLE transaction_details
+    ADD COLUMN late_fee DECIMAL(15,2) NOT NULL DEFAULT 0 AFTER amount;
\ No newline at end of file
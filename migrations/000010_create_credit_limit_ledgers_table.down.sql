@@ -0,0 +1,2 @@
+-- 000010_create_credit_limit_ledgers_table.down.sql
+DROP TABLE IF EXISTS credit_limit_ledgers;
\ No newline at end of file
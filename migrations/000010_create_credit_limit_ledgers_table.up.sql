@@ -0,0 +1,11 @@
+-- 000010_create_credit_limit_ledgers_table.up.sql
+CREATE TABLE IF NOT EXISTS credit_limit_ledgers (
+    id CHAR(36) PRIMARY KEY,
+    credit_limit_id CHAR(36) NOT NULL,
+    delta DECIMAL(15,2) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    balance_after DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+    );
+
+CREATE INDEX idx_credit_limit_ledgers_credit_limit_id ON credit_limit_ledgers(credit_limit_id, created_at);
\ No newline at end of file
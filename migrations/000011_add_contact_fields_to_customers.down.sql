@@ -0,0 +1,4 @@
+-- 000011_add_contact_fields_to_customers.down.sql
+ALTER TABLE customers
+    DROP COLUMN phone_number,
+    DROP COLUMN email;
\ No newline at end of file
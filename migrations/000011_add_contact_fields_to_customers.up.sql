@@ -0,0 +1,4 @@
+-- 000011_add_contact_fields_to_customers.up.sql
+ALTER TABLE customers
+    ADD COLUMN email VARCHAR(100) NOT NULL DEFAULT '' AFTER salary,
+    ADD COLUMN phone_number VARCHAR(20) NOT NULL DEFAULT '' AFTER email;
\ No newline at end of file
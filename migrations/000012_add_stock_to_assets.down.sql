@@ -0,0 +1,3 @@
+-- 000012_add_stock_to_assets.down.sql
+ALTER TABLE assets
+    DROP COLUMN stock;
\ No newline at end of file
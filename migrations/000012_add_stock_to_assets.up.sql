@@ -0,0 +1,6 @@
+-- 000012_add_stock_to_assets.up.sql
+-- Existing assets start with no stock and cannot be financed until an operator
+-- loads the counted level, e.g. through PUT /api/v1/assets/:id with "stock" or
+-- UPDATE assets SET stock = <counted units> WHERE id = '<asset id>';
+ALTER TABLE assets
+    ADD COLUMN stock INT NOT NULL DEFAULT 0 AFTER price;
\ No newline at end of file
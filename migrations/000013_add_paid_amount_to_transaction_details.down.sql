@@ -0,0 +1,3 @@
+-- 000013_add_paid_amount_to_transaction_details.down.sql
+ALTER TABLE transaction_details
+    DROP COLUMN paid_amount;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- 000013_add_paid_amount_to_transaction_details.up.sql
+ALTER TABLE transaction_details
+    ADD COLUMN paid_amount DECIMAL(15,2) NOT NULL DEFAULT 0 AFTER amount;
+
+UPDATE transaction_details
+SET paid_amount = amount + late_fee
+WHERE status = 'paid';
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- 000014_add_unique_customer_tenor_to_credit_limits.down.sql
+ALTER TABLE credit_limits
+    DROP INDEX uniq_credit_limits_customer_tenor;
\ No newline at end of file
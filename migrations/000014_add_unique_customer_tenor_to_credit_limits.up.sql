@@ -0,0 +1,3 @@
+-- 000014_add_unique_customer_tenor_to_credit_limits.up.sql
+ALTER TABLE credit_limits
+    ADD UNIQUE INDEX uniq_credit_limits_customer_tenor (customer_id, tenor_month);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- 000015_add_deleted_at_to_assets.down.sql
+ALTER TABLE assets
+    DROP INDEX idx_assets_deleted_at,
+    DROP COLUMN deleted_at;
\ No newline at end of file
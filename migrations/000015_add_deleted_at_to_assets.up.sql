@@ -0,0 +1,4 @@
+-- 000015_add_deleted_at_to_assets.up.sql
+ALTER TABLE assets
+    ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL AFTER updated_at,
+    ADD INDEX idx_assets_deleted_at (deleted_at);
\ No newline at end of file
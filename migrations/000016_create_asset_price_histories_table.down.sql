@@ -0,0 +1,2 @@
+-- 000016_create_asset_price_histories_table.down.sql
+DROP TABLE IF EXISTS asset_price_histories;
\ No newline at end of file
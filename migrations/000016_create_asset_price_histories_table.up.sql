@@ -0,0 +1,10 @@
+-- 000016_create_asset_price_histories_table.up.sql
+CREATE TABLE IF NOT EXISTS asset_price_histories (
+    id CHAR(36) PRIMARY KEY,
+    asset_id CHAR(36) NOT NULL,
+    old_price DECIMAL(15,2) NOT NULL,
+    new_price DECIMAL(15,2) NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+    );
+
+CREATE INDEX idx_asset_price_histories_asset_id ON asset_price_histories(asset_id, changed_at);
\ No newline at end of file
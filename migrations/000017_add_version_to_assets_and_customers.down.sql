@@ -0,0 +1,6 @@
+-- 000017_add_version_to_assets_and_customers.down.sql
+ALTER TABLE customers
+    DROP COLUMN version;
+
+ALTER TABLE assets
+    DROP COLUMN version;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- 000017_add_version_to_assets_and_customers.up.sql
+ALTER TABLE assets
+    ADD COLUMN version INT NOT NULL DEFAULT 0 AFTER stock;
+
+ALTER TABLE customers
+    ADD COLUMN version INT NOT NULL DEFAULT 0 AFTER is_active;
\ No newline at end of file
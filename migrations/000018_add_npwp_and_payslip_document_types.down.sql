@@ -0,0 +1,7 @@
+-- 000018_add_npwp_and_payslip_document_types.down.sql
+DELETE FROM customer_documents WHERE document_type IN ('npwp', 'payslip');
+
+ALTER TABLE customer_documents
+    DROP CHECK chk_customer_documents_document_type,
+    ADD CONSTRAINT customer_documents_chk_1
+        CHECK (document_type IN ('ktp', 'selfie'));
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- 000018_add_npwp_and_payslip_document_types.up.sql
+-- customer_documents_chk_1 is the name MySQL gave the unnamed check in 000002
+ALTER TABLE customer_documents
+    DROP CHECK customer_documents_chk_1,
+    ADD CONSTRAINT chk_customer_documents_document_type
+        CHECK (document_type IN ('ktp', 'selfie', 'npwp', 'payslip'));
\ No newline at end of file
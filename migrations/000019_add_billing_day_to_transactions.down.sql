@@ -0,0 +1,3 @@
+-- 000019_add_billing_day_to_transactions.down.sql
+ALTER TABLE transactions
+    DROP COLUMN billing_day;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- 000019_add_billing_day_to_transactions.up.sql
+ALTER TABLE transactions
+    ADD COLUMN billing_day TINYINT NOT NULL DEFAULT 0 AFTER interest_type;
\ No newline at end of file
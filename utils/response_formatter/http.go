@@ -1,23 +1,34 @@
 package response_formatter
 
 import (
+	"errors"
 	"math"
 	"net/http"
 )
 
 type Meta struct {
-	Page      int   `json:"page"`
-	PerPage   int   `json:"per_page"`
-	Total     int64 `json:"total"`
-	TotalPage int   `json:"total_page"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	Total      int64  `json:"total"`
+	TotalPage  int    `json:"total_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Meta    *Meta       `json:"meta,omitempty"`
-	Errors  []string    `json:"errors,omitempty"`
+	Code      int         `json:"code"`
+	ErrorCode string      `json:"error_code,omitempty"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data,omitempty"`
+	Meta      *Meta       `json:"meta,omitempty"`
+	Errors    []string    `json:"errors,omitempty"`
+	Fields    interface{} `json:"fields,omitempty"`
+}
+
+// codedError is implemented by the domain errors that carry a stable,
+// machine-readable code.
+type codedError interface {
+	error
+	ErrorCode() string
 }
 
 func Success(data interface{}, message string) Response {
@@ -44,6 +55,29 @@ func Error(code int, message string, errors []string) Response {
 	}
 }
 
+// CodedError builds an error response from err, exposing its domain code as
+// error_code when err is (or wraps) a coded domain error.
+func CodedError(code int, message string, err error) Response {
+	response := Error(code, message, []string{err.Error()})
+
+	var coded codedError
+	if errors.As(err, &coded) {
+		response.ErrorCode = coded.ErrorCode()
+	}
+	return response
+}
+
+// ValidationError builds a 422 response carrying field-level validation errors.
+func ValidationError(fields interface{}, errors []string) Response {
+	return Response{
+		Code:      http.StatusUnprocessableEntity,
+		ErrorCode: "VALIDATION_FAILED",
+		Message:   "Validation failed",
+		Errors:    errors,
+		Fields:    fields,
+	}
+}
+
 func WithPagination(data interface{}, message string, page, perPage int, total int64) Response {
 	totalPage := int(math.Ceil(float64(total) / float64(perPage)))
 
@@ -60,6 +94,12 @@ func WithPagination(data interface{}, message string, page, perPage int, total i
 	}
 }
 
+func WithCursorPagination(data interface{}, message string, page, perPage int, total int64, nextCursor string) Response {
+	response := WithPagination(data, message, page, perPage, total)
+	response.Meta.NextCursor = nextCursor
+	return response
+}
+
 func ValidatePagination(page, perPage int) (int, int) {
 	if page < 1 {
 		page = 1
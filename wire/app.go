@@ -0,0 +1,29 @@
+package wire
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"kredit-plus/internal/entity"
+	"kredit-plus/internal/handler"
+)
+
+// App bundles every handler built from one shared set of repositories.
+type App struct {
+	HealthHandler      *handler.HealthHandler
+	AdminHandler       *handler.AdminHandler
+	AssetHandler       *handler.AssetHandler
+	CustomerHandler    *handler.CustomerHandler
+	CreditLimitHandler *handler.CreditLimitHandler
+	TransactionHandler *handler.TransactionHandler
+	FinancingHandler   *handler.FinancingHandler
+	TransactionService entity.TransactionService
+}
+
+func (a *App) RegisterRoutes(app *fiber.App) {
+	a.HealthHandler.RegisterRoutes(app)
+	a.AdminHandler.RegisterRoutes(app)
+	a.AssetHandler.RegisterRoutes(app)
+	a.CustomerHandler.RegisterRoutes(app)
+	a.CreditLimitHandler.RegisterRoutes(app)
+	a.TransactionHandler.RegisterRoutes(app)
+	a.FinancingHandler.RegisterRoutes(app)
+}
@@ -8,6 +8,7 @@ import (
 	"go.uber.org/zap"
 	"kredit-plus/infra/mysql"
 	"kredit-plus/infra/redis"
+	"kredit-plus/internal/entity"
 	"kredit-plus/internal/handler"
 	"kredit-plus/internal/repository"
 	"kredit-plus/internal/service"
@@ -16,71 +17,72 @@ import (
 var (
 	AssetSet = wire.NewSet(
 		repository.NewAssetRepository,
+		service.NewSystemClock,
 		service.NewAssetService,
 		handler.NewAssetHandler,
 	)
 
 	CustomerSet = wire.NewSet(
 		repository.NewCustomerRepository,
+		repository.NewCreditLimitRepository,
+		service.NewSystemClock,
 		service.NewCustomerService,
 		handler.NewCustomerHandler,
 	)
 
 	CreditLimitSet = wire.NewSet(
 		repository.NewCreditLimitRepository,
+		service.NewSystemClock,
 		service.NewCreditLimitService,
 		handler.NewCreditLimitHandler,
 	)
 
-	TransactionProviderSet = wire.NewSet(
+	TransactionServiceSet = wire.NewSet(
 		repository.NewTransactionRepository,
 		repository.NewCustomerRepository,
 		repository.NewCreditLimitRepository,
 		repository.NewAssetRepository,
+		service.NewSystemClock,
+		service.NewLogReminderNotifier,
 		service.NewTransactionService,
+	)
+
+	TransactionProviderSet = wire.NewSet(
+		TransactionServiceSet,
 		handler.NewTransactionHandler,
 	)
 
-	DomainSet = wire.NewSet(
-		AssetSet,
-		CustomerSet,
-		CreditLimitSet,
-		TransactionProviderSet,
+	AppSet = wire.NewSet(
+		repository.NewAssetRepository,
+		repository.NewCustomerRepository,
+		repository.NewCreditLimitRepository,
+		repository.NewTransactionRepository,
+		service.NewSystemClock,
+		service.NewAssetService,
+		service.NewCustomerService,
+		service.NewCreditLimitService,
+		service.NewLogReminderNotifier,
+		service.NewTransactionService,
+		service.NewFinancingService,
+		handler.NewHealthHandler,
+		handler.NewAdminHandler,
+		handler.NewAssetHandler,
+		handler.NewCustomerHandler,
+		handler.NewCreditLimitHandler,
+		handler.NewTransactionHandler,
+		handler.NewFinancingHandler,
+		wire.Struct(new(App), "*"),
 	)
 )
 
-func InitializeAssetHandler(
-	db *mysql.Client,
-	redisClient *redis.Client,
-	logger *zap.Logger,
-) (*handler.AssetHandler, error) {
-	wire.Build(AssetSet)
-	return &handler.AssetHandler{}, nil
-}
-
-func InitializeCustomerHandler(
-	db *mysql.Client,
-	redisClient *redis.Client,
-	logger *zap.Logger,
-) (*handler.CustomerHandler, error) {
-	wire.Build(CustomerSet)
-	return &handler.CustomerHandler{}, nil
-}
-
-func InitializeCreditLimitHandler(
-	db *mysql.Client,
-	redisClient *redis.Client,
-	logger *zap.Logger,
-) (*handler.CreditLimitHandler, error) {
-	wire.Build(CreditLimitSet)
-	return &handler.CreditLimitHandler{}, nil
-}
-
-func InitializeTransactionProviderHandler(
+func InitializeApp(
 	db *mysql.Client,
 	redisClient *redis.Client,
+	publisher entity.EventPublisher,
+	creditPolicy entity.CreditPolicy,
+	logLevel zap.AtomicLevel,
 	logger *zap.Logger,
-) (*handler.TransactionHandler, error) {
-	wire.Build(TransactionProviderSet)
-	return &handler.TransactionHandler{}, nil
+) (*App, error) {
+	wire.Build(AppSet)
+	return &App{}, nil
 }
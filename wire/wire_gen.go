@@ -11,6 +11,7 @@ import (
 	"go.uber.org/zap"
 	"kredit-plus/infra/mysql"
 	"kredit-plus/infra/redis"
+	"kredit-plus/internal/entity"
 	"kredit-plus/internal/handler"
 	"kredit-plus/internal/repository"
 	"kredit-plus/internal/service"
@@ -18,52 +19,50 @@ import (
 
 // Injectors from wire.go:
 
-func InitializeAssetHandler(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) (*handler.AssetHandler, error) {
+func InitializeApp(db *mysql.Client, redisClient *redis.Client, publisher entity.EventPublisher, creditPolicy entity.CreditPolicy, logLevel zap.AtomicLevel, logger *zap.Logger) (*App, error) {
+	healthHandler := handler.NewHealthHandler(db, redisClient, logger)
+	adminHandler := handler.NewAdminHandler(logLevel, logger)
 	assetRepository := repository.NewAssetRepository(db, redisClient, logger)
-	assetService := service.NewAssetService(assetRepository, logger)
+	clock := service.NewSystemClock()
+	assetService := service.NewAssetService(assetRepository, clock, logger)
 	assetHandler := handler.NewAssetHandler(assetService, logger)
-	return assetHandler, nil
-}
-
-func InitializeCustomerHandler(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) (*handler.CustomerHandler, error) {
-	customerRepository := repository.NewCustomerRepository(db, redisClient, logger)
-	customerService := service.NewCustomerService(customerRepository, logger)
+	customerRepository := repository.NewCustomerRepository(db, redisClient, clock, logger)
+	creditLimitRepository := repository.NewCreditLimitRepository(db, redisClient, clock, logger)
+	customerService := service.NewCustomerService(customerRepository, creditLimitRepository, creditPolicy, clock, logger)
 	customerHandler := handler.NewCustomerHandler(customerService, logger)
-	return customerHandler, nil
-}
-
-func InitializeCreditLimitHandler(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) (*handler.CreditLimitHandler, error) {
-	creditLimitRepository := repository.NewCreditLimitRepository(db, logger)
-	creditLimitService := service.NewCreditLimitService(creditLimitRepository, logger)
+	creditLimitService := service.NewCreditLimitService(creditLimitRepository, clock, logger)
 	creditLimitHandler := handler.NewCreditLimitHandler(creditLimitService, logger)
-	return creditLimitHandler, nil
-}
-
-func InitializeTransactionProviderHandler(db *mysql.Client, redisClient *redis.Client, logger *zap.Logger) (*handler.TransactionHandler, error) {
-	transactionRepository := repository.NewTransactionRepository(db, logger)
-	customerRepository := repository.NewCustomerRepository(db, redisClient, logger)
-	creditLimitRepository := repository.NewCreditLimitRepository(db, logger)
-	assetRepository := repository.NewAssetRepository(db, redisClient, logger)
-	transactionService := service.NewTransactionService(transactionRepository, customerRepository, creditLimitRepository, assetRepository, logger)
+	transactionRepository := repository.NewTransactionRepository(db, redisClient, publisher, clock, logger)
+	reminderNotifier := service.NewLogReminderNotifier(logger)
+	transactionService := service.NewTransactionService(transactionRepository, customerRepository, creditLimitRepository, assetRepository, reminderNotifier, creditPolicy, clock, logger)
 	transactionHandler := handler.NewTransactionHandler(transactionService, logger)
-	return transactionHandler, nil
+	financingService := service.NewFinancingService(transactionService, transactionRepository, customerRepository, creditLimitRepository, assetRepository, creditPolicy, logger)
+	financingHandler := handler.NewFinancingHandler(financingService, logger)
+	app := &App{
+		HealthHandler:      healthHandler,
+		AdminHandler:       adminHandler,
+		AssetHandler:       assetHandler,
+		CustomerHandler:    customerHandler,
+		CreditLimitHandler: creditLimitHandler,
+		TransactionHandler: transactionHandler,
+		FinancingHandler:   financingHandler,
+		TransactionService: transactionService,
+	}
+	return app, nil
 }
 
 // wire.go:
 
 var (
-	AssetSet = wire.NewSet(repository.NewAssetRepository, service.NewAssetService, handler.NewAssetHandler)
+	AssetSet = wire.NewSet(repository.NewAssetRepository, service.NewSystemClock, service.NewAssetService, handler.NewAssetHandler)
+
+	CustomerSet = wire.NewSet(repository.NewCustomerRepository, repository.NewCreditLimitRepository, service.NewSystemClock, service.NewCustomerService, handler.NewCustomerHandler)
 
-	CustomerSet = wire.NewSet(repository.NewCustomerRepository, service.NewCustomerService, handler.NewCustomerHandler)
+	CreditLimitSet = wire.NewSet(repository.NewCreditLimitRepository, service.NewSystemClock, service.NewCreditLimitService, handler.NewCreditLimitHandler)
 
-	CreditLimitSet = wire.NewSet(repository.NewCreditLimitRepository, service.NewCreditLimitService, handler.NewCreditLimitHandler)
+	TransactionServiceSet = wire.NewSet(repository.NewTransactionRepository, repository.NewCustomerRepository, repository.NewCreditLimitRepository, repository.NewAssetRepository, service.NewSystemClock, service.NewLogReminderNotifier, service.NewTransactionService)
 
-	TransactionProviderSet = wire.NewSet(repository.NewTransactionRepository, repository.NewCustomerRepository, repository.NewCreditLimitRepository, repository.NewAssetRepository, service.NewTransactionService, handler.NewTransactionHandler)
+	TransactionProviderSet = wire.NewSet(TransactionServiceSet, handler.NewTransactionHandler)
 
-	DomainSet = wire.NewSet(
-		AssetSet,
-		CustomerSet,
-		CreditLimitSet,
-		TransactionProviderSet,
-	)
+	AppSet = wire.NewSet(repository.NewAssetRepository, repository.NewCustomerRepository, repository.NewCreditLimitRepository, repository.NewTransactionRepository, service.NewSystemClock, service.NewAssetService, service.NewCustomerService, service.NewCreditLimitService, service.NewLogReminderNotifier, service.NewTransactionService, service.NewFinancingService, handler.NewHealthHandler, handler.NewAdminHandler, handler.NewAssetHandler, handler.NewCustomerHandler, handler.NewCreditLimitHandler, handler.NewTransactionHandler, handler.NewFinancingHandler, wire.Struct(new(App), "*"))
 )